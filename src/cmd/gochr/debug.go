@@ -0,0 +1,68 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	chr "github.com/hfried/GoCHR/src/engine/CHR"
+)
+
+const helpDebug = `
+usage: gochr debug [-max-firings n] [-refined] [-order o] input-file
+
+Evaluates Constraint Handling Rules step by step. The evaluation pauses
+before every rule firing and shows the rule, the active constraint
+(-refined), the constraints matched by the heads and the bindings of the
+rule variables. The commands are read from stdin; at the end of stdin the
+evaluation runs without pauses. The result is printed like by 'gochr eval'.
+
+The flags -max-firings, -refined and -order are the flags of 'gochr eval'.
+
+` + chr.DebuggerHelp + `
+`
+
+// ###
+func debugCmd() {
+	debug := flag.NewFlagSet("debug", flag.ContinueOnError)
+	maxFiringsFlag := debug.Int("max-firings", chr.DefaultMaxRuleFirings, "the maximal number of rule firings")
+	refinedFlag := debug.Bool("refined", false, "use the refined operational semantics")
+	orderFlag := debug.String("order", "id", "the order of the printed constraints: id, term or none")
+
+	if err := debug.Parse(os.Args[2:]); err != nil {
+		log.Fatal(err)
+	}
+	order, err := chr.ParseStoreOrder(*orderFlag)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if debug.NArg() != 1 {
+		log.Fatal(fmt.Errorf("incorrect number of arguments after the command flags; should be 1, naming the input file (stdin is used for the commands)\n"))
+	}
+	inFile, err := os.Open(debug.Args()[0])
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer inFile.Close()
+
+	rs := chr.MakeRuleStore()
+	rs.MaxRuleFirings = *maxFiringsFlag
+	if *refinedFlag {
+		rs.Mode = chr.ModeRefined
+	}
+	rs.Order = order
+	d := chr.NewDebugger(&rs.Session, os.Stdin, os.Stdout)
+	d.OnQuit = func() { os.Exit(0) }
+	fmt.Println("'help' for the commands")
+	if !rs.ParseFileCHRRulesGoals(inFile) {
+		os.Exit(1)
+	}
+	chr.WriteCHRStore(rs, os.Stdout)
+	if rs.Result != chr.RStore {
+		fmt.Println()
+	}
+	if rs.Err != nil {
+		fmt.Fprintf(os.Stderr, "\n!!! %s, the store is partial\n", rs.Err)
+	}
+}
@@ -11,7 +11,8 @@ import (
 )
 
 const helpEval = `
-usage: gochr eval [-o output-file] [input-file]
+usage: gochr eval [-o output-file] [-max-firings n] [-timeout duration] [-refined] [-order o]
+                  [-stats] [-stats-format f] [input-file]
 
 Evaluates Constraint Handling Rules and prints the relult.
 
@@ -19,6 +20,26 @@ If no input-file is specified, input is read from stdin.
 
 The -o flag specifies the output file name. If the -o flag is not used, 
 output goes to stdout.
+
+The -max-firings flag limits the number of rule firings (default 100000),
+the -timeout flag limits the duration of the evaluation, e.g. 10s.
+If a limit is exceeded, the partial store is printed and the exit status is 1.
+
+The -refined flag selects the refined operational semantics: every new
+constraint is executed as active constraint through its occurrences in
+textual order.
+
+The -order flag selects the order of the printed constraints: id (default)
+in the order of their insertion into the store, term in canonical term
+order (functor, arity, printed term) or none in the internal order, which
+may change from run to run.
+
+The -stats flag prints the statistics of the evaluation to stderr: for
+every rule the number of tries, head match attempts, guard failures and
+firings and the time spent, the number of rule firings and renamings of
+rule variables and the peak, final and mean number of constraints in the
+CHR-store.
+The -stats-format flag selects the format: table (default) or json.
 `
 
 func contains(l []string, s1 string) bool {
@@ -36,6 +57,12 @@ func evalCmd() {
 	// fromFlag := eval.String("f", "yaml", "the format of the source file")
 	// toFlag := eval.String("t", "graphml", "the format of the output file")
 	outFileFlag := eval.String("o", "", "the filename of the output file")
+	maxFiringsFlag := eval.Int("max-firings", chr.DefaultMaxRuleFirings, "the maximal number of rule firings")
+	timeoutFlag := eval.Duration("timeout", 0, "the maximal duration of the evaluation, 0 = no limit")
+	refinedFlag := eval.Bool("refined", false, "use the refined operational semantics")
+	orderFlag := eval.String("order", "id", "the order of the printed constraints: id, term or none")
+	statsFlag := eval.Bool("stats", false, "print the statistics of the evaluation")
+	statsFormatFlag := eval.String("stats-format", "table", "the format of the statistics: table or json")
 
 	var inFile *os.File
 	var outFile *os.File
@@ -44,6 +71,13 @@ func evalCmd() {
 	if err := eval.Parse(os.Args[2:]); err != nil {
 		log.Fatal(err)
 	}
+	order, err := chr.ParseStoreOrder(*orderFlag)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if *statsFormatFlag != "table" && *statsFormatFlag != "json" {
+		log.Fatal(fmt.Errorf("unknown statistics format: %s, should be table or json", *statsFormatFlag))
+	}
 
 	switch eval.NArg() {
 	case 0:
@@ -67,14 +101,35 @@ func evalCmd() {
 		}
 	}
 	rs := chr.MakeRuleStore()
+	rs.MaxRuleFirings = *maxFiringsFlag
+	rs.Timeout = *timeoutFlag
+	if *refinedFlag {
+		rs.Mode = chr.ModeRefined
+	}
+	rs.Order = order
+	if *statsFlag {
+		rs.Stats = &chr.Stats{}
+	}
 	ok := rs.ParseFileCHRRulesGoals(inFile)
 	if !ok {
 		log.Fatal(fmt.Errorf("%s\n", err))
 	}
-	chr.CHRtrace = 0
-	chr.CHRsolver(rs)
-
-	chr.CHRtrace = 1
 	chr.WriteCHRStore(rs, outFile)
-
+	if rs.Err != nil {
+		fmt.Fprintf(os.Stderr, "\n!!! %s, the store is partial\n", rs.Err)
+	}
+	if rs.Stats != nil {
+		fmt.Fprintln(os.Stderr)
+		if *statsFormatFlag == "json" {
+			err = rs.Stats.WriteJSON(os.Stderr)
+		} else {
+			err = rs.Stats.WriteTable(os.Stderr)
+		}
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+	if rs.Err != nil {
+		os.Exit(1)
+	}
 }
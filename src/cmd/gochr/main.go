@@ -9,6 +9,8 @@ package main
 import (
 	"fmt"
 	"os"
+
+	chr "github.com/hfried/GoCHR/src/engine/CHR"
 )
 
 const help = `
@@ -19,9 +21,13 @@ Usage: gochr command [arguments]
 The commands are:
 
 eval - evaluate Constraint Handling Rules
+debug - evaluate Constraint Handling Rules step by step
+repl - read and evaluate Constraint Handling Rules interactively
+test - run the expected results in Constraint Handling Rules files as tests
 help - displays instructions
 
-Execute "gochr help [command]" for further information.
+Execute "gochr help [command]" for further information and
+"gochr help builtins" for the standard built-in functions and the finite domain constraints.
 `
 const (
 	Name    = "GoCHR"
@@ -35,6 +41,12 @@ func main() {
 		switch os.Args[1] {
 		case "eval":
 			evalCmd()
+		case "debug":
+			debugCmd()
+		case "repl":
+			replCmd()
+		case "test":
+			testCmd()
 		default:
 			if len(os.Args) == 2 {
 				fmt.Printf("%s\n", help)
@@ -42,6 +54,14 @@ func main() {
 				switch os.Args[2] {
 				case "eval":
 					fmt.Printf("%s\n", helpEval)
+				case "debug":
+					fmt.Printf("%s\n", helpDebug)
+				case "repl":
+					fmt.Printf("%s\n", helpRepl)
+				case "test":
+					fmt.Printf("%s\n", helpTest)
+				case "builtins":
+					fmt.Printf("\n%s\n\n%s\n\n", chr.StdlibHelp, chr.FDHelp)
 				default:
 					fmt.Printf("%s\n", help)
 				}
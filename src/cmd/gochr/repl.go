@@ -0,0 +1,168 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+
+	chr "github.com/hfried/GoCHR/src/engine/CHR"
+)
+
+const helpRepl = `
+usage: gochr repl [-order o] [input-file]
+
+Reads Constraint Handling Rules and goals line by line from stdin and
+evaluates them. Rules are added to the rule store, goals are added to the
+CHR-store and solved with all rules entered so far. An entry ends with a '.'.
+
+If an input-file is specified, it is loaded before the first prompt.
+
+The -order flag selects the order of the printed constraints: id (default),
+term or none (see 'gochr help eval').
+
+The commands are:
+
+:load <file>  - clear the CHR- and Built-In-store, load and evaluate the
+                rules and goals of a file (the expected results are checked
+                without the goals entered before)
+:store        - print the CHR- and Built-In-store
+:rules        - print the rule store
+:clear        - clear the CHR- and Built-In-store, the rules are kept
+:trace <n>    - set the trace level (0 = no trace)
+:help         - displays the commands
+:quit         - leave the repl
+`
+
+const (
+	prompt     = "?- "
+	contPrompt = "|  "
+)
+
+// ###
+func replCmd() {
+	repl := flag.NewFlagSet("repl", flag.ContinueOnError)
+	orderFlag := repl.String("order", "id", "the order of the printed constraints: id, term or none")
+
+	if err := repl.Parse(os.Args[2:]); err != nil {
+		log.Fatal(err)
+	}
+	order, err := chr.ParseStoreOrder(*orderFlag)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	rs := chr.MakeRuleStore()
+	rs.Order = order
+
+	switch repl.NArg() {
+	case 0:
+	case 1:
+		replLoad(rs, repl.Args()[0])
+	default:
+		log.Fatal(fmt.Errorf("incorrect number of arguments after the command flags; should be 0 or 1, naming the input file\n"))
+		return
+	}
+
+	in := bufio.NewScanner(os.Stdin)
+	entry := ""
+	fmt.Print(prompt)
+	for in.Scan() {
+		line := strings.TrimSpace(in.Text())
+		if entry == "" && strings.HasPrefix(line, ":") {
+			if !replCommand(rs, line) {
+				return
+			}
+			fmt.Print(prompt)
+			continue
+		}
+		if line != "" {
+			entry = entry + line + "\n"
+		}
+		if entry == "" || !strings.HasSuffix(line, ".") {
+			if entry == "" {
+				fmt.Print(prompt)
+			} else {
+				fmt.Print(contPrompt)
+			}
+			continue
+		}
+		replEval(rs, entry)
+		entry = ""
+		fmt.Print(prompt)
+	}
+	fmt.Println()
+	if err := in.Err(); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// replCommand executes the repl-command cmd, the result is false for ':quit'
+func replCommand(rs *chr.RuleStore, cmd string) bool {
+	args := strings.Fields(cmd)
+	switch args[0] {
+	case ":load", ":l":
+		if len(args) != 2 {
+			fmt.Println("usage: :load <file>")
+			return true
+		}
+		replLoad(rs, args[1])
+	case ":store", ":s":
+		replWriteStore(rs)
+	case ":rules", ":r":
+		chr.WriteCHRRules(rs, os.Stdout)
+	case ":clear", ":c":
+		chr.ClearCHRStore(rs)
+	case ":trace", ":t":
+		if len(args) != 2 {
+			fmt.Printf("trace level: %d\n", rs.Trace.Level)
+			return true
+		}
+		n, err := strconv.Atoi(args[1])
+		if err != nil || n < 0 {
+			fmt.Printf("trace level must be a number >= 0, not: %s\n", args[1])
+			return true
+		}
+		rs.Trace.Level = n
+	case ":help", ":h", ":?":
+		fmt.Printf("%s\n", helpRepl)
+	case ":quit", ":q", ":exit":
+		return false
+	default:
+		fmt.Printf("unknown command: %s (':help' for help)\n", args[0])
+	}
+	return true
+}
+
+func replLoad(rs *chr.RuleStore, fileName string) {
+	src, err := ioutil.ReadFile(fileName)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s\n", err)
+		return
+	}
+	chr.ClearCHRStore(rs)
+	replEval(rs, string(src))
+}
+
+func replEval(rs *chr.RuleStore, src string) {
+	solved, ok := rs.AddStringCHRRulesGoals(src)
+	if !ok || !solved {
+		return
+	}
+	replWriteStore(rs)
+	if rs.Result == chr.RFalse {
+		fmt.Println("(':clear' starts with an empty store)")
+	}
+}
+
+func replWriteStore(rs *chr.RuleStore) {
+	chr.WriteCHRStore(rs, os.Stdout)
+	if rs.Result != chr.RStore {
+		// no new line after 'true', 'false' or 'No rule fired'
+		fmt.Println()
+	}
+}
@@ -0,0 +1,240 @@
+package main
+
+import (
+	"encoding/xml"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	chr "github.com/hfried/GoCHR/src/engine/CHR"
+)
+
+const helpTest = `
+usage: gochr test [-v] [-refined] [-junit output-file] [dir|input-file ...]
+
+Evaluates Constraint Handling Rules files as a test suite. The computed
+stores are compared with the '#result', '#store' and '#bistore' directives
+of the files. A directory is searched recursively for '.chr' files.
+If no directory or input-file is specified, the current directory is used.
+
+The -v flag reports also the passed checks.
+
+The -refined flag selects the refined operational semantics.
+
+The -junit flag specifies a file for a report in JUnit XML format.
+
+The exit status is 1, if a check or the parsing of a file failed.
+`
+
+type testFileResult struct {
+	name    string
+	checks  []*chr.CheckResult
+	parseOK bool
+	time    time.Duration
+}
+
+func (r *testFileResult) failed() int {
+	n := 0
+	for _, c := range r.checks {
+		if !c.OK {
+			n++
+		}
+	}
+	return n
+}
+
+// ###
+func testCmd() {
+	test := flag.NewFlagSet("test", flag.ContinueOnError)
+	verboseFlag := test.Bool("v", false, "report the passed checks")
+	junitFlag := test.String("junit", "", "the filename of the JUnit XML report")
+	refinedFlag := test.Bool("refined", false, "use the refined operational semantics")
+
+	if err := test.Parse(os.Args[2:]); err != nil {
+		log.Fatal(err)
+	}
+
+	args := test.Args()
+	if len(args) == 0 {
+		args = []string{"."}
+	}
+	files, err := chrFiles(args)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	results := []*testFileResult{}
+	failed := false
+	for _, name := range files {
+		r := runTestFile(name, *refinedFlag)
+		results = append(results, r)
+		if !r.parseOK || r.failed() != 0 {
+			failed = true
+		}
+		writeTestFileResult(r, *verboseFlag)
+	}
+
+	if *junitFlag != "" {
+		if err := writeJUnit(*junitFlag, results); err != nil {
+			log.Fatal(err)
+		}
+	}
+	if failed {
+		fmt.Printf("FAIL\n")
+		os.Exit(1)
+	}
+	fmt.Printf("PASS\n")
+}
+
+// chrFiles - all '.chr' files in the directories and the files of args
+func chrFiles(args []string) ([]string, error) {
+	files := []string{}
+	for _, arg := range args {
+		info, err := os.Stat(arg)
+		if err != nil {
+			return nil, err
+		}
+		if !info.IsDir() {
+			files = append(files, arg)
+			continue
+		}
+		err = filepath.Walk(arg, func(path string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			if !info.IsDir() && filepath.Ext(path) == ".chr" {
+				files = append(files, path)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return files, nil
+}
+
+func runTestFile(name string, refined bool) *testFileResult {
+	r := &testFileResult{name: name}
+	inFile, err := os.Open(name)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s\n", err)
+		return r
+	}
+	defer inFile.Close()
+	start := time.Now()
+	rs := chr.MakeRuleStore()
+	rs.Order = chr.OrderId
+	if refined {
+		rs.Mode = chr.ModeRefined
+	}
+	r.checks, r.parseOK = rs.CheckFileCHRRulesGoals(inFile)
+	r.time = time.Since(start)
+	return r
+}
+
+func writeTestFileResult(r *testFileResult, verbose bool) {
+	switch {
+	case !r.parseOK:
+		fmt.Printf("--- FAIL: %s (parse error)\n", r.name)
+	case r.failed() != 0:
+		fmt.Printf("--- FAIL: %s (%d of %d checks failed)\n", r.name, r.failed(), len(r.checks))
+	case len(r.checks) == 0:
+		fmt.Printf("?    %s [no expectations]\n", r.name)
+		return
+	default:
+		fmt.Printf("ok   %s (%d checks, %v)\n", r.name, len(r.checks), r.time)
+	}
+	for _, c := range r.checks {
+		if c.OK {
+			if verbose {
+				fmt.Printf("    ok   line %d: goal %s #%s\n", c.Pos.Line, c.Goals, c.Directive)
+			}
+			continue
+		}
+		fmt.Printf("    FAIL line %d: goal %s #%s\n", c.Pos.Line, c.Goals, c.Directive)
+		fmt.Printf("        expected:   %s\n", c.Expected)
+		fmt.Printf("        computed:   %s\n", c.Computed)
+		if len(c.Missing) != 0 {
+			fmt.Printf("        missing:    %s\n", strings.Join(c.Missing, ", "))
+		}
+		if len(c.Unexpected) != 0 {
+			fmt.Printf("        unexpected: %s\n", strings.Join(c.Unexpected, ", "))
+		}
+	}
+}
+
+// JUnit XML
+
+type junitTestSuites struct {
+	XMLName xml.Name         `xml:"testsuites"`
+	Suites  []junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name     string          `xml:"name,attr"`
+	Tests    int             `xml:"tests,attr"`
+	Failures int             `xml:"failures,attr"`
+	Errors   int             `xml:"errors,attr"`
+	Time     string          `xml:"time,attr"`
+	Cases    []junitTestCase `xml:"testcase"`
+}
+
+type junitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	Failure   *junitFailure `xml:"failure,omitempty"`
+	Error     *junitFailure `xml:"error,omitempty"`
+}
+
+type junitFailure struct {
+	Message string `xml:"message,attr"`
+	Text    string `xml:",chardata"`
+}
+
+func writeJUnit(fileName string, results []*testFileResult) error {
+	suites := junitTestSuites{}
+	for _, r := range results {
+		suite := junitTestSuite{Name: r.name, Tests: len(r.checks), Failures: r.failed(),
+			Time: fmt.Sprintf("%.3f", r.time.Seconds())}
+		if !r.parseOK {
+			suite.Errors = 1
+			suite.Tests++
+			suite.Cases = append(suite.Cases, junitTestCase{Name: "parse", ClassName: r.name,
+				Error: &junitFailure{Message: "parse error"}})
+		}
+		for _, c := range r.checks {
+			tc := junitTestCase{Name: fmt.Sprintf("line %d: %s #%s", c.Pos.Line, c.Goals, c.Directive),
+				ClassName: r.name}
+			if !c.OK {
+				text := fmt.Sprintf("expected:   %s\ncomputed:   %s\n", c.Expected, c.Computed)
+				if len(c.Missing) != 0 {
+					text += fmt.Sprintf("missing:    %s\n", strings.Join(c.Missing, ", "))
+				}
+				if len(c.Unexpected) != 0 {
+					text += fmt.Sprintf("unexpected: %s\n", strings.Join(c.Unexpected, ", "))
+				}
+				tc.Failure = &junitFailure{Message: "#" + c.Directive + " failed", Text: text}
+			}
+			suite.Cases = append(suite.Cases, tc)
+		}
+		suites.Suites = append(suites.Suites, suite)
+	}
+	out, err := os.Create(fileName)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+	fmt.Fprintf(out, "%s", xml.Header)
+	enc := xml.NewEncoder(out)
+	enc.Indent("", "  ")
+	if err := enc.Encode(suites); err != nil {
+		return err
+	}
+	fmt.Fprintf(out, "\n")
+	return nil
+}
@@ -9,8 +9,10 @@
 package chr
 
 import (
-	"fmt"
+	"context"
+	"errors"
 	"math/big"
+	"time"
 
 	//	. "github.com/hfried/GoCHR/src/engine/parser"
 	. "github.com/hfried/GoCHR/src/engine/terms"
@@ -28,59 +30,11 @@ type argCHR struct {
 	listArg  CList
 	varArg   CList
 	noArg    CList
+	idx      []*argIndex // hash indexes on argument positions, only in the CHR-store
 }
 
 type store map[string]*argCHR
 
-//type EnvMap struct {
-//	inBinding   Bindings
-//	outBindings map[int]*EnvMap
-//}
-
-const EnvCache = 1
-
-type (
-	KeepCall int
-	KeepReq  int
-)
-
-const (
-	C_noLast KeepCall = iota
-	C_last
-)
-
-func keepCall2str(k KeepCall) string {
-	switch k {
-	case C_noLast:
-		return "no-Last"
-	case C_last:
-		return "last"
-	}
-	return "last-Fail"
-}
-
-func keepReq2str(r KeepReq) string {
-	switch r {
-	case R_noNews:
-		return "no-News"
-	case R_nextNew:
-		return "next-New"
-	}
-	return "next"
-}
-
-const (
-	R_noNews KeepReq = iota
-	R_nextNew
-	R_next
-)
-
-type keepMem struct {
-	// 	startIdx, curIdx, endIdx, lenOld, lenNew int
-	startIdx, curIdx, endIdx int
-	idxState                 KeepCall
-}
-
 type ruleIdx struct {
 	rule *chrRule
 	idx  int
@@ -88,44 +42,109 @@ type ruleIdx struct {
 
 type predicateRule map[string][]*ruleIdx
 
+// a compiled CHR-rule; the rule is not changed by a solver run,
+// the state of the rule in a run is the ruleState in the rule store
 type chrRule struct {
 	name     string
 	id       int
+	pos      int          // position in the rules of the rule store, index of the rule state
+	delHead  CList        // removed constraints
+	keepHead CList        // kept constraint
+	guard    CList        // built-in constraint
+	body     List         // add CHR and built-in constraint
+	join     []joinStep   // join order of the heads
+	occJoin  [][]joinStep // join order of the heads, beginning with the active head i
+}
+
+// the state of a rule in a solver run
+type ruleState struct {
 	isOn     bool
 	wasOn    bool
-	his      history
-	delHead  CList      // removed constraints
-	keepHead CList      // kept constraint
-	keepEnv  []*keepMem //
-	keepReq  KeepReq
-	guard    CList // built-in constraint
-	body     List  // add CHR and built-in constraint
-	eMap     *EnvMap
+	his      history        // propagation history, only for rules without del-head
+	tried    map[string]int // rules with del-head: tried candidates of the last head
+	triedBis int            // the version of the built-in store, when tried was filled
 }
 
+// RuleStore - the rules of a program and a session, which runs them; the rules
+// of a rule store are changed by AddRule, RegisterBuiltin and the parsed rules
 type RuleStore struct {
-	Result         resultType
-	CHRruleStore   []*chrRule
+	Session
+}
+
+// Session - the CHR- and built-in-store, the rule states and the propagation
+// histories of the solver runs of a program; the program is not changed by a
+// session. A session is used by one goroutine at a time, see Program.NewSession.
+type Session struct {
+	prog           *Program // the rules, read-only
+	Result         ResultType
 	QueryVars      Vars
 	QueryStore     List
 	CHRstore       store
 	BuiltInStore   store
-	nextRuleId     int // = 0
 	emptyBinding   Bindings
 	RenameRuleVars *big.Int
 	chrCounter     *big.Int
-	pred2rule      predicateRule
+	checkResults   *[]*CheckResult     // if != nil, collect the results of '#result', '#store' and '#bistore'
+	MaxRuleFirings int                 // maximal number of rule firings of a solver run, 0 = DefaultMaxRuleFirings
+	Timeout        time.Duration       // maximal duration of a solver run, 0 = no time limit
+	Err            error               // != nil, if the last solver run stopped before the goals were solved
+	ruleFirings    int                 // number of rule firings of the current solver run
+	Mode           SolverMode          // ModeDefault or ModeRefined
+	Order          StoreOrder          // order of the constraints in the output of the stores
+	hisIndex       map[string][]hisRef // constraint Id -> entries of the propagation histories
+	activeId       *big.Int            // constraints with an Id >= activeId are not activated (refined mode)
+	evalErr        error               // first error of a built-in function in the current solver run
+	disjuncts      []Compound          // disjunctions of the goals and bodies, not split up to now
+	choices        []*choicePoint      // choice points of the split disjunctions and of the rule choices
+	ruleChoices    bool                // the other applicable rules of a fired rule are alternatives, see Solutions
+	Trace          TraceWriter         // level and writer of the trace of the solver runs
+	Tracer         Tracer              // receiver of the trace events of the solver runs, nil = no events
+	Stats          *Stats              // statistics of the solver runs, nil = no statistics
+	renamings      int64               // number of the renamings of rule variables since the last InitStore/ClearCHRStore
+	states         []ruleState         // states of the rules, index: chrRule.pos
+	fd             bool                // finite domain constraints were added, see fdPropagate
+	herbrand       *herbrand           // the bindings and the occurrences of the variables of the CHR-store
+	chrSize        int                 // number of the constraints of the CHR-store, see Stats
+	biVersion      int                 // changed by each added, deleted or rewritten built-in constraint, see matchRule
+	lin            *linTableau         // the linear constraints of the built-in store, see linearTableau
+
+	// justifications, see Explain
+	Justify     bool                   // record the derivations of the constraints of the solver runs
+	derivations map[string]*Derivation // constraint Id -> derivation, if Justify
+	cause       *derivCause            // the cause of the constraints, which are added next, if Justify
 }
 
-type resultType int
+const DefaultMaxRuleFirings = 100000
+
+// errors of a solver run, stopped by a limit; the partial store is kept
+var (
+	ErrMaxRuleFirings = errors.New("maximal number of rule firings exceeded")
+	ErrTimeout        = errors.New("time-out")
+)
+
+type ResultType int
 
 const (
-	REmpty resultType = iota
+	REmpty ResultType = iota
 	RStore
 	RTrue
 	RFalse
 )
 
+func (r ResultType) String() string {
+	switch r {
+	case REmpty:
+		return "empty"
+	case RStore:
+		return "store"
+	case RTrue:
+		return "true"
+	case RFalse:
+		return "false"
+	}
+	return "unknown"
+}
+
 var bigOne = big.NewInt(1)
 
 // init, add and read CHR- and Build-In-store
@@ -137,39 +156,41 @@ func MakeRuleStore() *RuleStore {
 	return rs
 }
 
-func makeKeepEnv(kl CList) []*keepMem {
-	kEnv := make([]*keepMem, len(kl))
-	for idx, _ := range kEnv {
-		kEnv[idx] = &keepMem{}
-	}
-	return kEnv
-}
-
 func (rs *RuleStore) AddRule(name string, keep []string, del []string, guard []string, body []string) error {
 
 	cKeepList, cDelList, cGuardList, bodyList, err := parseRule(name, keep, del, guard, body)
 
 	if err == nil {
 
-		r := &chrRule{name: name, id: rs.nextRuleId,
+		r := &chrRule{name: name, id: rs.prog.nextRuleId,
 			delHead:  cDelList,
 			keepHead: cKeepList,
-			keepEnv:  makeKeepEnv(cKeepList),
 			guard:    cGuardList,
-			body:     bodyList,
-			eMap:     &EnvMap{InBinding: rs.emptyBinding, OutBindings: map[int]*EnvMap{}},
-			isOn:     false,
-			wasOn:    true}
-		TraceHeadln(3, 3, " OFF rule: ", name, " (AddRule) ")
-		rs.CHRruleStore = append(rs.CHRruleStore, r)
-
-		addRuleToPred2rule(rs, r)
-		rs.nextRuleId++
+			body:     bodyList}
+		rs.Trace.Headln(3, 3, " OFF rule: ", name, " (AddRule) ")
+		appendRule(rs, r)
+		rs.prog.nextRuleId++
 	}
 	return err
 }
+
+// appendRule adds the rule r to the rules of the rule store and compiles it
+func appendRule(rs *RuleStore, r *chrRule) {
+	r.pos = len(rs.prog.rules)
+	rs.prog.rules = append(rs.prog.rules, r)
+	rs.states = append(rs.states, ruleState{isOn: false, wasOn: true})
+	addRuleToPred2rule(rs, r)
+	hideStdlib(rs, r)
+	compileRule(rs, r)
+}
+
+// state - the state of the rule r in the current solver run
+func (rs *Session) state(r *chrRule) *ruleState {
+	return &rs.states[r.pos]
+}
+
 func addRuleToPred2rule(rs *RuleStore, r *chrRule) {
-	p2r := rs.pred2rule
+	p2r := rs.prog.pred2rule
 
 	del := r.delHead
 	keep := r.keepHead
@@ -192,9 +213,9 @@ func addRuleToPred2rule(rs *RuleStore, r *chrRule) {
 				}
 			}
 		} else {
-			r.isOn = true
-			r.wasOn = true
-			TraceHeadln(3, 3, " ON rule: ", r.name, " (variable in del) ")
+			rs.state(r).isOn = true
+			rs.state(r).wasOn = true
+			rs.Trace.Headln(3, 3, " ON rule: ", r.name, " (variable in del) ")
 		}
 	}
 
@@ -216,23 +237,24 @@ func addRuleToPred2rule(rs *RuleStore, r *chrRule) {
 				}
 			}
 		} else {
-			r.isOn = true
-			r.wasOn = true
-			TraceHeadln(3, 3, " ON rule: ", r.name, " (variable in keep) ")
+			rs.state(r).isOn = true
+			rs.state(r).wasOn = true
+			rs.Trace.Headln(3, 3, " ON rule: ", r.name, " (variable in keep) ")
 		}
 	}
 
 }
 
-func (rs *RuleStore) Infer(goals []string) (bool, []string, error) {
+func (rs *Session) Infer(goals []string) (bool, []string, error) {
 	cGoals, err := parseGoals(goals)
 	if err == nil {
 		// fmt.Printf("** parseGoals OK\n")
-		ClearCHRStore(rs)
+		clearSession(rs)
 		for _, g := range cGoals {
+			addQuery(rs, g)
 			addRefConstraintToStore(rs, g)
 		}
-		CHRsolver(rs)
+		err = chrSolver(context.Background(), rs)
 
 		switch rs.Result {
 		case REmpty:
@@ -244,30 +266,11 @@ func (rs *RuleStore) Infer(goals []string) (bool, []string, error) {
 		}
 		result := []string{}
 		// default: Result == RStore
-		for _, aChr := range rs.CHRstore {
-			for _, con := range aChr.varArg {
-				if con != nil && !con.IsDeleted {
-					result = append(result, con.String())
-				}
-			}
-			for _, con := range aChr.noArg {
-				if con != nil && !con.IsDeleted {
-					result = append(result, con.String())
-				}
-			}
+		for _, con := range sortedStore(rs, rs.CHRstore) {
+			result = append(result, con.String())
 		}
-
-		for _, aChr := range rs.BuiltInStore {
-			for _, con := range aChr.varArg {
-				if con != nil && !con.IsDeleted {
-					result = append(result, con.String())
-				}
-			}
-			for _, con := range aChr.noArg {
-				if con != nil && !con.IsDeleted {
-					result = append(result, con.String())
-				}
-			}
+		for _, con := range sortedStore(rs, rs.BuiltInStore) {
+			result = append(result, con.String())
 		}
 		return true, result, err
 	}
@@ -276,54 +279,115 @@ func (rs *RuleStore) Infer(goals []string) (bool, []string, error) {
 }
 
 func InitStore(rs *RuleStore) {
-	rs.Result = REmpty
-	InitRenamingVariables()
-	v := NewVariable("")
-	rs.emptyBinding = &BindEle{Var: v, T: nil, Next: nil}
-	rs.chrCounter = big.NewInt(0)
-	rs.nextRuleId = 0
-	rs.CHRruleStore = []*chrRule{}
-	rs.CHRstore = store{}
-	rs.BuiltInStore = store{}
-	rs.QueryStore = List{}
-	rs.QueryVars = Vars{}
-	rs.pred2rule = predicateRule{}
+	var bi builtins
+	if rs.prog != nil {
+		bi = rs.prog.builtins
+	}
+	rs.prog = newProgram()
+	// keep the registered built-in functions, but not the standard built-ins hidden by the old rules
+	for key, fn := range bi {
+		if fn != nil {
+			if rs.prog.builtins == nil {
+				rs.prog.builtins = builtins{}
+			}
+			rs.prog.builtins[key] = fn
+		}
+	}
+	clearSession(&rs.Session)
 }
 
 func ClearCHRStore(rs *RuleStore) {
+	clearSession(&rs.Session)
+}
+
+// clearSession clears the stores, the rule states and the propagation histories of the session rs
+func clearSession(rs *Session) {
 	rs.Result = REmpty
-	InitRenamingVariables()
+	rs.renamings = 0
+	rs.ruleFirings = 0
+	rs.derivations = nil
+	rs.cause = nil
+	if rs.emptyBinding == nil {
+		v := NewVariable("")
+		rs.emptyBinding = &BindEle{Var: v, T: nil, Next: nil}
+	}
 	rs.chrCounter = big.NewInt(0)
 	rs.CHRstore = store{}
+	rs.chrSize = 0
 	rs.BuiltInStore = store{}
+	rs.lin = nil
 	rs.QueryStore = List{}
 	rs.QueryVars = Vars{}
-	// clear EMaps
-	for _, rule := range rs.CHRruleStore {
-		rule.eMap = &EnvMap{InBinding: rs.emptyBinding, OutBindings: map[int]*EnvMap{}}
-		rule.isOn = false
-		TraceHeadln(3, 3, " OFF rule: ", rule.name, " (Clear Store) ")
-		rule.wasOn = true
-		for _, keepIdx := range rule.keepEnv {
-			keepIdx.startIdx = 0
-			keepIdx.curIdx = 0
-			keepIdx.endIdx = 0
-		}
-		rule.keepReq = R_noNews
+	rs.hisIndex = map[string][]hisRef{}
+	rs.activeId = big.NewInt(0)
+	rs.disjuncts = nil
+	rs.fd = false
+	rs.herbrand = newHerbrand()
+	if len(rs.states) != len(rs.prog.rules) {
+		rs.states = make([]ruleState, len(rs.prog.rules))
+	}
+	for _, rule := range rs.prog.rules {
+		rs.Trace.Headln(3, 3, " OFF rule: ", rule.name, " (Clear Store) ")
+		rs.states[rule.pos] = ruleState{isOn: false, wasOn: true, his: history{}}
+	}
+}
+
+// addQuery adds the goal g to the query store and the new variables
+// of g to the query variables
+func addQuery(rs *Session, g *Compound) {
+	rs.QueryStore = append(rs.QueryStore, *g)
+	for _, v := range g.OccurVars() {
+		found := false
+		for _, qv := range rs.QueryVars {
+			if EqVars(v, qv) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			rs.QueryVars = append(rs.QueryVars, v)
+		}
 	}
 }
 
+// newRenaming - a new index for the renaming of the variables of a rule,
+// unique in the rule store up to the next InitStore or ClearCHRStore
+func newRenaming(rs *Session) *big.Int {
+	rs.renamings++
+	return big.NewInt(rs.renamings)
+}
+
 func NewArgCHR() *argCHR {
 	return &argCHR{atomArg: map[string]CList{},
 		boolArg: CList{}, intArg: CList{}, floatArg: CList{}, strArg: CList{},
 		compArg: map[string]CList{}, listArg: CList{}, varArg: CList{}, noArg: CList{}}
 }
 
-func delConstraint(g *Compound, rs *RuleStore) {
-	delGoal1(g, rs.CHRstore)
+func delConstraint(g *Compound, rs *Session) {
+	if rs.Tracer != nil {
+		rs.event(Event{Kind: EventConstraintDeleted, Constraint: g}, nil)
+	}
+	markDeleted(rs, g)
+	delGoal1(rs, g, rs.CHRstore)
+	gcHistory(rs, g)
+}
+
+// addCHRGoal adds the constraint g to the CHR-store and counts it in rs.chrSize
+func addCHRGoal(rs *Session, g *Compound) {
+	addGoal1(rs, g, rs.CHRstore)
+	rs.chrSize++
+}
+
+// markDeleted marks the CHR-constraint g as deleted, the constraint is not
+// counted in rs.chrSize any more
+func markDeleted(rs *Session, g *Compound) {
+	if !g.IsDeleted {
+		g.IsDeleted = true
+		rs.chrSize--
+	}
 }
 
-func delGoal1(g *Compound, s store) {
+func delGoal1(rs *Session, g *Compound, s store) {
 
 	aArg, ok := s[g.Functor]
 	if !ok {
@@ -332,16 +396,16 @@ func delGoal1(g *Compound, s store) {
 	for idx, val := range aArg.varArg {
 		if val == g {
 			aArg.varArg[idx] = nil
-			if CHRtrace != 0 {
+			if rs.Trace.Level != 0 {
 				switch len(g.Args) {
 				case 0:
-					TraceHeadln(3, 3, "  delGoal1: '", g.Functor, "'() ")
+					rs.Trace.Headln(3, 3, "  delGoal1: '", g.Functor, "'() ")
 				case 1:
-					TraceHeadln(3, 3, "  delGoal1: Functor:'", g.Functor, "'(", g.Args[0], ")")
+					rs.Trace.Headln(3, 3, "  delGoal1: Functor:'", g.Functor, "'(", g.Args[0], ")")
 				case 2:
-					TraceHeadln(3, 3, "  delGoal1: Functor:'", g.Functor, "'(", g.Args[0], ",", g.Args[1], ")")
+					rs.Trace.Headln(3, 3, "  delGoal1: Functor:'", g.Functor, "'(", g.Args[0], ",", g.Args[1], ")")
 				default:
-					TraceHeadln(3, 3, "  delGoal1: Functor:'", g.Functor, "'(", g.Args[0], ",", g.Args[1], ", ... )")
+					rs.Trace.Headln(3, 3, "  delGoal1: Functor:'", g.Functor, "'(", g.Args[0], ",", g.Args[1], ", ... )")
 				}
 			}
 			return
@@ -349,16 +413,16 @@ func delGoal1(g *Compound, s store) {
 	}
 }
 
-func addGoal1(g *Compound, s store) {
+func addGoal1(rs *Session, g *Compound, s store) {
 	switch len(g.Args) {
 	case 0:
-		TraceHeadln(3, 3, "  addGoal1: '", g.Functor, "'() ")
+		rs.Trace.Headln(3, 3, "  addGoal1: '", g.Functor, "'() ")
 	case 1:
-		TraceHeadln(3, 3, "  addGoal1: Functor:'", g.Functor, "'(", g.Args[0], ")")
+		rs.Trace.Headln(3, 3, "  addGoal1: Functor:'", g.Functor, "'(", g.Args[0], ")")
 	case 2:
-		TraceHeadln(3, 3, "  addGoal1: Functor:'", g.Functor, "'(", g.Args[0], ",", g.Args[1], ")")
+		rs.Trace.Headln(3, 3, "  addGoal1: Functor:'", g.Functor, "'(", g.Args[0], ",", g.Args[1], ")")
 	default:
-		TraceHeadln(3, 3, "  addGoal1: Functor:'", g.Functor, "'(", g.Args[0], ",", g.Args[1], ", ... )")
+		rs.Trace.Headln(3, 3, "  addGoal1: Functor:'", g.Functor, "'(", g.Args[0], ",", g.Args[1], ", ... )")
 	}
 
 	aArg, ok := s[g.Functor]
@@ -382,7 +446,7 @@ func addGoal1(g *Compound, s store) {
 		aArg.atomArg[string(arg0.(Atom))] = append(cl, g)
 	case BoolType:
 		aArg.boolArg = append(aArg.boolArg, g)
-	case IntType:
+	case IntType, BigIntType, RationalType:
 		aArg.intArg = append(aArg.intArg, g)
 	case FloatType:
 		aArg.floatArg = append(aArg.floatArg, g)
@@ -398,40 +462,69 @@ func addGoal1(g *Compound, s store) {
 		aArg.listArg = append(aArg.listArg, g)
 	}
 	aArg.varArg = append(aArg.varArg, g) // a variable match to all types
+	for _, ix := range aArg.idx {
+		ix.add(g)
+	}
 }
 
-func addConstraintToStore(rs *RuleStore, g Compound) {
+func addConstraintToStore(rs *Session, g Compound) {
 	addRefConstraintToStore(rs, &g)
 }
-func addRefConstraintToStore(rs *RuleStore, g *Compound) {
-	// TraceHeadln(3, 3, " a) Counter %v \n", chrCounter)
+func addRefConstraintToStore(rs *Session, g *Compound) {
+	// rs.Trace.Headln(3, 3, " a) Counter %v \n", chrCounter)
+	if isDisjunction(*g) {
+		addDisjunction(rs, *g)
+		return
+	}
+	if splitFDList(rs, g) {
+		return
+	}
+	fd := isFDConstraint(rs, g)
+	rs.fd = rs.fd || fd
+	if g.Prio == 0 && !fd {
+		rs.herbrand.index(g)
+	}
 	g.Id = rs.chrCounter
 	rs.chrCounter = new(big.Int).Add(rs.chrCounter, bigOne)
-	// TraceHeadln(3, 3, " b) Counter++ %v , Id: %v \n", chrCounter, g.Id)
-	if g.Prio == 0 {
-		addGoal1(g, rs.CHRstore)
-		p2r := rs.pred2rule
+	// rs.Trace.Headln(3, 3, " b) Counter++ %v , Id: %v \n", chrCounter, g.Id)
+	if rs.Tracer != nil {
+		rs.event(Event{Kind: EventConstraintAdded, Constraint: g}, nil)
+	}
+	if rs.Justify {
+		justify(rs, g)
+	}
+	if g.Prio == 0 && !fd {
+		if _, ok := rs.CHRstore[g.Functor]; !ok {
+			rs.CHRstore[g.Functor] = newIndexedArgCHR(rs, g.Functor)
+		}
+		addCHRGoal(rs, g)
+		p2r := rs.prog.pred2rule
 		ruleSlice, _ := p2r[g.Functor]
 		for _, rIdx := range ruleSlice {
-			rIdx.rule.isOn = true
-			TraceHeadln(3, 3, " ON rule: ", rIdx.rule.name, " (Add Constraint to Store) ")
+			rs.state(rIdx.rule).isOn = true
+			rs.Trace.Headln(3, 3, " ON rule: ", rIdx.rule.name, " (Add Constraint to Store) ")
 		}
 	} else {
-		addGoal1(g, rs.BuiltInStore)
+		rs.biVersion++
+		addGoal1(rs, g, rs.BuiltInStore)
+		linearAdd(rs, *g)
 	}
 }
 
-func readProperConstraintsFromCHR_Store(rs *RuleStore, t *Compound, env Bindings) CList {
+func readProperConstraintsFromCHR_Store(rs *Session, t *Compound, env Bindings) CList {
 	argAtt, ok := rs.CHRstore[t.Functor]
 	if ok {
+		if chr, ok := readIndexedConstraints(t, argAtt, env); ok {
+			return chr
+		}
 		chr := readProperConstraintsFromStore(t, argAtt, env)
-		// TraceHeadln(3, 3, " ++> read ", t.Functor, " constraint(", len(chr), ") = ", chr)
+		// rs.Trace.Headln(3, 3, " ++> read ", t.Functor, " constraint(", len(chr), ") = ", chr)
 		return chr
 	}
 	return CList{}
 }
 
-func readProperConstraintsFromBI_Store(rs *RuleStore, t *Compound, env Bindings) CList {
+func readProperConstraintsFromBI_Store(rs *Session, t *Compound, env Bindings) CList {
 	argAtt, ok := rs.BuiltInStore[t.Functor]
 	if ok {
 		return readProperConstraintsFromStore(t, argAtt, env)
@@ -441,7 +534,7 @@ func readProperConstraintsFromBI_Store(rs *RuleStore, t *Compound, env Bindings)
 
 func readProperConstraintsFromStore(t *Compound, aAtt *argCHR, env Bindings) CList {
 	//	if t.Functor == "safety" {
-	//		TraceHeadln(3, 3, "  readProperConstrain: ", t.Functor)
+	//		rs.Trace.Headln(3, 3, "  readProperConstrain: ", t.Functor)
 	//	}
 	args := t.Args
 	l := len(args)
@@ -454,7 +547,7 @@ func readProperConstraintsFromStore(t *Compound, aAtt *argCHR, env Bindings) CLi
 		t2, ok := GetBinding(arg0.(Variable), env)
 		if ok {
 			//			if t.Functor == "safety" {
-			//				TraceHeadln(3, 3, "  Binding: ", t2)
+			//				rs.Trace.Headln(3, 3, "  Binding: ", t2)
 			//			}
 			arg0 = t2
 			argTyp = arg0.Type()
@@ -467,14 +560,14 @@ func readProperConstraintsFromStore(t *Compound, aAtt *argCHR, env Bindings) CLi
 	case AtomType:
 		cl, ok := aAtt.atomArg[string(arg0.(Atom))]
 		//		if t.Functor == "safety" {
-		//			TraceHeadln(3, 3, "  arg0 == AtomType: ", string(arg0.(Atom)), " OK:", ok, "CL: ", cl)
+		//			rs.Trace.Headln(3, 3, "  arg0 == AtomType: ", string(arg0.(Atom)), " OK:", ok, "CL: ", cl)
 		//		}
 		if ok {
 			return cl
 		}
 	case BoolType:
 		return aAtt.boolArg
-	case IntType:
+	case IntType, BigIntType, RationalType:
 		return aAtt.intArg
 	case FloatType:
 		return aAtt.floatArg
@@ -488,19 +581,16 @@ func readProperConstraintsFromStore(t *Compound, aAtt *argCHR, env Bindings) CLi
 	case ListType:
 		return aAtt.listArg
 	case VariableType:
-		if t.Functor == "safety" {
-			TraceHeadln(3, 3, "  arg0 == VariableType: ", arg0.(Variable), " aAtt.varArg: ", aAtt.varArg)
-		}
 		return aAtt.varArg
 	}
 	return CList{}
 }
 
-func readProperKeepConstraintsFromCHR_Store(rs *RuleStore, t *Compound) CList {
+func readProperKeepConstraintsFromCHR_Store(rs *Session, t *Compound) CList {
 	argAtt, ok := rs.CHRstore[t.Functor]
 	if ok {
 		chr := readProperKeepConstraintsFromStore(t, argAtt)
-		// TraceHeadln(3, 3, " ++> read ", t.Functor, " constraint(", len(chr), ") = ", chr)
+		// rs.Trace.Headln(3, 3, " ++> read ", t.Functor, " constraint(", len(chr), ") = ", chr)
 		return chr
 	}
 	return CList{}
@@ -508,7 +598,7 @@ func readProperKeepConstraintsFromCHR_Store(rs *RuleStore, t *Compound) CList {
 
 func readProperKeepConstraintsFromStore(t *Compound, aAtt *argCHR) CList {
 	//	if t.Functor == "safety" {
-	//		TraceHeadln(3, 3, "  readProperConstrain: ", t.Functor)
+	//		rs.Trace.Headln(3, 3, "  readProperConstrain: ", t.Functor)
 	//	}
 	args := t.Args
 	l := len(args)
@@ -521,7 +611,7 @@ func readProperKeepConstraintsFromStore(t *Compound, aAtt *argCHR) CList {
 	//		t2, ok := GetBinding(arg0.(Variable), env)
 	//		if ok {
 	//			//			if t.Functor == "safety" {
-	//			//				TraceHeadln(3, 3, "  Binding: ", t2)
+	//			//				rs.Trace.Headln(3, 3, "  Binding: ", t2)
 	//			//			}
 	//			arg0 = t2
 	//			argTyp = arg0.Type()
@@ -534,14 +624,14 @@ func readProperKeepConstraintsFromStore(t *Compound, aAtt *argCHR) CList {
 	case AtomType:
 		cl, ok := aAtt.atomArg[string(arg0.(Atom))]
 		//		if t.Functor == "safety" {
-		//			TraceHeadln(3, 3, "  arg0 == AtomType: ", string(arg0.(Atom)), " OK:", ok, "CL: ", cl)
+		//			rs.Trace.Headln(3, 3, "  arg0 == AtomType: ", string(arg0.(Atom)), " OK:", ok, "CL: ", cl)
 		//		}
 		if ok {
 			return cl
 		}
 	case BoolType:
 		return aAtt.boolArg
-	case IntType:
+	case IntType, BigIntType, RationalType:
 		return aAtt.intArg
 	case FloatType:
 		return aAtt.floatArg
@@ -555,168 +645,309 @@ func readProperKeepConstraintsFromStore(t *Compound, aAtt *argCHR) CList {
 	case ListType:
 		return aAtt.listArg
 	case VariableType:
-		if t.Functor == "safety" {
-			TraceHeadln(3, 3, "  arg0 == VariableType: ", arg0.(Variable), " aAtt.varArg: ", aAtt.varArg)
-		}
 		return aAtt.varArg
 	}
 	return CList{}
 }
 
-// old History
-
-type history [][]*big.Int
-
-// var History []idSequence
-
 // OccurVars
 // ---------
 
-var CurVarCounter *big.Int
-
 // CHR solver
 // ----------
 
 // Try all rules in 'CHRruleStore' with CHR-goals in CHR-store
 // until no rule fired.
 // CHRsolver used the trace- or no-trace function
-func CHRsolver(rs *RuleStore) {
+func CHRsolver(rs *Session) {
+	chrSolver(context.Background(), rs)
+}
+
+// chrSolver stops with an error, if the context ctx is done or
+// a limit of the rule store is exceeded. If no rule is applicable, the
+// next disjunction is split; if the result is false, the solver backtracks
+// to the next alternative of the last split disjunction.
+func chrSolver(ctx context.Context, rs *Session) error {
 
-	if CHRtrace != 0 {
+	if rs.Trace.Level != 0 {
 		printCHRStore(rs, "New goal:")
 	}
-	i := 0
-	ruleFound := true
-	if CHRtrace == 0 {
-		for ruleFound, i = true, 0; ruleFound && rs.Result != RFalse && i < 100000; i++ {
-			// for ruleFound := true; ruleFound; {
-			ruleFound = false
-			for _, rule := range rs.CHRruleStore {
-				if rule.isOn {
-					rs.RenameRuleVars = <-Counter
-					if pRuleFired(rs, rule) {
-						ruleFound = true
-						break
-					}
-					rule.isOn = false
-					// fmt.Printf("     OFF rule %s (Rule not fired 2) \n", rule.name)
-					// TraceHeadln(1, 1, " OFF rule: ", rule.name, " (Rule not fired2) ")
-				}
-			}
+	rs.choices = nil
+	err := searchCHR(ctx, rs)
+	rs.choices = nil
+	return err
+}
+
+// searchCHR solves the goals in the stores up to the first successful branch of the
+// search tree or up to the failure of all branches; the choice points of the open
+// branches are kept in rs.choices. The limits of the rule store apply to every call.
+func searchCHR(ctx context.Context, rs *Session) error {
+	maxFirings := rs.MaxRuleFirings
+	if maxFirings <= 0 {
+		maxFirings = DefaultMaxRuleFirings
+	}
+	var deadline time.Time
+	if rs.Timeout > 0 {
+		deadline = time.Now().Add(rs.Timeout)
+	}
+	var err error
+	rs.ruleFirings = 0
+	rs.evalErr = nil
+	var sr statsRun
+	if rs.Stats != nil {
+		sr = rs.Stats.startRun(rs)
+	}
+	for {
+		if rs.Mode == ModeRefined {
+			err = refinedCHRsolver(ctx, rs, maxFirings, deadline)
+		} else {
+			err = defaultCHRsolver(ctx, rs, maxFirings, deadline)
+		}
+		if err == nil {
+			err = rs.evalErr
+		}
+		if err == nil && rs.Result != RFalse && rs.fd && fdPropagate(rs) {
+			continue
+		}
+		if err == nil && rs.Result != RFalse && splitDisjunction(rs) {
+			continue
+		}
+		if reduceStore(rs) && err == nil {
+			continue
 		}
-	} else { // CHRtrace != 0
-		for ruleFound, i = true, 0; ruleFound && rs.Result != RFalse && i < 100000; i++ {
-			// for ruleFound := true; ruleFound; {
-			ruleFound = false
-			for _, rule := range rs.CHRruleStore {
+		if err == nil && rs.Result != RFalse && rs.fd && fdLabel(rs) {
+			continue
+		}
+		if err == nil && rs.Result == RFalse && backtrack(rs) {
+			continue
+		}
+		break
+	}
 
-				if rule.isOn {
-					rs.RenameRuleVars = <-Counter
+	if err == nil {
+		err = rs.evalErr
+	}
+	rs.Err = err
+	if rs.Stats != nil {
+		rs.Stats.endRun(rs, sr)
+	}
+	if err != nil {
+		rs.Trace.Headln(1, 1, "!!! ", err, " after ", rs.ruleFirings, " rule firings !!!")
+	}
 
-					TraceHeadln(2, 1, "trial rule ", rule.name, "(ID: ", rule.id, ") @ ", rule.keepHead.String(),
-						" \\ ", rule.delHead.String(), " <=> ", rule.guard.String(), " | ", rule.body.String(), ".")
+	if rs.Trace.Level > 1 {
+		printCHRStore(rs, "Result:")
+	}
+	return err
+}
 
-					if TraceRuleFired(rs, rule) {
-						TraceHeadln(1, 1, "rule ", rule.name, " fired (id: ", rule.id, ")")
-						ruleFound = true
-						break
-					}
-					rule.isOn = false
-					TraceHeadln(1, 1, " OFF rule: ", rule.name, " (Rule not fired) ")
-					TraceHeadln(2, 1, "rule ", rule.name, " NOT fired (id: ", rule.id, ")")
-					rule.isOn = false
-					// fmt.Printf("     OFF rule %s (Rule not fired 2) \n", rule.name)
-					// TraceHeadln(1, 1, " OFF rule: ", rule.name, " (Rule not fired2) ")
-				} else {
-					TraceHeadln(2, 1, "rule is OFF: ", rule.name)
-				}
+// defaultCHRsolver tries the rules in textual order, until no rule fired
+func defaultCHRsolver(ctx context.Context, rs *Session, maxFirings int, deadline time.Time) (err error) {
+	for ruleFound := true; ruleFound && rs.Result != RFalse; {
+		if err = checkLimits(ctx, rs, maxFirings, deadline); err != nil {
+			break
+		}
+		ruleFound = false
+		for _, rule := range rs.prog.rules {
+			if !rs.state(rule).isOn {
+				rs.Trace.Headln(2, 1, "rule is OFF: ", rule.name)
+				continue
 			}
-
-			if ruleFound {
-				printCHRStore(rs, "Intermediary result:")
+			rs.RenameRuleVars = newRenaming(rs)
+			if rs.Trace.On(2) {
+				rs.Trace.Headln(2, 1, "trial rule ", rule.name, "(ID: ", rule.id, ") @ ", rule.keepHead,
+					" \\ ", rule.delHead, " <=> ", rule.guard, " | ", rule.body, ".")
+			}
+			if rs.Tracer != nil {
+				rs.event(Event{Kind: EventRuleTried, Rule: rule.name}, nil)
+			}
+			var start time.Time
+			if rs.Stats != nil {
+				rs.Stats.rule(rule).Tries++
+				start = time.Now()
+			}
+			fired := pRuleFired(rs, rule)
+			if rs.Stats != nil {
+				rs.Stats.addTime(rule, start)
+			}
+			if fired {
+				rs.Trace.Headln(1, 1, "rule ", rule.name, " fired (id: ", rule.id, ")")
+				ruleFound = true
+				break
+			}
+			rs.state(rule).isOn = false
+			if rs.Trace.On(1) {
+				rs.Trace.Headln(1, 1, " OFF rule: ", rule.name, " (Rule not fired) ")
+				rs.Trace.Headln(2, 1, "rule ", rule.name, " NOT fired (id: ", rule.id, ")")
 			}
 		}
+		if ruleFound && rs.Trace.Level != 0 {
+			printCHRStore(rs, "Intermediary result:")
+		}
 	}
+	return
+}
 
-	if i == 100000 {
-		TraceHeadln(0, 1, "!!! Time-out !!!")
+// checkLimits - the error, if a built-in function failed, the context ctx is done,
+// the number of rule firings reached maxFirings or the deadline is over
+func checkLimits(ctx context.Context, rs *Session, maxFirings int, deadline time.Time) error {
+	if rs.evalErr != nil {
+		return rs.evalErr
 	}
-
-	reduceStore(rs)
-
-	if CHRtrace > 1 {
-		printCHRStore(rs, "Result:")
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if rs.ruleFirings >= maxFirings {
+		return ErrMaxRuleFirings
+	}
+	if !deadline.IsZero() && time.Now().After(deadline) {
+		return ErrTimeout
 	}
+	return nil
 }
 
-func equationSolver(arg1, arg2 Term, env Bindings) (Bindings, bool) {
+// equationSolver solves the equation arg1 == arg2 with the bindings env: an equation
+// without variables is checked, a linear equation with one unknown is solved and
+// the solution is added to env. solved == false: the arithmetic equation is kept,
+// e.g. with two unknowns or not linear; ok == false: the equation has no solution
+func equationSolver(arg1, arg2 Term, env Bindings) (env2 Bindings, solved, ok bool) {
 	v1 := arg1.OccurVars()
 	v2 := arg2.OccurVars()
 	lenv1 := len(v1)
 	lenv2 := len(v2)
 	if lenv1 == 0 && lenv2 == 0 {
 		if Equal(Eval(arg1), Eval(arg2)) {
-			return env, true
+			return env, true, true
 		}
-		return env, false
+		return env, true, false
+	}
+	if !isArithmetic(arg1) && !isArithmetic(arg2) {
+		// terms, which do not unify, e.g. 0 == s(W)
+		return env, false, false
 	}
 	// fmt.Printf("** In equationsSolver \n")
 	var v Variable
-	vFound := 0
-	for _, v3 := range v1 {
-		if IsNewVariable(v3) {
-			if vFound != 0 { // found two variables
-				return env, false
+	vFound := false
+	for _, vars := range []Vars{v1, v2} {
+		for _, v3 := range vars {
+			if !vFound {
+				v = v3
+				vFound = true
+			} else if !EqVars(v, v3) { // found two variables
+				return env, false, true
 			}
-			v = v3
-			vFound = 1
 		}
 	}
-	for _, v3 := range v2 {
-		if IsNewVariable(v3) {
-			if vFound != 0 { // found two variables
-				return env, false
-			}
-			v = v3
-			vFound = 2
-		}
-	}
-	if vFound == 0 {
-		return env, false
-	}
-	if vFound == 2 {
-		arg1, arg2 = arg2, arg1
-	}
 	// fmt.Printf("** Vor eqSolver1 \n")
 	return eqSolver1(v, arg1, arg2, env)
 }
 
-func eqSolver1(v Variable, arg1, arg2 Term, env Bindings) (Bindings, bool) {
+// eqSolver1 solves the equation arg1 == arg2, if it is linear in the unknown v:
+// a1*v + b1 == a2*v + b2, v = (b2 - b1) / (a1 - a2) over ints, floats and rationals
+func eqSolver1(v Variable, arg1, arg2 Term, env Bindings) (env2 Bindings, solved, ok bool) {
 	// fmt.Printf("** In eqSolver1 Arg1: %s, Arg2: %s\n", arg1, arg2)
-	//	switch arg1.Type() {
-	//	case CompoundType:
-	//	case ListType:
-	//	case VariableType:
-	//		v2 := arg1.(Variable)
-	//		if EqVars(v, v2)
-	//		/*		AtomType Type = iota
-	//		BoolType
-	//		IntType
-	//		FloatType
-	//		StringType
-	//		CompoundType
-	//		ListType
-	//		VariableType
-	//		*/
-	//	}
+	a1, b1, ok1 := linearTerm(v, arg1)
+	a2, b2, ok2 := linearTerm(v, arg2)
+	if !ok1 || !ok2 {
+		return env, false, true
+	}
+	a := evalMinus(nil, a1, a1.Type(), a2, a2.Type())
+	b := evalMinus(nil, b2, b2.Type(), b1, b1.Type())
+	if isZero(a) {
+		// no unknown left: 0 == b
+		return env, true, isZero(b)
+	}
+	return AddBinding(v, divNumbers(b, a), env), true, true
+}
 
-	return env, false
+// linearTerm - the coefficient a and the constant b with t == a*v + b, if the
+// term t is linear in the variable v and has no other variables
+func linearTerm(v Variable, t Term) (a, b Term, ok bool) {
+	switch t := t.(type) {
+	case Int, BigInt, Rational, Float:
+		return Int(0), t, true
+	case Variable:
+		if EqVars(v, t) {
+			return Int(1), Int(0), true
+		}
+	case Compound:
+		if t.Prio == 0 || len(t.Args) == 0 || len(t.Args) > 2 {
+			return nil, nil, false
+		}
+		a1, b1, ok := linearTerm(v, t.Args[0])
+		if !ok {
+			return nil, nil, false
+		}
+		if len(t.Args) == 1 {
+			switch t.Functor {
+			case "+":
+				return a1, b1, true
+			case "-":
+				return evalUnaryMinus(nil, a1, a1.Type()), evalUnaryMinus(nil, b1, b1.Type()), true
+			}
+			return nil, nil, false
+		}
+		a2, b2, ok := linearTerm(v, t.Args[1])
+		if !ok {
+			return nil, nil, false
+		}
+		switch t.Functor {
+		case "+":
+			return evalPlus(nil, a1, a1.Type(), a2, a2.Type()), evalPlus(nil, b1, b1.Type(), b2, b2.Type()), true
+		case "-":
+			return evalMinus(nil, a1, a1.Type(), a2, a2.Type()), evalMinus(nil, b1, b1.Type(), b2, b2.Type()), true
+		case "*":
+			if isZero(a1) {
+				return evalTimes(nil, b1, b1.Type(), a2, a2.Type()), evalTimes(nil, b1, b1.Type(), b2, b2.Type()), true
+			}
+			if isZero(a2) {
+				return evalTimes(nil, a1, a1.Type(), b2, b2.Type()), evalTimes(nil, b1, b1.Type(), b2, b2.Type()), true
+			}
+		case "/":
+			// the integer division of the evaluator, if v is an integer: solved only
+			// for a divisor, which is not an integer, or without v
+			if !isZero(a2) || isZero(b2) {
+				break
+			}
+			if isZero(a1) {
+				return Int(0), evalDivision(nil, b1, b1.Type(), b2, b2.Type()), true
+			}
+			if typ := b2.Type(); typ == FloatType || typ == RationalType {
+				return divNumbers(a1, b2), divNumbers(b1, b2), true
+			}
+		case "rdiv":
+			if isZero(a2) && !isZero(b2) {
+				return divNumbers(a1, b2), divNumbers(b1, b2), true
+			}
+		}
+	}
+	return nil, nil, false
+}
+
+// isArithmetic - t is an arithmetic expression, e.g. X + 3
+func isArithmetic(t Term) bool {
+	c, ok := t.(Compound)
+	return ok && c.Prio != 0
+}
+
+// divNumbers - x / y for y != 0, a rational number for integers
+func divNumbers(x, y Term) Term {
+	if isExact(x.Type()) && isExact(y.Type()) {
+		return evalRDiv(nil, x, x.Type(), y, y.Type())
+	}
+	return evalDivision(nil, x, x.Type(), y, y.Type())
 }
 
-func reduceStore(rs *RuleStore) {
+// reduceStore reduces the built-in store with the bindings of its equations,
+// solves the arithmetic equations and checks the linear constraints; true: new
+// solutions of equations are substituted in the CHR-store, which is solved again
+func reduceStore(rs *Session) bool {
 	if rs.Result != RStore {
-		return
+		return false
 	}
+	// the built-in constraints are deleted and rewritten in place
+	rs.biVersion++
+	defer func() { rs.biVersion++ }()
 	// fmt.Printf("** In reduce Store\n")
 	bi := bi2CList(rs)
 	var env Bindings = nil
@@ -734,37 +965,71 @@ func reduceStore(rs *RuleStore) {
 	}
 
 	reduce2true := false
+	residual := 0
+	var solutions Bindings
 	// fmt.Printf("** Nach Unify idxList: %v \n", idxList)
 	for _, idx := range idxList {
 		b := bi[idx]
-		b2 := Eval(Substitute(*b, env))
+		b2 := rs.eval(Substitute(*b, env))
 		if b2.Type() == BoolType {
 			if b2.(Bool) == true {
 				reduce2true = true
 				b.IsDeleted = true
 			} else {
 				rs.Result = RFalse
-				return
+				return false
 			}
 		}
 		if b2.Type() == CompoundType {
 			b3 := b2.(Compound)
 			bi[idx] = &b3
-			env2, ok := equationSolver(b3.Args[0], b3.Args[1], env)
+			env2, solved, ok := equationSolver(b3.Args[0], b3.Args[1], env)
 			if !ok {
 				rs.Result = RFalse
-				return
+				return false
+			}
+			if !solved {
+				// kept in the built-in store
+				residual++
+				continue
 			}
-			env = env2
 			reduce2true = true
-			b.IsDeleted = true
+			if env2 == env {
+				b.IsDeleted = true
+				continue
+			}
+			// the solution is kept as equation v == value and substituted in the CHR-store
+			env = env2
+			solutions = AddBinding(env2.Var, env2.T, solutions)
+			b.Args = []Term{env2.Var, env2.T}
+			bi[idx] = b
 		}
 
 	}
+	// the linear constraints have no solution or the equations determine the values of variables
+	if ls := linearTableau(rs); ls.n != 0 {
+		if !ls.isFeasible() {
+			rs.Result = RFalse
+			return false
+		}
+		for d := ls.determined(); d != nil; d = d.Next {
+			if _, ok := GetBinding(d.Var, env); ok {
+				continue
+			}
+			env = AddBinding(d.Var, d.T, env)
+			solutions = AddBinding(d.Var, d.T, solutions)
+			addConstraintToStore(rs, Compound{Functor: "==", Prio: 3, Args: []Term{d.Var, d.T}})
+		}
+	}
+	if solutions != nil {
+		// the CHR-store is solved again with the solutions
+		substituteStores(rs, solutions)
+		return true
+	}
 
 	// fmt.Printf("** Nach equationSover \n")
 	if env == nil {
-		return
+		return false
 	}
 	//	for env2 := env; env2 != nil; env2 = env2.Next {
 	//		v1 := env2.Var
@@ -772,7 +1037,7 @@ func reduceStore(rs *RuleStore) {
 	//	}
 	// reduce all equals
 
-	pcount := 0
+	pcount := residual
 	visited := map[string]bool{}
 	for i, b := range bi {
 		pcount++
@@ -796,7 +1061,7 @@ func reduceStore(rs *RuleStore) {
 					pcount--
 				} else {
 					visited[arg0.(Variable).Name] = true
-					b.Args[1] = Eval(Substitute(arg1, env))
+					b.Args[1] = rs.eval(Substitute(arg1, env))
 				}
 				continue
 			}
@@ -807,7 +1072,7 @@ func reduceStore(rs *RuleStore) {
 				} else {
 					visited[arg1.(Variable).Name] = true
 					b.Args[0] = arg1
-					b.Args[1] = Eval(Substitute(arg0, env))
+					b.Args[1] = rs.eval(Substitute(arg0, env))
 				}
 				continue
 			}
@@ -818,14 +1083,14 @@ func reduceStore(rs *RuleStore) {
 			pcount--
 			// subst && eval
 			sb := Substitute(*b, env)
-			sb = Eval(sb)
+			sb = rs.eval(sb)
 			if sb.Type() == BoolType {
 				if sb.(Bool) == true {
 					reduce2true = true
 					b.IsDeleted = true
 				} else {
 					rs.Result = RFalse
-					return
+					return false
 				}
 			}
 			if sb.Type() == CompoundType {
@@ -838,15 +1103,15 @@ func reduceStore(rs *RuleStore) {
 	for i, c := range chrs {
 		pcount++
 		c1 := Substitute(*c, env)
-		c1 = Eval(c1)
+		c1 = rs.eval(c1)
 		if c1.Type() == BoolType {
 			if c1.(Bool) == true {
 				reduce2true = true
-				c.IsDeleted = true
+				markDeleted(rs, c)
 				pcount--
 			} else {
 				rs.Result = RFalse
-				return
+				return false
 			}
 		}
 		if c1.Type() == CompoundType {
@@ -857,2470 +1122,227 @@ func reduceStore(rs *RuleStore) {
 	if pcount == 0 && reduce2true {
 		rs.Result = RTrue
 	}
+	return false
 }
 
-// prove whether rule fired
-func pRuleFired(rs *RuleStore, rule *chrRule) (ok bool) {
-	keepList := rule.keepHead
-	len_keepHead := len(keepList)
-	delList := rule.delHead
-	len_delHead := len(delList)
+// skipTried - matchRule skips the tried candidates of rules with del-head; false
+// only in tests, which compare the results with and without the skipped candidates
+var skipTried = true
 
-	if rule.eMap == nil {
-		fmt.Printf(" ######### empty envMap in RULE !!!!!!!!!!!!!!!\n")
-		rule.eMap = &EnvMap{InBinding: rs.emptyBinding, OutBindings: map[int]*EnvMap{}}
+// prove whether rule fired: match the heads in the join order of the rule
+func pRuleFired(rs *Session, rule *chrRule) bool {
+	partners, env, ok := matchRule(rs, rule)
+	if !ok {
+		return false
 	}
+	if rs.ruleChoices {
+		addRuleChoice(rs, rule)
+	}
+	fireMatchedRule(rs, rule, partners, env)
+	return true
+}
 
-	if len_delHead == 0 {
-		if len_keepHead == 0 {
-			return false
-		}
-		// only keepHead
-		req := rule.keepReq // Request
-		// fmt.Printf("->>[> %s <] Rule %s stored req\n", keepReq2str(req), rule.name)
-		if req != R_next {
-
-			ok, req = handleLastFail(rs, rule, []*big.Int{}, keepList, 0, len_keepHead, rule.eMap, nil)
-
-			// fmt.Printf("<<-[> %v, %s <] << [> lastFail <], Rule %s \n", ok, keepReq2str(req), rule.name)
-		}
-		for req == R_next {
-
-			ok, req = matchKeepHead(rs, rule, []*big.Int{}, keepList, 0, len_keepHead, rule.eMap, nil, C_last)
+// matchRule matches the heads of the rule in its join order with the constraints
+// of the CHR-store and checks the guards; the matched constraints are the partners
+func matchRule(rs *Session, rule *chrRule) (CList, Bindings, bool) {
+	heads := ruleHeads(rule)
+	if len(heads) == 0 {
+		return nil, nil, false
+	}
+	var tried map[string]int
+	if len(rule.delHead) != 0 && skipTried {
+		st := rs.state(rule)
+		// a guard, which failed, may be entailed by the grown built-in store
+		if st.tried == nil || st.triedBis != rs.biVersion {
+			st.tried = map[string]int{}
+			st.triedBis = rs.biVersion
+		}
+		tried = st.tried
+	}
+	partners := make(CList, len(heads))
+	env, ok := matchHeads(rs, rule, rule.join, heads, partners, 0, rs.emptyBinding, tried)
+	return partners, env, ok
+}
 
-			// fmt.Printf("<<-[> %v, %s <] << [> next <] Rule %s  \n", ok, keepReq2str(req), rule.name)
-			if ok {
-				rule.keepReq = req
-				return ok
-			}
+// fireMatchedRule fires the rule with the matched partners and the environment env
+func fireMatchedRule(rs *Session, rule *chrRule, partners CList, env Bindings) {
+	rs.ruleFirings++
+	if rs.Tracer != nil {
+		rs.event(Event{Kind: EventRuleFired, Rule: rule.name, Partners: partners}, env)
+	}
+	if rs.Stats != nil {
+		rs.Stats.fired(rs, rule)
+	}
+	if len(rule.delHead) == 0 {
+		addHistory(rs, rule, partners)
+		if rs.Trace.On(3) {
+			rs.Trace.Headln(3, 3, "add history: ", rule.name, " [", historyKey(partners), "]")
 		}
-		rule.keepReq = req
-		return ok // == false
-
 	}
-
-	if len_keepHead != 0 {
-
-		ok = matchKeepDelHead(true, rs, rule, keepList, 0, len_keepHead, rule.eMap, nil)
-		return ok
+	for i := range rule.delHead {
+		delConstraint(partners[i], rs)
 	}
-
-	return matchDelHead(rs, rule, delList, 0, len_delHead, rule.eMap, nil)
-
+	if rs.Justify {
+		rs.cause = ruleCause(rs, rule, partners, env)
+	}
+	fireRule(rs, rule, env)
+	rs.cause = nil
 }
 
-// prove and trace whether rule fired
-func TraceRuleFired(rs *RuleStore, rule *chrRule) (ok bool) {
-	keepList := rule.keepHead
-	len_keepHead := len(keepList)
-	delList := rule.delHead
-	len_delHead := len(delList)
-
-	if rule.eMap == nil {
-		fmt.Printf(" ######### empty envMap in RULE !!!!!!!!!!!!!!!\n")
-		rule.eMap = &EnvMap{InBinding: rs.emptyBinding, OutBindings: map[int]*EnvMap{}}
+// matchHeads matches the heads in the join order 'order', beginning with the step 'it',
+// with constraints of the CHR-store; the matched constraints are stored in 'partners'.
+// The guards of a step are checked, when the head of the step matched. Heads with a
+// partner (active constraint) are not matched again, only the guards are checked.
+// A propagation rule (without del-head) fires only once with the same partners.
+// If tried != nil, the candidates of the last head, which were tried with the same
+// partners of the previous heads, are skipped: they failed and a failed candidate
+// can only match, if the built-in store changed (see matchRule). The positions in
+// the read constraint lists are kept: a deleted CHR-constraint is only marked as
+// deleted (or set to nil), a substituted CHR-constraint is added as a new one.
+func matchHeads(rs *Session, r *chrRule, order []joinStep, heads, partners CList, it int, env Bindings,
+	tried map[string]int) (Bindings, bool) {
+	if it == len(order) {
+		return env, true
+	}
+	step := order[it]
+	if partners[step.head] != nil {
+		env2, ok := checkStepGuards(rs, r, step, partners, it+1 == len(order), env)
+		if !ok {
+			return env, false
+		}
+		return matchHeads(rs, r, order, heads, partners, it+1, env2, tried)
 	}
 
-	if len_delHead == 0 {
-		if len_keepHead == 0 {
-			return false
+	head := heads[step.head]
+	if head.Functor == "" {
+		// variable in head
+		b, ok := GetBinding(head.Args[0].(Variable), env)
+		if !ok || b.Type() != CompoundType {
+			return env, false
+		}
+		bc := b.(Compound)
+		head = &bc
+	}
+	chrList := readProperConstraintsFromCHR_Store(rs, head, env)
+	if rs.Trace.On(3) {
+		rs.Trace.Headln(3, 3, "match head >", head, "< with ", len(chrList), " constraints")
+	}
+	// propagation rule: the newest constraints first, the older are in the history
+	ic, last, inc := 0, len(chrList), 1
+	if len(r.delHead) == 0 {
+		ic, last, inc = len(chrList)-1, -1, -1
+	}
+	useTried := tried != nil && it+1 == len(order)
+	var key string
+	if useTried {
+		key = triedKey(order[:it], partners)
+		ic = tried[key]
+	}
+	for ; ic != last; ic += inc {
+		if useTried {
+			tried[key] = ic
+		}
+		chr := chrList[ic]
+		if chr == nil || chr.IsDeleted || isPartner(chr, partners) {
+			continue
+		}
+		if rs.Stats != nil {
+			rs.Stats.rule(r).HeadMatches++
+		}
+		env2, ok := Match(*head, *chr, env)
+		if !ok {
+			continue
 		}
-		// only keepHead
-		req := rule.keepReq // Request
-		// fmt.Printf("->>[> %s <] Rule %s stored req\n", keepReq2str(req), rule.name)
-		if req != R_next {
-			ok, req = traceHandleLastFail(rs, rule, []*big.Int{}, keepList, 0, len_keepHead, rule.eMap, nil)
-			// fmt.Printf("<<-[> %v, %s <] << [> lastFail <], Rule %s \n", ok, keepReq2str(req), rule.name)
+		if rs.Trace.On(4) {
+			rs.Trace.Head(4, 3, "match head ", head, " with CHR ", chr, " (Id: ", chr.Id, ") (Binding: ")
+			rs.Trace.Env(4, env2)
+			rs.Trace.Traceln(4, ")")
 		}
-		for req == R_next {
-			ok, req = traceMatchKeepHead(rs, rule, []*big.Int{}, keepList, 0, len_keepHead, rule.eMap, nil, C_last)
-			// fmt.Printf("<<-[> %v, %s <] << [> next <] Rule %s  \n", ok, keepReq2str(req), rule.name)
+		if rs.Tracer != nil {
+			rs.event(Event{Kind: EventHeadMatched, Rule: r.name, Head: head, Constraint: chr}, env2)
+		}
+		partners[step.head] = chr
+		env2, ok = checkStepGuards(rs, r, step, partners, it+1 == len(order), env2)
+		if ok {
+			env2, ok = matchHeads(rs, r, order, heads, partners, it+1, env2, tried)
 			if ok {
-				rule.keepReq = req
-				return ok
+				return env2, true
 			}
 		}
-		rule.keepReq = req
-		return ok // == false
-
+		partners[step.head] = nil
 	}
-
-	if len_keepHead != 0 {
-
-		ok = traceMatchKeepDelHead(true, rs, rule, keepList, 0, len_keepHead, rule.eMap, nil)
-		return ok
+	if useTried {
+		tried[key] = len(chrList)
 	}
-
-	return traceMatchDelHead(rs, rule, delList, 0, len_delHead, rule.eMap, nil)
-
+	return env, false
 }
 
-// Try to match the del-head 'it' from the 'headlist' ('nt'==len of 'headlist')
-// with the 'ienv'-te environmen 'env'
-// If matching ok, call 'matchKeepHead' or 'checkGuards'
-func matchKeepDelHead(isKeep bool, rs *RuleStore, r *chrRule, headList CList, it int, nt int, envMap *EnvMap, env1 Bindings) (ok bool) {
-	var env Bindings
-	var env2 *EnvMap
-	var senv map[int]*EnvMap
-	var mark bool
-
-	head := headList[it]
-	head2 := head
-	if env1 == nil {
-		env = envMap.InBinding
-	} else {
-		env = env1
+// triedKey - the key of the partners of the join steps 'steps'
+func triedKey(steps []joinStep, partners CList) string {
+	cl := make(CList, len(steps))
+	for i, s := range steps {
+		cl[i] = partners[s.head]
 	}
+	return historyKey(cl)
+}
 
-	chrList := readProperConstraintsFromCHR_Store(rs, head, env)
-	len_chr := len(chrList)
-
-	// trace
-
-	if isKeep {
-		TraceHeadln(3, 3, "-----")
-		TraceHead(3, 3, "match Keep-Head >", head, "< with [")
-	} else {
-		TraceHead(3, 3, "match Del-Head >", head, "< with [")
+// checkStepGuards checks the guards of the join step 'step'; after the last step the
+// propagation history of a rule without del-head is checked first
+func checkStepGuards(rs *Session, r *chrRule, step joinStep, partners CList, last bool, env Bindings) (Bindings, bool) {
+	if last && len(r.delHead) == 0 && inHistory(rs, r, partners) {
+		if rs.Trace.On(3) {
+			rs.Trace.Headln(3, 3, "in history: ", r.name, " [", historyKey(partners), "]")
+		}
+		return env, false
 	}
-
-	// end trace
-
-	if len_chr == 0 {
-		// variabel in head
-		if head.Functor == "" {
-			// ###
-			b, ok := GetBinding(head.Args[0].(Variable), env)
-			if !ok {
-				return false
+	for _, g := range step.guards {
+		env2, ok := checkGuard(rs, g, env)
+		if !ok {
+			if rs.Tracer != nil {
+				rs.event(Event{Kind: EventGuardFailed, Rule: r.name, Guard: g}, env)
 			}
-			if b.Type() != CompoundType {
-				return false
+			if rs.Stats != nil {
+				rs.Stats.rule(r).GuardFailures++
 			}
-			bc := b.(Compound)
-			head2 = &bc
-			chrList = readProperConstraintsFromCHR_Store(rs, head2, env)
-			len_chr = len(chrList)
-			if len_chr == 0 {
-				return false
-			}
-		} else {
-			return false
-		}
-	}
-
-	// begin trace
-
-	first := true
-	for _, c := range chrList {
-		if c != nil && !c.IsDeleted {
-			if first {
-				Trace(3, c)
-				first = false
-			} else {
-				Trace(3, ", ", c)
-			}
-		}
-	}
-	Traceln(3, "]")
-
-	// end trace
-	// end trace
-
-	// begin check the next head
-	lastKeepDelHead := it+1 == nt // last Keep or last Del-Head
-	lastHead := false
-	if lastKeepDelHead {
-		// last keep head
-		if isKeep {
-			// headList = r.keepHead
-			headList = r.delHead
-			nt = len(headList)
-			if nt == 0 {
-				lastHead = true
-			}
-		} else {
-			lastHead = true
-		}
-	}
-	// End next check next head, if lastDelHead the headList == r.keephead
-	// check in head stored environment map
-	ie := 0
-	len_ie := 0
-
-	if env1 == nil {
-
-		senv = envMap.OutBindings
-		// senv, ok := (*head.EMap)[ienv]
-		//	if ok {
-		len_ie = len(senv)
-		if lastHead {
-			ie = len_ie
-		} else {
-			if lastKeepDelHead {
-				for ; ie < len_ie; ie++ {
-					env2 = senv[ie]
-					if env2 != nil {
-						chr := chrList[ie]
-						mark = markCHR(chr)
-						if mark {
-							ok = matchKeepDelHead(false, rs, r, headList, 0, nt, env2, nil)
-							if ok {
-								unmarkDelCHR(chr)
-								// unmarkDelCHR(chr), chr == keepCHR
-								return ok
-							}
-							unmarkDelCHR(chr)
-						}
-					}
-				}
-			} else { // not a last Del-Head
-				for ; ie < len_ie; ie++ {
-					env2 = senv[ie]
-					if env2 != nil {
-						chr := chrList[ie]
-						mark = markCHR(chr)
-						if mark {
-							ok = matchKeepDelHead(isKeep, rs, r, headList, it+1, nt, env2, nil)
-							if ok {
-								if isKeep {
-									unmarkDelCHR(chr)
-								} else {
-									chrList[ie] = nil
-									delConstraint(chr, rs)
-								}
-								// else: not unmarkDelCHR(chr), markt == deleted
-								return ok
-							}
-							unmarkDelCHR(chr)
-						}
-
-					}
-				} // for ; ie < len_ie; ie++
-			} // ! lastDelHead
-		} // ! lastHead
-		//	} else {
-		//		// senv = []Bindings{}
-		//		envMap.nextBindings[ienv] = &EnvMap{storedBinding: rs.emptyBinding, nextBindings: map[int]*EnvMap{}}
-		//		// (*head.EMap)[ienv] = senv
-		//	}
-		// End check in head stored environment map
-	}
-	// normal head-check, start at ie (not at 0 !!)
-	if lastHead {
-		for ok, ic := false, ie; !ok && ic < len_chr; ic++ {
-			chr := chrList[ic]
-			envNew, ok, mark := markCHRAndMatchDelHead(r.id, head2, chr, env)
-			if ok {
-				if it < EnvCache {
-					senv[ic] = &EnvMap{InBinding: envNew, OutBindings: map[int]*EnvMap{}}
-				}
-				ok = checkGuards(rs, r, envNew)
-				if ok {
-					if isKeep {
-						unmarkDelCHR(chr)
-					} else {
-						chrList[ic] = nil
-						delConstraint(chr, rs)
-					}
-					// (*head.EMap)[ienv] = senv
-					return ok
-				}
-
-			} else {
-				if it < EnvCache {
-					senv[ic] = nil
-				}
-			}
-			if mark {
-				unmarkDelCHR(chr)
-			}
-		}
-		// (*head.EMap)[ienv] = senv
-		return false
-	} // lastHead
-
-	if lastKeepDelHead { // last keepHead in front of delHead
-		for ok, ic := false, ie; !ok && ic < len_chr; ic++ {
-			chr := chrList[ic]
-			envNew, ok, mark := markCHRAndMatchKeepHead(r.id, head2, chr, env)
-			if ok {
-				// senv = append(senv, env2)
-				if it < EnvCache {
-					senv[ic] = &EnvMap{InBinding: envNew, OutBindings: map[int]*EnvMap{}}
-					if isKeep {
-						ok = matchKeepDelHead(false, rs, r, headList, 0, nt, senv[ic], nil)
-					} else {
-						ok = checkGuards(rs, r, envNew)
-					}
-				} else {
-					if isKeep {
-						ok = matchKeepDelHead(false, rs, r, headList, 0, nt, nil, envNew)
-					} else {
-						ok = checkGuards(rs, r, envNew)
-					}
-				}
-				if ok {
-					if isKeep {
-						unmarkDelCHR(chr)
-					} else {
-						chrList[ic] = nil
-						delConstraint(chr, rs)
-					}
-					return ok
-				}
-			} else {
-				if it < EnvCache {
-					senv[ic] = nil
-				}
-			}
-			if mark {
-				unmarkDelCHR(chr)
-			}
-		}
-		// (*head.EMap)[ienv] = senv
-		return false
-	}
-
-	for ok, ic := false, ie; !ok && ic < len_chr; ic++ {
-
-		chr := chrList[ic]
-
-		envNew, ok, mark := markCHRAndMatchDelHead(r.id, head2, chr, env) // mark chr and Match, if fail unmark chr
-		if ok {
-			if it < EnvCache {
-				senv[ic] = &EnvMap{InBinding: envNew, OutBindings: map[int]*EnvMap{}}
-				ok = matchKeepDelHead(isKeep, rs, r, headList, it+1, nt, senv[ic], nil)
-			} else {
-				ok = matchKeepDelHead(isKeep, rs, r, headList, it+1, nt, nil, envNew)
-			}
-
-			if ok {
-				if isKeep {
-					unmarkDelCHR(chr)
-				} else {
-					chrList[ic] = nil
-					delConstraint(chr, rs)
-				}
-				// not unmarkDelCHR(chr), markt == deleted
-				// (*head.EMap)[ienv] = senv
-				return ok
-			}
-		} else {
-			if it < EnvCache {
-				senv[ic] = nil
-			}
-		}
-		if mark {
-			unmarkDelCHR(chr)
-		}
-	}
-	// (*head.EMap)[ienv] = senv
-	return false
-}
-
-// Try to match and trace the del-head 'it' from the 'headlist' ('nt'==len of 'headlist')
-// with the 'ienv'-te environmen 'env'
-// If matching ok, call 'matchKeepHead' or 'checkGuards'
-
-func traceMatchKeepDelHead(isKeep bool, rs *RuleStore, r *chrRule, headList CList, it int, nt int, envMap *EnvMap, env1 Bindings) (ok bool) {
-	var env Bindings
-	var env2 *EnvMap
-	var senv map[int]*EnvMap
-	var mark bool
-
-	head := headList[it]
-	head2 := head
-	if env1 == nil {
-		env = envMap.InBinding
-	} else {
-		env = env1
-	}
-
-	chrList := readProperConstraintsFromCHR_Store(rs, head, env)
-	len_chr := len(chrList)
-
-	// trace
-
-	if isKeep {
-		TraceHeadln(3, 3, "-----")
-		TraceHead(3, 3, "match Keep-Head > ", head, " < with [")
-	} else {
-		TraceHead(3, 3, "match Del-Head > ", head, " < with [")
-	}
-
-	// end trace
-
-	if len_chr == 0 {
-		// variabel in head
-		if head.Functor == "" {
-			// ###
-			b, ok := GetBinding(head.Args[0].(Variable), env)
-			if !ok {
-				Traceln(3, "]")
-				return false
-			}
-			if b.Type() != CompoundType {
-				Traceln(3, "]")
-				return false
-			}
-			bc := b.(Compound)
-			head2 = &bc
-			chrList = readProperConstraintsFromCHR_Store(rs, head2, env)
-			len_chr = len(chrList)
-			if len_chr == 0 {
-				Traceln(3, "]")
-				return false
-			}
-		} else {
-			Traceln(3, "]")
-			return false
-		}
-	}
-
-	// begin trace
-	first := true
-	for _, c := range chrList {
-		if c != nil && !c.IsDeleted {
-			if first {
-				Trace(3, c)
-				first = false
-			} else {
-				Trace(3, ", ", c)
-			}
-		}
-	}
-	Traceln(3, "]")
-	// end trace
-
-	// begin check the next head
-	lastKeepDelHead := it+1 == nt // last Keep or last Del-Head
-	lastHead := false
-	if lastKeepDelHead {
-		// last keep head
-		if isKeep {
-			// headList = r.keepHead
-			headList = r.delHead
-			nt = len(headList)
-			if nt == 0 {
-				lastHead = true
-			}
-		} else {
-			lastHead = true
-		}
-	}
-	// End next check next head, if lastKeepHead the headList == r.delHead
-	// check in head stored environment map
-	ie := 0
-	len_ie := 0
-
-	if env1 == nil {
-		senv = envMap.OutBindings
-		// senv, ok := (*head.EMap)[ienv]
-		//	if ok {
-		TraceHead(4, 4, "(1) Emap before head match")
-		TraceEMap(4, 4, head, envMap)
-		len_ie = len(senv)
-
-		// trace
-		if isKeep {
-			TraceHead(4, 3, "match Keep-Head > ", head, " < Env: [")
-		} else {
-			TraceHead(4, 3, "match Del-Head > ", head, " < Env: [")
-		}
-		first = true
-		for _, e := range senv {
-			if first {
-				first = false
-			} else {
-				Trace(4, ", ")
-			}
-			if e != nil {
-				TraceEnv(4, e.InBinding)
-			}
-		}
-		Traceln(4, "]")
-		// End trace3
-
-		if lastHead {
-			ie = len_ie
-		} else {
-			if lastKeepDelHead {
-				for ; ie < len_ie; ie++ {
-					env2 = senv[ie]
-					if env2 != nil {
-						chr := chrList[ie]
-						mark = markCHR(chr)
-						if mark {
-							ok = traceMatchKeepDelHead(false, rs, r, headList, 0, nt, env2, nil)
-							if ok {
-								traceUnmarkDelCHR(chr)
-								// unmarkDelCHR(chr) - chr == keepCHR
-								return ok
-							}
-							traceUnmarkDelCHR(chr)
-						}
-					}
-				}
-			} else { // not a last Keep-Head
-				for ; ie < len_ie; ie++ {
-					env2 = senv[ie]
-					if env2 != nil {
-						chr := chrList[ie]
-						mark = markCHR(chr)
-						if mark {
-							ok = traceMatchKeepDelHead(isKeep, rs, r, headList, it+1, nt, env2, nil)
-							if ok {
-								if isKeep {
-									unmarkDelCHR(chr)
-								} else {
-									chrList[ie] = nil
-									delConstraint(chr, rs)
-								}
-								// else: not unmarkDelCHR(chr), markt == deleted
-								return ok
-							}
-							traceUnmarkDelCHR(chr)
-						}
-
-					}
-				} // for ; ie < len_ie; ie++
-			} // ! lastDelHead
-		} // ! lastHead
-		//	} else {
-		//		// senv = []Bindings{}
-		//		envMap.nextBindings[ienv] = &EnvMap{storedBinding: rs.emptyBinding, nextBindings: map[int]*EnvMap{}}
-		//		// (*head.EMap)[ienv] = senv
-		//	}
-		// End check in head stored environment map
-	}
-	// normal head-check, start at ie (not at 0 !!)
-	//	// trace
-	//	if isKeep {
-	//		TraceHeadln(3, 3, "normal match keep-Head > ", head, " < from: ", ie, " < ", len_chr)
-	//	} else {
-	//		TraceHeadln(3, 3, "normal match del-Head > ", head, " < from: ", ie, " < ", len_chr)
-	//	}
-	//	// end trace
-	if lastHead {
-		// trace
-		if isKeep {
-			TraceHeadln(3, 3, "ERROR: normal match last keep-Head > ", head, " < from: ", ie, " < ", len_chr)
-		} else {
-			TraceHeadln(3, 3, "normal match last del-Head > ", head, " < from: ", ie, " < ", len_chr)
-		}
-		// end trace
-		for ok, ic := false, ie; !ok && ic < len_chr; ic++ {
-			chr := chrList[ic]
-			envNew, ok, mark := traceMarkCHRAndMatchDelHead(r.id, head2, chr, env)
-			if ok {
-				// trace senv changes
-				TraceHead(4, 3, "New environment, Head: ", head.String(), ", Env: [", ic, "], =")
-				TraceEnv(4, envNew)
-				Traceln(4, "")
-				// end trace
-
-				if it < EnvCache {
-					senv[ic] = &EnvMap{InBinding: envNew, OutBindings: map[int]*EnvMap{}}
-				}
-
-				ok = traceCheckGuards(rs, r, envNew)
-				if ok {
-					if isKeep {
-						traceUnmarkDelCHR(chr)
-					} else {
-						chrList[ic] = nil
-						delConstraint(chr, rs)
-					}
-					// (*head.EMap)[ienv] = senv
-					TraceHead(4, 3, "(2) After rule fired Emap for the last del-Head.")
-					TraceEMap(4, 4, head, envMap)
-					return ok
-				}
-
-			} else {
-				if it < EnvCache {
-					senv[ic] = nil
-				}
-			}
-			if mark {
-				traceUnmarkDelCHR(chr)
-			}
-		}
-		// (*head.EMap)[ienv] = senv
-		TraceHead(4, 3, "(3) After rule NOT fired Emap for the last del-Head.")
-		TraceEMap(4, 4, head, envMap)
-		return false
-	} // lastHead
-
-	if lastKeepDelHead { // last keepHead in front of delHead
-		// trace
-		if isKeep {
-			TraceHeadln(3, 3, "normal match last keep-Head (.. delHead) > ", head, " < from: ", ie, " < ", len_chr)
-		} else {
-			TraceHeadln(3, 3, "ERROR: normal match del-Head > ", head, " < from: ", ie, " < ", len_chr)
-		}
-		// end trace
-		for ok, ic := false, ie; !ok && ic < len_chr; ic++ {
-			chr := chrList[ic]
-			envNew, ok, mark := traceMarkCHRAndMatchKeepHead(r.id, head2, chr, env)
-			if ok {
-				// trace senv changes
-				TraceHead(4, 3, "New environment ", "Head: ", head.String(), ", Env: [", ic, "], =")
-				TraceEnv(4, envNew)
-				Traceln(4, "")
-				// end trace
-
-				// senv = append(senv, env2)
-				if it < EnvCache {
-					senv[ic] = &EnvMap{InBinding: envNew, OutBindings: map[int]*EnvMap{}}
-					if isKeep {
-						ok = traceMatchKeepDelHead(false, rs, r, headList, 0, nt, senv[ic], nil)
-					} else {
-						ok = traceCheckGuards(rs, r, envNew)
-					}
-				} else {
-					if isKeep {
-						ok = traceMatchKeepDelHead(false, rs, r, headList, 0, nt, nil, envNew)
-					} else {
-						ok = traceCheckGuards(rs, r, envNew)
-					}
-				}
-				if ok {
-					// trace
-					TraceHead(4, 4, "(4) Environment after rule fired: ")
-					TraceEMap(4, 4, head, envMap)
-					// end trace
-					if isKeep {
-						traceUnmarkDelCHR(chr)
-					} else {
-						chrList[ic] = nil
-						delConstraint(chr, rs)
-					}
-					return ok
-				}
-			} else {
-				if it < EnvCache {
-					senv[ic] = nil
-				}
-			}
-			if mark {
-				traceUnmarkDelCHR(chr)
-			}
-		}
-		// (*head.EMap)[ienv] = senv
-		// trace
-		TraceHead(4, 4, "(5) Environment after rule NOT fired: ")
-		TraceEMap(4, 4, head, envMap)
-		// end trace
-		return false
-	}
-
-	// trace
-	if isKeep {
-		TraceHeadln(3, 3, "normal match keep-Head > ", head, " < from: ", ie, " < ", len_chr)
-	} else {
-		TraceHeadln(3, 3, "normal match del-Head > ", head, " < from: ", ie, " < ", len_chr)
-	}
-	// end trace
-
-	for ok, ic := false, ie; !ok && ic < len_chr; ic++ {
-
-		chr := chrList[ic]
-
-		envNew, ok, mark := traceMarkCHRAndMatchDelHead(r.id, head2, chr, env) // mark chr and Match, if fail unmark chr
-		if ok {
-			// trace senv changes
-			TraceHead(4, 3, "New environment, Head: ", head.String(), ", Env: [", ic, "], =")
-			TraceEnv(4, envNew)
-			Traceln(4, "")
-			// end trace
-
-			if it < EnvCache {
-				senv[ic] = &EnvMap{InBinding: envNew, OutBindings: map[int]*EnvMap{}}
-				ok = traceMatchKeepDelHead(isKeep, rs, r, headList, it+1, nt, senv[ic], nil)
-			} else {
-				ok = traceMatchKeepDelHead(isKeep, rs, r, headList, it+1, nt, nil, envNew)
-			}
-
-			if ok {
-				if isKeep {
-					unmarkDelCHR(chr)
-				} else {
-					chrList[ic] = nil
-					delConstraint(chr, rs)
-				}
-				// not unmarkDelCHR(chr), markt == deleted
-				// (*head.EMap)[ienv] = senv
-				TraceHead(4, 4, "(6) Emap after rule fired:")
-				TraceEMap(4, 4, head, envMap)
-				return ok
-			}
-		} else {
-			if it < EnvCache {
-				senv[ic] = nil
-			}
-		}
-		if mark {
-			traceUnmarkDelCHR(chr)
-		}
-	}
-	// (*head.EMap)[ienv] = senv
-	TraceHead(4, 4, "(7) Emap after rule NOT fired:")
-	TraceEMap(4, 4, head, envMap)
-
-	return false
-}
-
-//func traceMatchDelKeepHead(isDel bool, rs *RuleStore, r *chrRule, headList CList, it int, nt int, envMap *EnvMap, env1 Bindings) (ok bool) {
-//	var env, env2 Bindings
-//	var mark bool
-//	var senv map[int]*EnvMap
-
-//	if env1 == nil {
-//		env = envMap.InBinding
-//	} else {
-//		env = env1
-//	}
-
-//	head := headList[it]
-//	head2 := head
-//	chrList := readProperConstraintsFromCHR_Store(rs, head, env)
-//	if isDel {
-//		TraceHead(3, 3, "match Del-Head ", head, " with [")
-//	} else {
-//		TraceHead(3, 3, "match Keep-Head ", head, " with [")
-//	}
-//	len_chr := len(chrList)
-//	if len_chr == 0 {
-//		// variabel in head
-//		if head.Functor == "" {
-//			// ###
-//			b, ok := GetBinding(head.Args[0].(Variable), env)
-//			if !ok {
-//				Traceln(3, "]")
-//				return false
-//			}
-//			if b.Type() != CompoundType {
-//				Traceln(3, "]")
-//				return false
-//			}
-//			bc := b.(Compound)
-//			head2 = &bc
-//			chrList = readProperConstraintsFromCHR_Store(rs, head2, env)
-//			len_chr = len(chrList)
-//			if len_chr == 0 {
-//				Traceln(3, "]")
-//				return false
-//			}
-//		} else {
-//			Traceln(3, "]")
-//			return false
-//		}
-//	}
-//	// begin trace
-//	first := true
-//	for _, c := range chrList {
-//		if c != nil && !c.IsDeleted {
-//			if first {
-//				Trace(3, c)
-//				first = false
-//			} else {
-//				Trace(3, ", ", c)
-//			}
-//		}
-//	}
-//	Traceln(3, "]")
-//	// end trace
-//	// begin check the next head
-//	lastDelKeepHead := it+1 == nt
-//	lastHead := false
-//	if lastDelKeepHead {
-//		// last del head
-//		if isDel {
-//			headList = r.keepHead
-//			nt = len(headList)
-//			if nt == 0 {
-//				lastHead = true
-//			}
-//		} else {
-//			lastHead = true
-//		}
-//	}
-//	// End next check next head, if lastDelHead the headList == r.keephead
-//	// check in head stored environment map
-//	ie := 0
-//	len_ie := 0
-//	if env1 == nil {
-//		senv = envMap.OutBindings
-//		// senv, ok := (*head.EMap)[ienv]
-//		//	if ok {
-//		TraceEMap(4, 4, head, envMap)
-//		len_ie = len(senv)
-//		// trace
-//		if isDel {
-//			TraceHead(4, 3, "match Del-Head ", head, " Env: [")
-//		} else {
-//			TraceHead(4, 3, "match Keep-Head ", head, " Env: [")
-//		}
-//		first = true
-//		for _, e := range senv {
-//			if first {
-//				first = false
-//			} else {
-//				Trace(4, ", ")
-//			}
-//			if e != nil {
-//				TraceEnv(4, e.InBinding)
-//			}
-//		}
-//		Traceln(4, "]")
-
-//		// End trace
-
-//		if lastHead {
-//			ie = len_ie
-//		} else {
-//			if lastDelKeepHead {
-//				for ; ie < len_ie; ie++ {
-//					envOut := senv[ie]
-//					if envOut != nil {
-//						chr := chrList[ie]
-//						mark = traceMarkCHR(chr)
-//						if mark {
-
-//							ok = traceMatchDelKeepHead(false, rs, r, headList, 0, nt, envOut, nil)
-
-//							if ok {
-//								return ok
-//							}
-//							traceUnmarkDelCHR(chr)
-//						}
-//					}
-//				}
-//			} else { // not a last Del-Head
-//				for ; ie < len_ie; ie++ {
-//					envOut := senv[ie]
-//					if envOut != nil {
-//						chr := chrList[ie]
-//						mark = markCHR(chr)
-//						if mark {
-//							ok = traceMatchDelKeepHead(isDel, rs, r, headList, it+1, nt, envOut, nil)
-//							if ok {
-//								if !isDel {
-//									unmarkDelCHR(chr)
-//								} else {
-//									chrList[ie] = nil
-//									delConstraint(chr, rs)
-//								}
-//								// not unmarkDelCHR(chr), markt == deleted
-//								return ok
-//							}
-//							traceUnmarkDelCHR(chr)
-//						}
-
-//					}
-//				} // for ; ie < len_ie; ie++
-//			} // ! lastDelHead
-//		} // ! lastHead
-//		//	} else {
-//		//		// head.EMap = &EnvMap{}
-//		//		TraceEMap(4, 4, head)
-//		//		senv = []Bindings{}
-//		//		(*head.EMap)[ienv] = senv
-//		//	}
-//		// End check in head stored environment map
-//	}
-
-//	// normal head-check, start at ie (not at 0 !!)
-//	if isDel {
-//		TraceHeadln(3, 3, "match del-Head ", head, " from: ", ie, " < ", len_chr)
-//	} else {
-//		TraceHeadln(3, 3, "match keep-Head ", head, " from: ", ie, " < ", len_chr)
-//	}
-//	if lastHead {
-//		if isDel {
-//			TraceHeadln(3, 3, "last del-Head ")
-//		} else {
-//			TraceHeadln(3, 3, "last keep-Head ")
-//		}
-//		for ok, ic := false, ie; !ok && ic < len_chr; ic++ {
-//			chr := chrList[ic]
-//			// env = lateRenameVars(env)
-//			env2, ok, mark = traceMarkCHRAndMatchDelHead(r.id, head2, chr, env)
-//			if ok {
-//				if it < EnvCache {
-//					senv[ic] = &EnvMap{InBinding: env2, OutBindings: map[int]*EnvMap{}}
-//				}
-//				// trace senv changes
-
-//				TraceHead(4, 3, "New environment ", "Head: ", head.String(), ", Env: [", ic, "], =")
-//				TraceEnv(4, env2)
-//				Traceln(4, "")
-
-//				ok = traceCheckGuards(rs, r, env2)
-//				if ok {
-//					if !isDel {
-//						traceUnmarkDelCHR(chr)
-//					} else {
-//						chrList[ic] = nil
-//						delConstraint(chr, rs)
-//					}
-//					// (*head.EMap)[ienv] = senv
-//					TraceEMap(4, 4, head, envMap)
-//					return ok
-//				}
-//			} else {
-//				if it < EnvCache {
-//					senv[ic] = nil
-//				}
-//			}
-//			if mark {
-//				traceUnmarkDelCHR(chr)
-//			}
-//		}
-//		// (*head.EMap)[ienv] = senv
-//		TraceEMap(4, 4, head, envMap)
-//		return false
-//	}
-//	if lastDelKeepHead {
-//		for ok, ic := false, ie; !ok && ic < len_chr; ic++ {
-//			chr := chrList[ic]
-//			// env = lateRenameVars(env)
-//			env2, ok, mark = traceMarkCHRAndMatchDelHead(r.id, head2, chr, env)
-//			if ok {
-
-//				// trace senv changes
-
-//				TraceHead(4, 3, "New environment ", "Head: ", head.String(), ", Env: [", ic, "], =")
-//				TraceEnv(4, env2)
-//				Traceln(4, "")
-//				if it < EnvCache {
-//					senv[ic] = &EnvMap{InBinding: env2, OutBindings: map[int]*EnvMap{}}
-
-//					if isDel {
-//						ok = traceMatchDelKeepHead(false, rs, r, headList, 0, nt, senv[ic], nil)
-//					} else {
-//						ok = checkGuards(rs, r, env2)
-//					}
-
-//				} else {
-//					if isDel {
-//						ok = traceMatchDelKeepHead(false, rs, r, headList, 0, nt, nil, env2)
-//					} else {
-//						ok = checkGuards(rs, r, env2)
-//					}
-//				}
-//				if ok {
-//					// (*head.EMap)[ienv] = senv
-//					TraceEMap(4, 4, head, envMap)
-//					if !isDel {
-//						traceUnmarkDelCHR(chr)
-//					} else {
-//						chrList[ic] = nil
-//						delConstraint(chr, rs)
-//					}
-//					return ok
-//				}
-//			} else {
-//				if it < EnvCache {
-//					senv[ic] = nil
-//				}
-//			}
-//			if mark {
-//				traceUnmarkDelCHR(chr)
-//			}
-//		}
-//		// (*head.EMap)[ienv] = senv
-//		TraceEMap(4, 4, head, envMap)
-//		return false
-//	}
-
-//	for ok, ic := false, ie; !ok && ic < len_chr; ic++ {
-
-//		chr := chrList[ic]
-//		// env = lateRenameVars(env)  // ???
-//		env2, ok, mark = traceMarkCHRAndMatchDelHead(r.id, head2, chr, env) // mark chr and Match, if fail unmark chr
-//		if ok {
-
-//			// trace senv changes
-
-//			TraceHead(4, 3, "New environment ", "Head: ", head.String(), ", Env: [", ic, "], =")
-//			TraceEnv(4, env2)
-//			Traceln(4, "")
-//			if it < EnvCache {
-//				senv[ic] = &EnvMap{InBinding: env2, OutBindings: map[int]*EnvMap{}}
-//				ok = traceMatchDelKeepHead(isDel, rs, r, headList, it+1, nt, senv[ic], nil)
-//			} else {
-//				ok = traceMatchDelKeepHead(isDel, rs, r, headList, it+1, nt, nil, env2)
-//			}
-//			if ok {
-//				// not unmarkDelCHR(chr), markt == deleted
-//				// (*head.EMap)[ienv] = senv
-//				TraceEMap(4, 4, head, envMap)
-//				return ok
-//			}
-//		} else {
-//			if it < EnvCache {
-//				senv[ic] = nil
-//			}
-//		}
-//		if mark {
-//			traceUnmarkDelCHR(chr)
-//		}
-//	}
-//	// (*head.EMap)[ienv] = senv
-//	TraceEMap(4, 4, head, envMap)
-//	return false
-//}
-
-// Try to match the del-head 'it' from the 'headlist' ('nt'==len of 'headlist')
-// with the 'ienv'-te environmen 'env'
-// If matching ok, call 'checkGuards'
-func matchDelHead(rs *RuleStore, r *chrRule, headList CList, it int, nt int, envMap *EnvMap, env1 Bindings) (ok bool) {
-	var env Bindings
-	var senv map[int]*EnvMap
-
-	head := headList[it]
-	head2 := head
-	if env1 == nil {
-		env = envMap.InBinding
-	} else {
-		env = env1
-	}
-
-	chrList := readProperConstraintsFromCHR_Store(rs, head, env)
-	len_chr := len(chrList)
-	if len_chr == 0 {
-		// variabel in head
-		if head.Functor == "" {
-			// ###
-			b, ok := GetBinding(head.Args[0].(Variable), env)
-			if !ok {
-				return false
-			}
-			if b.Type() != CompoundType {
-				return false
-			}
-			bc := b.(Compound)
-			head2 = &bc
-			chrList = readProperConstraintsFromCHR_Store(rs, head2, env)
-			len_chr = len(chrList)
-			if len_chr == 0 {
-				return false
-			}
-		} else {
-			return false
-		}
-	}
-	// begin check the next head
-
-	lastHead := it+1 == nt
-
-	// End next check next head, if lastDelHead the headList == r.keephead
-	// check in head stored environment map
-	ie := 0
-	len_ie := 0
-
-	if env1 == nil {
-
-		senv = envMap.OutBindings
-		// senv, ok := (*head.EMap)[ienv]
-		//	if ok {
-		len_ie = len(senv)
-		if lastHead {
-			ie = len_ie
-		}
-		//	} else {
-		//		// senv = []Bindings{}
-		//		envMap.nextBindings[ienv] = &EnvMap{storedBinding: rs.emptyBinding, nextBindings: map[int]*EnvMap{}}
-		//		// (*head.EMap)[ienv] = senv
-		//	}
-		// End check in head stored environment map
-	}
-	// normal head-check, start at ie (not at 0 !!)
-	if lastHead {
-		for ok, ic := false, ie; !ok && ic < len_chr; ic++ {
-			chr := chrList[ic]
-			envNew, ok, mark := markCHRAndMatchDelHead(r.id, head2, chr, env)
-			if ok {
-				if it < EnvCache {
-					senv[ic] = &EnvMap{InBinding: envNew, OutBindings: map[int]*EnvMap{}}
-				}
-				ok = checkGuards(rs, r, envNew)
-				if ok {
-
-					chrList[ic] = nil
-					delConstraint(chr, rs)
-
-					// (*head.EMap)[ienv] = senv
-					return ok
-				}
-
-			} else {
-				if it < EnvCache {
-					senv[ic] = nil
-				}
-			}
-			if mark {
-				unmarkDelCHR(chr)
-			}
-		}
-		// (*head.EMap)[ienv] = senv
-		return false
-	}
-
-	for ok, ic := false, ie; !ok && ic < len_chr; ic++ {
-
-		chr := chrList[ic]
-
-		envNew, ok, mark := markCHRAndMatchDelHead(r.id, head2, chr, env) // mark chr and Match, if fail unmark chr
-		if ok {
-			if it < EnvCache {
-				senv[ic] = &EnvMap{InBinding: envNew, OutBindings: map[int]*EnvMap{}}
-				ok = matchDelHead(rs, r, headList, it+1, nt, senv[ic], nil)
-			} else {
-				ok = matchDelHead(rs, r, headList, it+1, nt, nil, envNew)
-			}
-
-			if ok {
-
-				chrList[ic] = nil
-				delConstraint(chr, rs)
-
-				// not unmarkDelCHR(chr), markt == deleted
-				// (*head.EMap)[ienv] = senv
-				return ok
-			}
-		} else {
-			if it < EnvCache {
-				senv[ic] = nil
-			}
-		}
-		if mark {
-			unmarkDelCHR(chr)
-		}
-	}
-	// (*head.EMap)[ienv] = senv
-	return false
-}
-
-// Try to match the del-head 'it' from the 'headlist' ('nt'==len of 'headlist')
-// with the 'ienv'-te environmen 'env'
-// If matching ok, call 'checkGuards'
-func traceMatchDelHead(rs *RuleStore, r *chrRule, headList CList, it int, nt int, envMap *EnvMap, env1 Bindings) (ok bool) {
-	var env Bindings
-	var senv map[int]*EnvMap
-
-	head := headList[it]
-	head2 := head
-	if env1 == nil {
-		env = envMap.InBinding
-	} else {
-		env = env1
-	}
-
-	chrList := readProperConstraintsFromCHR_Store(rs, head, env)
-	len_chr := len(chrList)
-	if len_chr == 0 {
-		// variabel in head
-		if head.Functor == "" {
-			// ###
-			b, ok := GetBinding(head.Args[0].(Variable), env)
-			if !ok {
-				return false
-			}
-			if b.Type() != CompoundType {
-				return false
-			}
-			bc := b.(Compound)
-			head2 = &bc
-			chrList = readProperConstraintsFromCHR_Store(rs, head2, env)
-			len_chr = len(chrList)
-			if len_chr == 0 {
-				return false
-			}
-		} else {
-			return false
-		}
-	}
-	// begin check the next head
-
-	lastHead := it+1 == nt
-
-	// End next check next head, if lastDelHead the headList == r.keephead
-	// check in head stored environment map
-	ie := 0
-	len_ie := 0
-
-	if env1 == nil {
-
-		senv = envMap.OutBindings
-		// senv, ok := (*head.EMap)[ienv]
-		//	if ok {
-		len_ie = len(senv)
-		if lastHead {
-			ie = len_ie
-		}
-		//	} else {
-		//		// senv = []Bindings{}
-		//		envMap.nextBindings[ienv] = &EnvMap{storedBinding: rs.emptyBinding, nextBindings: map[int]*EnvMap{}}
-		//		// (*head.EMap)[ienv] = senv
-		//	}
-		// End check in head stored environment map
-	}
-	// normal head-check, start at ie (not at 0 !!)
-	if lastHead {
-		for ok, ic := false, ie; !ok && ic < len_chr; ic++ {
-			chr := chrList[ic]
-			envNew, ok, mark := traceMarkCHRAndMatchDelHead(r.id, head2, chr, env)
-			if ok {
-				if it < EnvCache {
-					senv[ic] = &EnvMap{InBinding: envNew, OutBindings: map[int]*EnvMap{}}
-				}
-				ok = traceCheckGuards(rs, r, envNew)
-				if ok {
-
-					chrList[ic] = nil
-					delConstraint(chr, rs)
-
-					// (*head.EMap)[ienv] = senv
-					return ok
-				}
-
-			} else {
-				if it < EnvCache {
-					senv[ic] = nil
-				}
-			}
-			if mark {
-				traceUnmarkDelCHR(chr)
-			}
-		}
-		// (*head.EMap)[ienv] = senv
-		return false
-	}
-
-	for ok, ic := false, ie; !ok && ic < len_chr; ic++ {
-
-		chr := chrList[ic]
-
-		envNew, ok, mark := traceMarkCHRAndMatchDelHead(r.id, head2, chr, env) // mark chr and Match, if fail unmark chr
-		if ok {
-			if it < EnvCache {
-				senv[ic] = &EnvMap{InBinding: envNew, OutBindings: map[int]*EnvMap{}}
-				ok = traceMatchDelHead(rs, r, headList, it+1, nt, senv[ic], nil)
-			} else {
-				ok = traceMatchDelHead(rs, r, headList, it+1, nt, nil, envNew)
-			}
-
-			if ok {
-
-				chrList[ic] = nil
-				delConstraint(chr, rs)
-
-				// not unmarkDelCHR(chr), markt == deleted
-				// (*head.EMap)[ienv] = senv
-				return ok
-			}
-		} else {
-			if it < EnvCache {
-				senv[ic] = nil
-			}
-		}
-		if mark {
-			traceUnmarkDelCHR(chr)
-		}
-	}
-	// (*head.EMap)[ienv] = senv
-	return false
-}
-
-// mark chr - no other head-predicate can match that constraint
-func markCHR(chr *Compound) bool {
-	if chr == nil || chr.IsDeleted {
-		return false
-	}
-	chr.IsDeleted = true
-	return true
-}
-
-func traceMarkCHR(chr *Compound) bool {
-	if chr == nil || chr.IsDeleted {
-		TraceHeadln(4, 3, " Not marked: ", chr)
-		return false
-	}
-	TraceHeadln(3, 3, " Marked: ", chr)
-	chr.IsDeleted = true
-	return true
-}
-
-func traceMarkCHRAndMatchDelHead(id int, head, chr *Compound, env Bindings) (env2 Bindings, ok bool, m bool) {
-	// mark and unmark chr
-	if chr == nil || chr.IsDeleted {
-		return env, false, false
-	}
-	// TraceHeadln(3, 3, "     *** mark del %v, ID: %v\n", chr, chr.Id)
-	chr.IsDeleted = true
-	env2, ok = Match(*head, *chr, env)
-	if ok {
-		TraceHead(3, 3, "Match head ", head, " with CHR ", chr, " (Id: ", chr.Id, ") is ", ok, " (Binding: ")
-		TraceEnv(3, env2)
-		Traceln(3, ")")
-	} else {
-		TraceHead(4, 3, "Match head ", head, " with mark CHR ", chr, " (Id: ", chr.Id, ") is ", ok, " (Binding: ")
-		TraceEnv(4, env2)
-		Traceln(4, ")")
-	}
-	return env2, ok, true
-}
-
-func markCHRAndMatchDelHead(id int, head, chr *Compound, env Bindings) (env2 Bindings, ok bool, m bool) {
-	// mark and unmark chr
-	if chr == nil || chr.IsDeleted {
-		return env, false, false
-	}
-	chr.IsDeleted = true
-	env2, ok = Match(*head, *chr, env)
-	return env2, ok, true
-}
-
-func unmarkDelCHR(chr *Compound) {
-	chr.IsDeleted = false
-	return
-}
-
-func traceUnmarkDelCHR(chr *Compound) {
-	chr.IsDeleted = false
-	TraceHeadln(4, 3, "unmark del ", chr, ", ID: ", chr.Id)
-	return
-}
-
-func traceMarkCHRAndMatchKeepHead(id int, head, chr *Compound, env Bindings) (env2 Bindings, ok bool, m bool) {
-	// mark and unmark chr
-
-	if chr == nil || chr.IsDeleted {
-		TraceHeadln(3, 3, " No match, CHR is marked, head ", head, " CHR ", chr)
-		return env, false, false
-	}
-	// TraceHeadln(3, 3, "mark keep ",chr,", ID: ",chr.Id )
-	chr.IsDeleted = true
-	env2, ok = Match(*head, *chr, env)
-	if ok {
-		TraceHead(3, 3, "Match head ", head, " with CHR ", chr, " (Id: ", chr.Id, ") is ", ok, " (Binding: ")
-		TraceEnv(3, env2)
-		Traceln(3, ")")
-	} else {
-		TraceHead(4, 3, "Match head ", head, " with mark CHR ", chr, " (Id: ", chr.Id, ") is ", ok, " (Binding: ")
-		TraceEnv(4, env2)
-		Traceln(4, ")")
-	}
-	return env2, ok, true
-}
-
-func markCHRAndMatchKeepHead(id int, head, chr *Compound, env Bindings) (env2 Bindings, ok bool, m bool) {
-	// mark and unmark chr
-
-	if chr == nil || chr.IsDeleted {
-		return env, false, false
-	}
-	chr.IsDeleted = true
-	env2, ok = Match(*head, *chr, env)
-	return env2, ok, true
-}
-
-func traceUnmarkKeepCHR(chr *Compound) {
-	chr.IsDeleted = false
-	TraceHeadln(4, 3, "unmark keep ", chr, ", ID: ", chr.Id)
-	return
-}
-
-func unmarkKeepCHR(chr *Compound) {
-	chr.IsDeleted = false
-	return
-}
-
-var spaces int = 1
-
-//func matchKeepHead(rs *RuleStore, r *chrRule, his []*big.Int, headList CList, it int, nt int, envMap *EnvMap, env1 Bindings, call KeepCall) (ok bool, req KeepReq) {
-//	var env Bindings
-//	if env1 == nil {
-//		env = envMap.InBinding
-//	} else {
-//		env = env1
-//	}
-//	head := headList[it]
-//	chrList := readProperConstraintsFromCHR_Store(rs, head, env)
-//	len_chr := len(chrList)
-//	keepIdx := r.keepEnv[it]
-//	ie := keepIdx.curIdx
-//	endIdx := keepIdx.endIdx
-//	lastKeepHead := it+1 == nt
-//	for k := 0; k < spaces; k++ {
-//		fmt.Printf("  ")
-//	}
-//	spaces++
-
-//	if lastKeepHead {
-//		fmt.Printf(" >>>[> %s <], Rule: %s, Last-Head(%d): %s,  start: %d, cur: %d, end: %d chr: %d ---\n", keepCall2str(call), r.name, it, head, keepIdx.startIdx, ie, endIdx, len_chr)
-
-//	} else {
-//		fmt.Printf(" >>>[> %s <], Rule: %s, Head(%d): %s,  start: %d, cur: %d, end: %d chr: %d ---\n", keepCall2str(call), r.name, it, head, keepIdx.startIdx, ie, endIdx, len_chr)
-//	}
-//	ok, req = matchKeepHead1(rs, r, his, headList, it, nt, envMap, env1, call)
-//	spaces--
-//	for k := 0; k < spaces; k++ {
-//		fmt.Printf("  ")
-//	}
-
-//	keepIdx = r.keepEnv[it]
-//	if lastKeepHead {
-//		fmt.Printf(" <<<[> %v, %s <] << [> %s <],  Rule: %s, Last-Head(%d): %s, start: %d, cur: %d, end: %d chr: %d ---\n", ok, keepReq2str(req), keepCall2str(call), r.name, it, head,
-//			keepIdx.startIdx, keepIdx.curIdx, keepIdx.endIdx, len_chr)
-//	} else {
-
-//		fmt.Printf(" <<<[> %v, %s <] << [> %s <], Rule: %s, Head(%d): %s, start: %d, cur: %d, end: %d chr: %d ---\n", ok, keepReq2str(req), keepCall2str(call), r.name, it, head,
-//			keepIdx.startIdx, keepIdx.curIdx, keepIdx.endIdx, len_chr)
-//	}
-
-//	return
-//}
-
-func handleLastFail(rs *RuleStore, r *chrRule, his []*big.Int, headList CList, it int, nt int, envMap *EnvMap, env1 Bindings) (ok bool, req KeepReq) {
-	var env Bindings
-
-	head := headList[it]
-	head2 := head
-
-	if env1 == nil {
-		env = envMap.InBinding
-	} else {
-		env = env1
-	}
-
-	chrList := readProperKeepConstraintsFromCHR_Store(rs, head)
-	len_chr := len(chrList)
-	if len_chr == 0 {
-		// variabel in head
-		if head.Functor == "" {
-			b, ok := GetBinding(head.Args[0].(Variable), env)
-			if !ok {
-				return false, R_noNews
-			}
-			if b.Type() != CompoundType {
-				return false, R_noNews
-			}
-			bc := b.(Compound)
-			head2 = &bc
-			chrList = readProperKeepConstraintsFromCHR_Store(rs, head2)
-			len_chr = len(chrList)
-			if len_chr == 0 {
-				return false, R_noNews
-			}
-		} else {
-			return false, R_noNews
-		}
-	}
-
-	// begin check the next head
-
-	keepIdx := r.keepEnv[it]
-	endIdx := keepIdx.endIdx
-	lastKeepHead := it+1 == nt
-
-	// fmt.Printf(" - Rule: %s, Head(%d): %s, call: %s, start: %d, cur: %d, end: %d chr: %d --- \n", r.name, it, head, keepCall2str(call), keepIdx.startIdx, ie, endIdx, len_chr)
-
-	if lastKeepHead {
-		req = R_nextNew
-	} else {
-		if env1 == nil {
-		}
-		ok, req = handleLastFail(rs, r, nil, headList, it+1, nt, nil, env)
-	}
-	switch req {
-	case R_next:
-		keepIdx.startIdx, keepIdx.curIdx = 0, 0
-		if endIdx == 0 {
-			// fmt.Printf("#B# %s.endidx[%d] = %d, lenChr= %d\n", r.name, it, endIdx, len_chr)
-			keepIdx.endIdx = len_chr
-		}
-		return false, R_next
-
-	case R_nextNew:
-		if endIdx < len_chr {
-			// News
-			// fmt.Printf("#C# %s.endidx[%d] = %d, lenChr= %d\n", r.name, it, endIdx, len_chr)
-			keepIdx.startIdx, keepIdx.curIdx = endIdx, endIdx
-			keepIdx.endIdx = len_chr
-			return false, R_next
-		} else {
-			// old U new
-			keepIdx.startIdx, keepIdx.curIdx = 0, 0
-			return false, R_nextNew
-		}
-	}
-
-	return false, R_noNews
-}
-
-// Try to match the keep-head 'it' from the 'headlist' ('nt'==len of 'headlist')
-// with the 'ienv'-te environmen 'env'
-// If matching for all keep-heads ok, call 'checkGuards'
-func matchKeepHead(rs *RuleStore, r *chrRule, his []*big.Int, headList CList, it int, nt int, envMap *EnvMap, env1 Bindings, call KeepCall) (ok bool, req KeepReq) {
-	var env2 Bindings
-	var env Bindings
-	var senv map[int]*EnvMap
-	var mark bool
-
-	head := headList[it]
-	head2 := head
-
-	if env1 == nil {
-		env = envMap.InBinding
-	} else {
-		env = env1
-	}
-
-	chrList := readProperKeepConstraintsFromCHR_Store(rs, head)
-	len_chr := len(chrList)
-	if len_chr == 0 {
-		// variabel in head
-		if head.Functor == "" {
-			b, ok := GetBinding(head.Args[0].(Variable), env)
-			if !ok {
-				return false, R_noNews
-			}
-			if b.Type() != CompoundType {
-				return false, R_noNews
-			}
-			bc := b.(Compound)
-			head2 = &bc
-			chrList = readProperKeepConstraintsFromCHR_Store(rs, head2)
-			len_chr = len(chrList)
-			if len_chr == 0 {
-				return false, R_noNews
-			}
-		} else {
-			return false, R_noNews
-		}
-	}
-
-	// begin check the next head
-
-	keepIdx := r.keepEnv[it]
-	ie := keepIdx.curIdx
-	endIdx := keepIdx.endIdx
-	if endIdx > len_chr {
-		fmt.Printf(" !!!!!!!! #a# %s.endidx[%d] = %d, lenChr= %d\n", r.name, it, endIdx, len_chr)
-	} /*else {
-		fmt.Printf("#a# %s.endidx[%d] = %d, lenChr= %d\n", r.name, it, endIdx, len_chr)
-	}*/
-	lastKeepHead := it+1 == nt
-
-	// fmt.Printf(" - Rule: %s, Head(%d): %s, call: %s, start: %d, cur: %d, end: %d chr: %d --- \n", r.name, it, head, keepCall2str(call), keepIdx.startIdx, ie, endIdx, len_chr)
-
-	// End next check next head
-	// check in head stored environment map
-	call_1 := C_noLast
-	len_ie := 0
-	if env1 == nil {
-		senv = envMap.OutBindings
-		len_ie = len(senv)
-		for ; ie < len_ie; ie++ {
-			envOut := senv[ie]
-			if envOut != nil {
-				chr := chrList[ie]
-				if lastKeepHead {
-					// env2, ok, mark = traceMarkCHRAndMatchKeepHead(r.id, head2, chr, env)
-					// env2, ok, mark = markCHRAndMatchKeepHead(r.id, head2, chr, env)
-					mark = markCHR(chr)
-					if mark {
-						//						if it < EnvCache {
-						//							senv[ie] = &EnvMap{InBinding: env2, OutBindings: map[int]*EnvMap{}}
-						//						}
-						ok = checkGuards(rs, r, envOut.InBinding)
-						unmarkKeepCHR(chr)
-						if ok {
-							keepIdx.curIdx = ie + 1
-							return ok, R_next
-						}
-					}
-					//					else {
-					//						if it < EnvCache {
-					//							senv[ie] = nil
-					//						}
-					//					}
-					//					if mark {
-					//						traceUnmarkKeepCHR(chr)
-					//					}
-				} else { // not last keepHead
-					mark = markCHR(chr)
-					if mark {
-						if call == C_last && ie+1 == endIdx {
-							call_1 = C_last
-						}
-						ok, req = matchKeepHead(rs, r, nil, headList, it+1, nt, envOut, nil, call_1)
-						unmarkKeepCHR(chr)
-						if ok {
-							keepIdx.curIdx = ie
-							return ok, R_next
-						}
-						switch req {
-						case R_next:
-							if call_1 == C_last {
-								// reset
-								keepIdx.curIdx = keepIdx.startIdx
-								return false, R_next
-							}
-						case R_nextNew:
-							if endIdx < len_chr {
-								// News
-								// fmt.Printf("#b# %s.endidx[%d] = %d, lenChr= %d\n", r.name, it, endIdx, len_chr)
-								keepIdx.startIdx, keepIdx.curIdx, ie = endIdx, endIdx, endIdx-1 // for .... ie++ { ...}
-								keepIdx.endIdx, endIdx = len_chr, len_chr
-								return false, R_next
-							} else {
-								// old U new
-								keepIdx.startIdx, keepIdx.curIdx = 0, 0
-								return false, R_nextNew
-							}
-						case R_noNews:
-							keepIdx.curIdx = ie
-							return false, R_noNews
-						}
-					}
-				}
-			}
-		}
-		// TraceHeadln(3, 3, "End check stored environment, ie=", ie)
-	} // End check in head stored environment map
-
-	// ###
-	// normal head-check, start at ie (not at 0 !!)
-
-	if lastKeepHead {
-		// TraceHeadln(3, 3, "Start last Keep Head from:", ie, " to:", endIdx)
-		for ic := ie; ic < endIdx; ic++ {
-			// fmt.Printf("#z# %s . len_chr= %d, endIdx[%d]= %d, ic= %d ##\n", r.name, len_chr, it, endIdx, ic)
-			chr := chrList[ic]
-			env2, ok, mark = markCHRAndMatchKeepHead(r.id, head2, chr, env)
-			// env2, ok, mark = markCHRAndMatchKeepHead(r.id, head2, chr, env)
-			if ok {
-				if it < EnvCache {
-					senv[ic] = &EnvMap{InBinding: env2, OutBindings: map[int]*EnvMap{}}
-				}
-				ok = checkGuards(rs, r, env2)
-				if ok {
-					unmarkKeepCHR(chr)
-					// (*head.EMap)[ienv] = senv
-					keepIdx.curIdx = ic + 1
-					return ok, R_next
-				}
-			} else {
-				if it < EnvCache {
-					senv[ic] = nil
-				}
-			}
-			if mark {
-				unmarkKeepCHR(chr)
-			}
-		}
-		// TraceHeadln(3, 3, "End check last Keep Head")
-		// (*head.EMap)[ienv] = senv
-		if call == C_last {
-			if endIdx < len_chr {
-				// News
-				keepIdx.startIdx, keepIdx.curIdx = endIdx, endIdx
-				keepIdx.endIdx = len_chr
-				return false, R_next
-			} else {
-				// old U new
-				keepIdx.startIdx, keepIdx.curIdx = 0, 0
-				return false, R_nextNew
-			}
-		}
-		keepIdx.curIdx = keepIdx.startIdx
-		return false, R_next
-	}
-
-	// TraceHeadln(3, 3, "Start check Keep Head from:", ie, " to:", endIdx)
-	for ic := ie; ic < endIdx; ic++ {
-		chr := chrList[ic]
-		env2, ok, mark = markCHRAndMatchKeepHead(r.id, head2, chr, env)
-		// env2, ok, mark = markCHRAndMatchKeepHead(r.id, head2, chr, env) // mark chr and Match, if fail unmark chr
-		if ok {
-			if call == C_last && ic+1 == len_chr {
-				call_1 = C_last
-			} // else call_1 == C_noLast
-			if it < EnvCache {
-				senv[ic] = &EnvMap{InBinding: env2, OutBindings: map[int]*EnvMap{}}
-				ok, req = matchKeepHead(rs, r, nil, headList, it+1, nt, senv[ic], nil, call_1)
-			} else {
-				ok, req = matchKeepHead(rs, r, nil, headList, it+1, nt, nil, env2, call_1)
-			}
-			unmarkKeepCHR(chr)
-			if ok {
-				keepIdx.curIdx = ic
-				return ok, R_next
-			}
-			switch req {
-			case R_next:
-				if call_1 == C_last {
-					// reset
-					keepIdx.curIdx = keepIdx.startIdx
-					return false, R_next
-				}
-			case R_nextNew: // only if call_1 == C_last
-				if endIdx < len_chr {
-					// News
-					keepIdx.startIdx, keepIdx.curIdx = endIdx, endIdx
-					keepIdx.endIdx = len_chr
-					return false, R_next
-				} else {
-					// old U new
-					keepIdx.startIdx, keepIdx.curIdx = 0, 0
-					return false, R_nextNew
-				}
-			case R_noNews:
-				keepIdx.curIdx = ic
-				return false, R_noNews
-			}
-		} else {
-			if it < EnvCache {
-				senv[ic] = nil
-			}
-		}
-		if mark {
-			unmarkDelCHR(chr)
-		}
-	}
-	// TraceHeadln(3, 3, "End check Keep Head from:", ie, " to:", endIdx)
-
-	if call == C_last && call_1 == C_noLast {
-		ok, req = handleLastFail(rs, r, nil, headList, it+1, nt, nil, env)
-		switch req {
-		case R_next:
-			keepIdx.startIdx, keepIdx.curIdx = 0, 0
-			return false, req
-		case R_nextNew:
-			if endIdx < len_chr {
-				// News
-				keepIdx.startIdx, keepIdx.curIdx = endIdx, endIdx
-				keepIdx.endIdx = len_chr
-				return false, R_next
-			} else {
-				// old U new
-				keepIdx.startIdx, keepIdx.curIdx = 0, 0
-				return false, R_nextNew
-			}
-		case R_noNews:
-			return false, R_noNews
-		}
-
-	}
-
-	// (*head.EMap)[ienv] = senv
-	return false, R_next
-}
-
-func traceHandleLastFail(rs *RuleStore, r *chrRule, his []*big.Int, headList CList, it int, nt int, envMap *EnvMap, env1 Bindings) (ok bool, req KeepReq) {
-	var env Bindings
-
-	head := headList[it]
-	head2 := head
-
-	if env1 == nil {
-		env = envMap.InBinding
-	} else {
-		env = env1
-	}
-
-	chrList := readProperKeepConstraintsFromCHR_Store(rs, head)
-	TraceHead(4, 3, "match keep-Head ", head, " with [")
-	len_chr := len(chrList)
-	if len_chr == 0 {
-		// variabel in head
-		if head.Functor == "" {
-			Trace(4, " {HeadVariable=", head.Args[0].(Variable), "}")
-			b, ok := GetBinding(head.Args[0].(Variable), env)
-			if !ok {
-				Traceln(3, "] - empty chr (Variable not bind)")
-				return false, R_noNews
-			}
-			if b.Type() != CompoundType {
-				Traceln(3, "] - empty chr (Variable Binding!=CompoundType)", b)
-				return false, R_noNews
-			}
-			bc := b.(Compound)
-			head2 = &bc
-			chrList = readProperKeepConstraintsFromCHR_Store(rs, head2)
-			len_chr = len(chrList)
-			if len_chr == 0 {
-				Traceln(3, "] - empty chr (Variable=) ", b)
-				return false, R_noNews
-			}
-		} else {
-			Traceln(3, "] - empty chr")
-			return false, R_noNews
-		}
-	}
-	// begin trace
-	first := true
-	for _, c := range chrList {
-		if first {
-			Trace(4, c)
-			first = false
-		} else {
-			Trace(4, ", ", c)
-		}
-	}
-	Traceln(4, "]")
-	// end trace
-	// begin check the next head
-
-	keepIdx := r.keepEnv[it]
-	endIdx := keepIdx.endIdx
-	lastKeepHead := it+1 == nt
-	TraceHeadln(4, 4, " last keep head = ", lastKeepHead)
-
-	// fmt.Printf(" - Rule: %s, Head(%d): %s, call: %s, start: %d, cur: %d, end: %d chr: %d --- \n", r.name, it, head, keepCall2str(call), keepIdx.startIdx, ie, endIdx, len_chr)
-
-	if lastKeepHead {
-		req = R_nextNew
-	} else {
-		ok, req = traceHandleLastFail(rs, r, nil, headList, it+1, nt, nil, env)
-	}
-	switch req {
-	case R_next:
-		keepIdx.startIdx, keepIdx.curIdx = 0, 0
-		if endIdx == 0 {
-			keepIdx.endIdx = len_chr
-		}
-		return false, R_next
-
-	case R_nextNew:
-		if endIdx < len_chr {
-			// News
-			keepIdx.startIdx, keepIdx.curIdx = endIdx, endIdx
-			keepIdx.endIdx = len_chr
-			return false, R_next
-		} else {
-			// old U new
-			keepIdx.startIdx, keepIdx.curIdx = 0, 0
-			return false, R_nextNew
-		}
-	}
-
-	return false, R_noNews
-}
-
-// Try to match and trace the keep-head 'it' from the 'headlist' ('nt'==len of 'headlist')
-// with the 'ienv'-te environmen 'env'
-// If matching for all keep-heads ok, call 'checkGuards'
-func traceMatchKeepHead(rs *RuleStore, r *chrRule, his []*big.Int, headList CList, it int, nt int, envMap *EnvMap, env1 Bindings, call KeepCall) (ok bool, req KeepReq) {
-	var env2 Bindings
-	var env Bindings
-	var senv map[int]*EnvMap
-	var mark bool
-
-	head := headList[it]
-	head2 := head
-
-	if env1 == nil {
-		env = envMap.InBinding
-	} else {
-		env = env1
-	}
-
-	chrList := readProperKeepConstraintsFromCHR_Store(rs, head)
-	TraceHead(4, 3, "match keep-Head ", head, " with [")
-	len_chr := len(chrList)
-	if len_chr == 0 {
-		// variabel in head
-		if head.Functor == "" {
-			Trace(4, " {HeadVariable=", head.Args[0].(Variable), "}")
-			b, ok := GetBinding(head.Args[0].(Variable), env)
-			if !ok {
-				Traceln(3, "] - empty chr (Variable not bind)")
-				return false, R_noNews
-			}
-			if b.Type() != CompoundType {
-				Traceln(3, "] - empty chr (Variable Binding!=CompoundType)", b)
-				return false, R_noNews
-			}
-			bc := b.(Compound)
-			head2 = &bc
-			chrList = readProperKeepConstraintsFromCHR_Store(rs, head2)
-			len_chr = len(chrList)
-			if len_chr == 0 {
-				Traceln(3, "] - empty chr (Variable=) ", b)
-				return false, R_noNews
-			}
-		} else {
-			Traceln(3, "] - empty chr")
-			return false, R_noNews
-		}
-	}
-	// begin trace
-	first := true
-	for _, c := range chrList {
-		if first {
-			Trace(4, c)
-			first = false
-		} else {
-			Trace(4, ", ", c)
-		}
-	}
-	Traceln(4, "]")
-	// end trace
-	// begin check the next head
-
-	keepIdx := r.keepEnv[it]
-	ie := keepIdx.curIdx
-	endIdx := keepIdx.endIdx
-	lastKeepHead := it+1 == nt
-	TraceHeadln(4, 4, " last keep head = ", lastKeepHead)
-
-	// fmt.Printf(" - Rule: %s, Head(%d): %s, call: %s, start: %d, cur: %d, end: %d chr: %d --- \n", r.name, it, head, keepCall2str(call), keepIdx.startIdx, ie, endIdx, len_chr)
-
-	// End next check next head
-	// check in head stored environment map
-	call_1 := C_noLast
-	len_ie := 0
-	if env1 == nil {
-		senv = envMap.OutBindings
-		TraceEMap(4, 4, head, envMap)
-		len_ie = len(senv)
-		TraceHeadln(4, 4, " len env = ", len_ie)
-		for ; ie < len_ie; ie++ {
-			envOut := senv[ie]
-			if envOut != nil {
-				chr := chrList[ie]
-				if lastKeepHead {
-					// env2, ok, mark = traceMarkCHRAndMatchKeepHead(r.id, head2, chr, env)
-					// env2, ok, mark = markCHRAndMatchKeepHead(r.id, head2, chr, env)
-					TraceHeadln(4, 4, " ie == len_ie == ", ie, " = ", len_ie)
-					mark = traceMarkCHR(chr)
-					if mark {
-						//						if it < EnvCache {
-						//							senv[ie] = &EnvMap{InBinding: env2, OutBindings: map[int]*EnvMap{}}
-						//						}
-						ok = checkGuards(rs, r, envOut.InBinding)
-						traceUnmarkKeepCHR(chr)
-						if ok {
-							keepIdx.curIdx = ie + 1
-							return ok, R_next
-						}
-					}
-					//					else {
-					//						if it < EnvCache {
-					//							senv[ie] = nil
-					//						}
-					//					}
-					//					if mark {
-					//						traceUnmarkKeepCHR(chr)
-					//					}
-				} else { // not last keepHead
-					// trace
-					TraceHead(4, 3, "match Keep-Head ", head, " Env: [")
-					first = true
-					for _, e := range senv {
-						if first {
-							first = false
-						} else {
-							Trace(4, ", ")
-						}
-						if e == nil {
-							Trace(4, "e= NIL")
-						} else {
-							TraceEnv(4, e.InBinding)
-						}
-					}
-					Traceln(4, "]")
-
-					// End trace
-					mark = traceMarkCHR(chr)
-					if mark {
-						if call == C_last && ie+1 == endIdx {
-							call_1 = C_last
-						}
-						ok, req = traceMatchKeepHead(rs, r, nil, headList, it+1, nt, envOut, nil, call_1)
-						traceUnmarkKeepCHR(chr)
-						if ok {
-							keepIdx.curIdx = ie
-							return ok, R_next
-						}
-						switch req {
-						case R_next:
-							if call_1 == C_last {
-								// reset
-								keepIdx.curIdx = keepIdx.startIdx
-								TraceHeadln(4, 4, " req=next, reset curIdx <- startIdx ", keepIdx.startIdx)
-								return false, R_next
-							}
-						case R_nextNew:
-							if endIdx < len_chr {
-								// News
-								TraceHeadln(4, 4, " req=nextNew, new CHR found, startIdx, curIdx <- endIdx ", endIdx, " endIdx <- lenChr ", len_chr)
-								keepIdx.startIdx, keepIdx.curIdx, ie = endIdx, endIdx, endIdx-1 // for .... ie++ { ...}
-								keepIdx.endIdx, endIdx = len_chr, len_chr
-								return false, R_next
-							} else {
-								// old U new
-								TraceHeadln(4, 4, " req=nextNew, no new CHR found, old U new ")
-								keepIdx.startIdx, keepIdx.curIdx = 0, 0
-								return false, R_nextNew
-							}
-						case R_noNews:
-							TraceHeadln(4, 4, " req=noNews ")
-							keepIdx.curIdx = ie
-							return false, R_noNews
-						}
-					}
-				}
-			}
-		}
-		TraceHeadln(3, 3, "End check stored environment, ie=", ie)
-	} // End check in head stored environment map
-
-	// ###
-	// normal head-check, start at ie (not at 0 !!)
-
-	if lastKeepHead {
-		TraceHeadln(3, 3, "Start last Keep Head from:", ie, " to:", endIdx)
-		for ic := ie; ic < endIdx; ic++ {
-			chr := chrList[ic]
-			env2, ok, mark = traceMarkCHRAndMatchKeepHead(r.id, head2, chr, env)
-			// env2, ok, mark = markCHRAndMatchKeepHead(r.id, head2, chr, env)
-			if ok {
-				if it < EnvCache {
-					senv[ic] = &EnvMap{InBinding: env2, OutBindings: map[int]*EnvMap{}}
-				}
-
-				TraceHead(4, 3, "New environment ", "Head: ", head.String(), ", Env [", ic, "], =")
-				TraceEnv(4, env2)
-				Traceln(4, "")
-
-				ok = traceCheckGuards(rs, r, env2)
-				if ok {
-					traceUnmarkKeepCHR(chr)
-					// (*head.EMap)[ienv] = senv
-					// TraceEMap(4, 4, head, envMap)
-					keepIdx.curIdx = ic + 1
-					return ok, R_next
-				}
-			} else {
-				if it < EnvCache {
-					senv[ic] = nil
-				}
-			}
-			if mark {
-				traceUnmarkKeepCHR(chr)
-			}
-		}
-		TraceHeadln(3, 3, "End check last Keep Head")
-		// (*head.EMap)[ienv] = senv
-		if call == C_last {
-			if endIdx < len_chr {
-				// News
-				TraceHeadln(3, 3, " lastKeep & call=last, new Chr found, startIdx, keepIdx <- endIdx ", endIdx, " endIdx <- lenChr ", len_chr)
-				keepIdx.startIdx, keepIdx.curIdx = endIdx, endIdx
-				keepIdx.endIdx = len_chr
-				return false, R_next
-			} else {
-				// old U new
-				TraceHeadln(3, 3, " lastKeep & call=last, no new Chr found, old U new ")
-				keepIdx.startIdx, keepIdx.curIdx = 0, 0
-				return false, R_nextNew
-			}
-		}
-		TraceHeadln(3, 3, " lastKeep & call=noLast, reset curIdx <- startIdx ", keepIdx.startIdx)
-		keepIdx.curIdx = keepIdx.startIdx
-		return false, R_next
-	}
-
-	TraceHeadln(3, 3, "Start check Keep Head from:", ie, " to:", endIdx)
-	for ic := ie; ic < endIdx; ic++ {
-		chr := chrList[ic]
-		env2, ok, mark = traceMarkCHRAndMatchKeepHead(r.id, head2, chr, env)
-		// env2, ok, mark = markCHRAndMatchKeepHead(r.id, head2, chr, env) // mark chr and Match, if fail unmark chr
-		if ok {
-
-			// trace senv changes
-
-			TraceHead(4, 3, "New environment ", "Head: ", head.String(), ", Env:  [", ic, "], =")
-			TraceEnv(4, env2)
-			Traceln(4, "")
-
-			if call == C_last && ic+1 == len_chr {
-				call_1 = C_last
-			} // else call_1 == C_noLast
-			if it < EnvCache {
-				senv[ic] = &EnvMap{InBinding: env2, OutBindings: map[int]*EnvMap{}}
-				ok, req = traceMatchKeepHead(rs, r, nil, headList, it+1, nt, senv[ic], nil, call_1)
-			} else {
-				ok, req = traceMatchKeepHead(rs, r, nil, headList, it+1, nt, nil, env2, call_1)
-			}
-			traceUnmarkKeepCHR(chr)
-			if ok {
-				keepIdx.curIdx = ic
-				return ok, R_next
-			}
-			switch req {
-			case R_next:
-				if call_1 == C_last {
-					// reset
-					TraceHeadln(3, 3, " req= next, Reset curIdx <- startIdx ", keepIdx.startIdx)
-					keepIdx.curIdx = keepIdx.startIdx
-					return false, R_next
-				}
-			case R_nextNew: // only if call_1 == C_last
-				if endIdx < len_chr {
-					// News
-					TraceHeadln(3, 3, " req= nextNew, new CHR found startIdx, curIdx <- startIdx ", endIdx, " endIdx <- lenChr ", len_chr)
-					keepIdx.startIdx, keepIdx.curIdx = endIdx, endIdx
-					keepIdx.endIdx = len_chr
-					return false, R_next
-				} else {
-					// old U new
-					TraceHeadln(3, 3, " req= nextNew, old U new ")
-					keepIdx.startIdx, keepIdx.curIdx = 0, 0
-					return false, R_nextNew
-				}
-			case R_noNews:
-				TraceHeadln(3, 3, " req= noNews, curIdx = ", ic)
-				keepIdx.curIdx = ic
-				return false, R_noNews
-			}
-		} else {
-			if it < EnvCache {
-				senv[ic] = nil
-			}
-		}
-		if mark {
-			traceUnmarkDelCHR(chr)
-		}
-	}
-	TraceHeadln(3, 3, "End check Keep Head from:", ie, " to:", endIdx)
-
-	if call == C_last && call_1 == C_noLast {
-		ok, req = traceHandleLastFail(rs, r, nil, headList, it+1, nt, nil, env)
-		switch req {
-		case R_next:
-			TraceHeadln(3, 3, " after lastFail, req=next old U new")
-			keepIdx.startIdx, keepIdx.curIdx = 0, 0
-			return false, req
-		case R_nextNew:
-			if endIdx < len_chr {
-				// News
-				TraceHeadln(3, 3, " after lastFail, req=nextNew, new CHR found startIdx <- endIdx ", endIdx, " endIdx <- lenChr ", len_chr)
-				keepIdx.startIdx, keepIdx.curIdx = endIdx, endIdx
-				keepIdx.endIdx = len_chr
-				return false, R_next
-			} else {
-				// old U new
-				TraceHeadln(3, 3, " after lastFail, req=nextNew, no new CHR found, old U new ")
-				keepIdx.startIdx, keepIdx.curIdx = 0, 0
-				return false, R_nextNew
-			}
-		case R_noNews:
-			TraceHeadln(3, 3, " after lastFail, req=noNew ")
-
-			return false, R_noNews
-		}
-
-	}
-
-	// (*head.EMap)[ienv] = senv
-	return false, R_next
-}
-
-//	var env, env2 Bindings
-//	var senv map[int]*EnvMap
-//	var mark bool
-
-//	if env1 == nil {
-//		env = envMap.InBinding
-//	} else {
-//		env = env1
-//	}
-
-//	head := headList[it]
-//	head2 := head
-//	chrList := readProperConstraintsFromCHR_Store(rs, head, env)
-//	TraceHead(4, 3, "match keep-Head ", head, " with [")
-//	len_chr := len(chrList)
-//	if len_chr == 0 {
-//		// variabel in head
-//		if head.Functor == "" {
-//			// ###
-//			Trace(4, " {HeadVariable=", head.Args[0].(Variable), "}")
-//			b, ok := GetBinding(head.Args[0].(Variable), env)
-//			if !ok {
-//				Traceln(3, "] - empty chr (Variable not bind)")
-//				return false
-//			}
-//			if b.Type() != CompoundType {
-//				Traceln(3, "] - empty chr (Variable!=CompoundType)", b)
-//				return false
-//			}
-//			bc := b.(Compound)
-//			head2 = &bc
-//			chrList = readProperConstraintsFromCHR_Store(rs, head2, env)
-//			len_chr = len(chrList)
-//			if len_chr == 0 {
-//				Traceln(3, "] - empty chr (Variable=) ", b)
-//				return false
-//			}
-//		} else {
-//			Traceln(3, "] - empty chr")
-//			return false
-//		}
-//	}
-//	// begin trace
-//	first := true
-//	for _, c := range chrList {
-//		if first {
-//			Trace(4, c)
-//			first = false
-//		} else {
-//			Trace(4, ", ", c)
-//		}
-//	}
-//	Traceln(4, "]")
-//	// end trace
-//	// begin check the next head
-
-//	lastKeepHead := it+1 == nt
-//	TraceHeadln(4, 4, " last keep head = ", lastKeepHead)
-
-//	// End next check next head
-//	// check in head stored environment map
-//	ie := 0
-//	len_ie := 0
-//	if env1 == nil {
-//		// senv, ok := (*head.EMap)[ienv]
-//		senv = envMap.OutBindings
-//		//	if !ok {
-//		//		TraceHeadln(4, 4, " !!! head: ", head, " with no Emap[ ", ienv, " ]")
-//		//	}
-//		//	if ok {
-//		TraceEMap(4, 4, head, envMap)
-//		len_ie = len(senv)
-//		TraceHeadln(4, 4, " len env = ", len_ie)
-//		if lastKeepHead {
-//			ie = len_ie
-//			TraceHeadln(4, 4, " ie == len_ie == ", ie, " = ", len_ie)
-//		} else {
-//			// trace
-//			TraceHead(4, 3, "match Keep-Head ", head, " Env: [")
-//			first = true
-//			for _, e := range senv {
-//				if first {
-//					first = false
-//				} else {
-//					Trace(4, ", ")
-//				}
-//				TraceEnv(4, e.InBinding)
-//			}
-//			Traceln(4, "]")
-
-//			// End trace
-//			for ; ie < len_ie; ie++ {
-//				envOut := senv[ie]
-//				if envOut != nil {
-//					chr := chrList[ie]
-//					mark = markCHR(chr)
-//					TraceHeadln(4, 4, " mark keep chr:", chr.String(), " = ", mark)
-//					if mark {
-//						ok = traceMatchKeepHead(rs, r, nil, headList, it+1, nt, envOut, nil)
-//						if ok {
-//							traceUnmarkKeepCHR(chr)
-//							return ok
-//						}
-//						traceUnmarkKeepCHR(chr)
-//					}
-//				}
-//			}
-
-//		} // ! lastHead
-//		//	} else { // if !ok
-//		//		// head.EMap = &EnvMap{}
-//		//		senv = []Bindings{}
-//		//		(*head.EMap)[ienv] = senv
-//		//	}
-//		// End check in head stored environment map
-//	}
-//	// normal head-check, start at ie (not at 0 !!)
-//	TraceHeadln(4, 3, "match keep-Head ", head, " from: ", ie, " < ", len_chr)
-//	if lastKeepHead {
-//		for ok, ic := false, ie; !ok && ic < len_chr; ic++ {
-//			chr := chrList[ic]
-//			env2, ok, mark = traceMarkCHRAndMatchKeepHead(r.id, head2, chr, env)
-//			if ok {
-//				if it < EnvCache {
-//					senv[ic] = &EnvMap{InBinding: env2, OutBindings: map[int]*EnvMap{}}
-//				}
-//				// trace senv changes
-
-//				TraceHead(4, 3, "New environment ", "Head: ", head.String(), ", Env [", ic, "], =")
-//				TraceEnv(4, env2)
-//				Traceln(4, "")
-
-//				ok = traceCheckGuards(rs, r, env2)
-//				if ok {
-//					traceUnmarkKeepCHR(chr)
-//					// (*head.EMap)[ienv] = senv
-//					TraceEMap(4, 4, head, envMap)
-//					return ok
-//				}
-//			} else {
-//				if it < EnvCache {
-//					senv[ic] = nil
-//				}
-//			}
-//			if mark {
-//				traceUnmarkKeepCHR(chr)
-//			}
-//		}
-//		// (*head.EMap)[ienv] = senv
-//		TraceEMap(4, 4, head, envMap)
-//		return false
-//	}
-
-//	for ok, ic := false, ie; !ok && ic < len_chr; ic++ {
-
-//		chr := chrList[ic]
-
-//		env2, ok, mark = traceMarkCHRAndMatchKeepHead(r.id, head2, chr, env) // mark chr and Match, if fail unmark chr
-//		if ok {
-
-//			// trace senv changes
-
-//			TraceHead(4, 3, "New environment ", "Head: ", head.String(), ", Env:  [", ic, "], =")
-//			TraceEnv(4, env2)
-//			Traceln(4, "")
-//			if it < EnvCache {
-//				senv[ic] = &EnvMap{InBinding: env2, OutBindings: map[int]*EnvMap{}}
-//				ok = traceMatchKeepHead(rs, r, nil, headList, it+1, nt, senv[ic], nil)
-//			} else {
-//				ok = traceMatchKeepHead(rs, r, nil, headList, it+1, nt, nil, env2)
-//			}
-//			if ok {
-//				traceUnmarkKeepCHR(chr)
-//				// (*head.EMap)[ienv] = senv
-//				TraceEMap(4, 4, head, envMap)
-//				return ok
-//			}
-//		} else {
-//			if it < EnvCache {
-//				senv[ic] = nil
-//			}
-//		}
-//		if mark {
-//			unmarkDelCHR(chr)
-//		}
-//	}
-//	// (*head.EMap)[ienv] = senv
-//	TraceEMap(4, 4, head, envMap)
-//	return false
-//}
-
-// check and trace guards of the rule r with the binding env
-// if all guards are true, fire rule
-func traceCheckGuards(rs *RuleStore, r *chrRule, env Bindings) (ok bool) {
-	for _, g := range r.guard {
-		env2, ok := traceCheckGuard(rs, g, env)
-		if !ok {
-			return false
-		}
-		env = env2
-	}
-	if traceFireRule(rs, r, env) {
-		return true
-	}
-	// dt do setFail
-	return true
-}
-
-// check and trace a guard g with the binding env
-// if guards are true, return the new binding (if ':=', '=' or 'is' guard)
-func traceCheckGuard(rs *RuleStore, g *Compound, env Bindings) (env2 Bindings, ok bool) {
-	TraceHead(3, 3, "check guard: ", g.String())
-	g1 := Substitute(*g, env).(Compound)
-	Trace(3, ", subst: ", g1)
-	if g.Functor == ":=" || g1.Functor == "is" || g1.Functor == "=" {
-		if !(g1.Args[0].Type() == VariableType) {
-			return env, false
-		}
-		a := Eval(g1.Args[1])
-		env2 = AddBinding(g1.Args[0].(Variable), a, env)
-		return env2, true
-	}
-
-	t1 := Eval(g1)
-	Traceln(3, ", eval: ", t1)
-	switch t1.Type() {
-	case BoolType:
-		if t1.(Bool) {
-			return env, true
-		}
-		return env, false
-	case CompoundType:
-		t2 := t1.(Compound)
-		biChrList := readProperConstraintsFromBI_Store(rs, &t2, nil)
-		len_chr := len(biChrList)
-		if len_chr == 0 {
 			return env, false
 		}
-		for _, chr := range biChrList {
-			if Equal(t1, chr) {
-				return env, true
-			}
-		}
-		// to do for the operators(@): ==, !=, <, <=, >, >=, =<
-		// symmetry: x @ y --> y @ x
-		// transitivity: x @ y && y @ z --> x @ z
-		//
-		// case AtomType, IntType, FloatType, StringType:
-		//	case ListType:
-		//	case VariableType:
+		env = env2
 	}
-	return env, false
+	return env, true
 }
 
-// check guards of the rule r with the binding env
-// if all guards are true, fire rule
-func checkGuards(rs *RuleStore, r *chrRule, env Bindings) (ok bool) {
-	for _, g := range r.guard {
-		env2, ok := checkGuard(rs, g, env)
-		if !ok {
-			return false
+func isPartner(c *Compound, partners CList) bool {
+	for _, p := range partners {
+		if p == c {
+			return true
 		}
-		env = env2
-	}
-	if fireRule(rs, r, env) {
-		return true
 	}
-	// dt do setFail
-	return true
+	return false
 }
 
 // check a guard g with the binding env
 // if guards are true, return the new binding (if ':=', '=' or 'is' guard)
-func checkGuard(rs *RuleStore, g *Compound, env Bindings) (env2 Bindings, ok bool) {
-
+func checkGuard(rs *Session, g *Compound, env Bindings) (env2 Bindings, ok bool) {
 	g1 := Substitute(*g, env).(Compound)
-
+	if rs.Trace.On(3) {
+		rs.Trace.Head(3, 3, "check guard: ", g, ", subst: ", g1)
+	}
 	if g.Functor == ":=" || g1.Functor == "is" || g1.Functor == "=" {
 		if !(g1.Args[0].Type() == VariableType) {
+			rs.Trace.Traceln(3, ", no variable")
+			return env, false
+		}
+		a := rs.eval(g1.Args[1])
+		if rs.evalErr != nil {
+			rs.Trace.Traceln(3, ", error")
 			return env, false
 		}
-		a := Eval(g1.Args[1])
+		rs.Trace.Traceln(3, ", bind: ", a)
 		env2 = AddBinding(g1.Args[0].(Variable), a, env)
 		return env2, true
 	}
-	t1 := Eval(g1)
+	t1 := rs.eval(g1)
+	rs.Trace.Traceln(3, ", eval: ", t1)
 	switch t1.Type() {
 	case BoolType:
 		if t1.(Bool) {
@@ -3329,158 +1351,102 @@ func checkGuard(rs *RuleStore, g *Compound, env Bindings) (env2 Bindings, ok boo
 		return env, false
 	case CompoundType:
 		t2 := t1.(Compound)
-		biChrList := readProperConstraintsFromBI_Store(rs, &t2, nil)
-		len_chr := len(biChrList)
-		if len_chr == 0 {
-			return env, false
+		if linearEntailed(rs, t2) {
+			rs.Trace.Traceln(3, "entailed by the linear constraints")
+			return env, true
 		}
+		biChrList := readProperConstraintsFromBI_Store(rs, &t2, nil)
 		for _, chr := range biChrList {
-			if Equal(t1, chr) {
+			if Equal(t1, *chr) {
 				return env, true
 			}
 		}
-		// to do for the operators(@): ==, !=, <, <=, >, >=, =<
+		// the operators(@): ==, !=, <, <=, >, >=, =<
 		// symmetry: x @ y --> y @ x
 		// transitivity: x @ y && y @ z --> x @ z
-		//
-		// case AtomType, IntType, FloatType, StringType:
-		//	case ListType:
-		//	case VariableType:
+		if guardEntailed(rs, t2) {
+			rs.Trace.Traceln(3, "entailed by the built-in store")
+			return env, true
+		}
 	}
 	return env, false
 }
 
-// rule fired and trace with the environment env
-func traceFireRule(rs *RuleStore, rule *chrRule, env Bindings) bool {
-	var biVarEqTerm Bindings
-	biVarEqTerm = nil
-	goals := rule.body
-
-	if goals.Type() == ListType {
-		for _, g := range goals {
-			TraceHead(3, 3, " Goal: ", g.String())
-			g = RenameAndSubstitute(g, rs.RenameRuleVars, env)
-			Traceln(3, " after rename&subst: ", g.String())
-			g = Eval(g)
-
-			if g.Type() == CompoundType {
-				g1 := g.(Compound)
-				if len(g1.Args) == 2 {
-					arg0 := g1.Args[0]
-					arg0ty := arg0.Type()
-					arg1 := g1.Args[1]
-					switch g1.Functor {
-					case ":=", "is", "=":
-						if !(arg0ty == VariableType) {
-							TraceHeadln(1, 3, "Missing Variable in assignment in body: ", g.String(), ", in rule:", rule.name)
-							return false
-						}
-
-						env = AddBinding(arg0.(Variable), arg1, env)
-						TraceHeadln(1, 3, "in fire Rule add Binding: ", arg0.(Variable).String(), " = ", arg1.String())
-						// add assignment or not add assignment - thats the question
-						// up to now the assignment will be added
-					case "==":
-						arg1ty := arg1.Type()
-						if arg0ty == VariableType && arg1ty == VariableType {
-							arg0var := arg0.(Variable)
-							arg1var := arg1.(Variable)
-							if arg0var.Name > arg1var.Name {
-								g1 = CopyCompound(g1)
-								g1.Args[0] = arg1var
-								g1.Args[1] = arg0var
-								g = g1
-								biVarEqTerm = AddBinding(arg1var, arg0var, biVarEqTerm)
-							} else {
-								biVarEqTerm = AddBinding(arg0var, arg1var, biVarEqTerm)
-							}
-						} else if arg0ty == VariableType {
-
-							biVarEqTerm = AddBinding(arg0.(Variable), arg1, biVarEqTerm)
-
-						} else if arg1ty == VariableType {
-							g1 = CopyCompound(g1)
-							g1.Args[0] = arg1
-							g1.Args[1] = arg0
-							g = g1
-							biVarEqTerm = AddBinding(arg1.(Variable), arg0, biVarEqTerm)
-						} else {
-							env2, ok := Match(arg0, arg1, biVarEqTerm)
-							if ok {
-								biVarEqTerm = env2
-							} else {
-								env2, ok := Match(arg1, arg0, biVarEqTerm)
-								if ok {
-									biVarEqTerm = env2
-								}
-							}
-						}
-
-					} // end switch g1.Functor
-				} // end if len(g1.Args) == 2
-				TraceHeadln(3, 3, "Add Goal: ", g)
-				addConstraintToStore(rs, g.(Compound))
-				rs.Result = RStore
-			} else {
-				if g.Type() == BoolType && !g.(Bool) {
-					rs.Result = RFalse
-					return false
-				}
-			}
-		}
-		if biVarEqTerm != nil {
-			substituteStores(rs, biVarEqTerm)
+func substituteStores(rs *Session, biEnv Bindings) {
+	if rs.Tracer != nil {
+		rs.event(Event{Kind: EventStoreSubstituted}, biEnv)
+	}
+	// only the constraints with the bound variables are substituted
+	h := rs.herbrand
+	oldCHR := h.wake(h.bind(biEnv))
+	newCHR := make([]Compound, 0, len(oldCHR))
+	for _, con := range oldCHR {
+		con1, _ := h.resolve(*con)
+		newCHR = append(newCHR, con1.(Compound))
+		markDeleted(rs, con)
+		gcHistory(rs, con)
+	}
+	cause := rs.cause
+	for i, con := range newCHR {
+		if rs.Justify {
+			rs.cause = substCause(oldCHR[i], biEnv)
 		}
+		addConstraintToStore(rs, con)
 	}
-	return true
+	rs.cause = cause
+	substituteDisjunctions(rs)
 }
 
-func substituteStores(rs *RuleStore, biEnv Bindings) {
-	newCHR := []Compound{}
-	for _, aChr := range rs.CHRstore {
-		for _, con := range aChr.varArg {
-			if con != nil && !con.IsDeleted {
-				con1, ok := SubstituteBiEnv(*con, biEnv)
-				if ok && con1.Type() == CompoundType {
-					newCHR = append(newCHR, con1.(Compound))
-					con.IsDeleted = true
-				}
-			}
+// bodyEquation adds the binding of the equation g1 ('==') of a rule body
+// to biVarEqTerm; a variable is moved to the left side of g1
+func bodyEquation(g1 Compound, biVarEqTerm Bindings) (Compound, Bindings) {
+	arg0 := g1.Args[0]
+	arg0ty := arg0.Type()
+	arg1 := g1.Args[1]
+	arg1ty := arg1.Type()
+	if arg0ty == VariableType && arg1ty == VariableType {
+		arg0var := arg0.(Variable)
+		arg1var := arg1.(Variable)
+		if arg0var.Name > arg1var.Name {
+			g1 = CopyCompound(g1)
+			g1.Args[0] = arg1var
+			g1.Args[1] = arg0var
+			biVarEqTerm = AddBinding(arg1var, arg0var, biVarEqTerm)
+		} else {
+			biVarEqTerm = AddBinding(arg0var, arg1var, biVarEqTerm)
 		}
-		for _, con := range aChr.noArg {
-			if con != nil && !con.IsDeleted {
-				con1, ok := SubstituteBiEnv(*con, biEnv)
-				if ok && con1.Type() == CompoundType {
-					newCHR = append(newCHR, con1.(Compound))
-					con.IsDeleted = true
-				}
+	} else if arg0ty == VariableType {
+
+		biVarEqTerm = AddBinding(arg0.(Variable), arg1, biVarEqTerm)
+
+	} else if arg1ty == VariableType {
+		g1 = CopyCompound(g1)
+		g1.Args[0] = arg1
+		g1.Args[1] = arg0
+		biVarEqTerm = AddBinding(arg1.(Variable), arg0, biVarEqTerm)
+	} else {
+		env2, ok := Match(arg0, arg1, biVarEqTerm)
+		if ok {
+			biVarEqTerm = env2
+		} else {
+			env2, ok := Match(arg1, arg0, biVarEqTerm)
+			if ok {
+				biVarEqTerm = env2
+			} else if env3, solved, ok := equationSolver(Eval(Substitute(arg0, biVarEqTerm)),
+				Eval(Substitute(arg1, biVarEqTerm)), nil); solved && ok && env3 != nil {
+				// a linear equation with one unknown, e.g. X + 3 == 10: X == 7
+				g1 = CopyCompound(g1)
+				g1.Args[0] = env3.Var
+				g1.Args[1] = env3.T
+				biVarEqTerm = AddBinding(env3.Var, env3.T, biVarEqTerm)
 			}
 		}
 	}
-	for _, con := range newCHR {
-		addConstraintToStore(rs, con)
-	}
-	/*
-		newBI := []Compound{}
-		for _, aChr := range BuiltInStore {
-			for _, con := range aChr.varArg {
-				if con != nil && !con.IsDeleted {
-					con1, ok := SubstituteBiEnv(*con, biEnv)
-					if ok && con1.Type() == CompoundType {
-						newBI = append(newBI, con1.(Compound))
-						con.IsDeleted = true
-					}
-				}
-			}
-		}
-		for _, con := range newBI {
-			addConstraintToStore(con)
-		}
-	*/
+	return g1, biVarEqTerm
 }
 
 // rule fired with the environment env
-func fireRule(rs *RuleStore, rule *chrRule, env Bindings) bool {
+func fireRule(rs *Session, rule *chrRule, env Bindings) bool {
 	var biVarEqTerm Bindings
 	biVarEqTerm = nil
 	goals := rule.body
@@ -3494,8 +1460,19 @@ func fireRule(rs *RuleStore, rule *chrRule, env Bindings) bool {
 			goals = g2
 		}
 		for _, g := range goals {
-			g = RenameAndSubstitute(g, rs.RenameRuleVars, env)
-			g = Eval(g)
+			if rs.Trace.On(3) {
+				rs.Trace.Head(3, 3, " Goal: ", g)
+				g = RenameAndSubstitute(g, rs.RenameRuleVars, env)
+				rs.Trace.Traceln(3, " after rename&subst: ", g)
+			} else {
+				g = RenameAndSubstitute(g, rs.RenameRuleVars, env)
+			}
+			if isDisjunction(g) {
+				addDisjunction(rs, g.(Compound))
+				rs.Result = RStore
+				continue
+			}
+			g = rs.eval(g)
 
 			if g.Type() == CompoundType {
 				g1 := g.(Compound)
@@ -3506,52 +1483,29 @@ func fireRule(rs *RuleStore, rule *chrRule, env Bindings) bool {
 					switch g1.Functor {
 					case ":=", "is", "=":
 						if !(arg0ty == VariableType) {
-							TraceHeadln(1, 3, "Missing Variable in assignment in body: ", g.String(), ", in rule:", rule.name)
+							rs.Trace.Headln(1, 3, "Missing Variable in assignment in body: ", g.String(), ", in rule:", rule.name)
 							return false
 						}
 
 						env = AddBinding(arg0.(Variable), arg1, env)
+						if rs.Trace.On(1) {
+							rs.Trace.Headln(1, 3, "in fire Rule add Binding: ", arg0, " = ", arg1)
+						}
 						// add assignment or not add assignment - thats the question
 						// up to now the assignment will be added
 					case "==":
-						arg1ty := arg1.Type()
-						if arg0ty == VariableType && arg1ty == VariableType {
-							arg0var := arg0.(Variable)
-							arg1var := arg1.(Variable)
-							if arg0var.Name > arg1var.Name {
-								g1 = CopyCompound(g1)
-								g1.Args[0] = arg1var
-								g1.Args[1] = arg0var
-								g = g1
-								biVarEqTerm = AddBinding(arg1var, arg0var, biVarEqTerm)
-							} else {
-								biVarEqTerm = AddBinding(arg0var, arg1var, biVarEqTerm)
-							}
-						} else if arg0ty == VariableType {
-
-							biVarEqTerm = AddBinding(arg0.(Variable), arg1, biVarEqTerm)
-
-						} else if arg1ty == VariableType {
-							g1 = CopyCompound(g1)
-							g1.Args[0] = arg1
-							g1.Args[1] = arg0
-							g = g1
-							biVarEqTerm = AddBinding(arg1.(Variable), arg0, biVarEqTerm)
-						} else {
-							env2, ok := Match(arg0, arg1, biVarEqTerm)
-							if ok {
-								biVarEqTerm = env2
-							} else {
-								env2, ok := Match(arg1, arg0, biVarEqTerm)
-								if ok {
-									biVarEqTerm = env2
-								}
-							}
-						}
+						g1, biVarEqTerm = bodyEquation(g1, biVarEqTerm)
+						g = g1
 					} // end switch g1.Functor
 				} // end if len(g1.Args) == 2
+				rs.Trace.Headln(3, 3, "Add Goal: ", g)
 				addConstraintToStore(rs, g.(Compound))
 				rs.Result = RStore
+				if !linearConsistent(rs, g.(Compound)) {
+					rs.Trace.Headln(1, 3, "no solution of the linear constraints: ", g)
+					rs.Result = RFalse
+					return false
+				}
 			} else {
 				if g.Type() == BoolType && !g.(Bool) {
 					rs.Result = RFalse
@@ -126,7 +126,6 @@ func TestCHR12(t *testing.T) {
 //}
 */
 func TestCHRRule00(t *testing.T) {
-	CHRtrace = 0
 	rs := MakeRuleStore()
 	ok := rs.ParseStringCHRRulesGoals(`
 	sum([], S) <=> S == 0 .
@@ -138,14 +137,12 @@ func TestCHRRule00(t *testing.T) {
 //	sum([1,X,3], 6).
 //	#result: X == 2 .
 	`)
-	CHRtrace = 0
 	if !ok {
 		t.Error("TestCHRRule00 fails")
 	}
 }
 
 func TestCHRRule01(t *testing.T) {
-	CHRtrace = 0
 	rs := MakeRuleStore()
 	ok := rs.ParseStringCHRRulesGoals(`
 	prime01 @ prime(N) ==> N>2 | prime(N-1).
@@ -161,7 +158,6 @@ func TestCHRRule01(t *testing.T) {
 }
 
 func TestCHRRule02(t *testing.T) {
-	CHRtrace = 0
 	rs := MakeRuleStore()
 	ok := rs.ParseStringCHRRulesGoals(`
 	// first rule set with assignment
@@ -219,7 +215,6 @@ func TestCHRRule02(t *testing.T) {
 }
 
 func TestCHRRule04(t *testing.T) {
-	CHRtrace = 0
 	rs := MakeRuleStore()
 	ok := rs.ParseStringCHRRulesGoals(`
 	fib01@ upto(A) ==> fib(0,1), fib(1,1).
@@ -237,7 +232,6 @@ func TestCHRRule04(t *testing.T) {
 }
 
 func TestCHRRule05(t *testing.T) {
-	CHRtrace = 0
 	rs := MakeRuleStore()
 	ok := rs.ParseStringCHRRulesGoals(`
 	leq_reflexivity  @ leq(X,X) <=> true.
@@ -253,7 +247,6 @@ func TestCHRRule05(t *testing.T) {
 }
 
 func TestCHRRule06(t *testing.T) {
-	CHRtrace = 0
 	rs := MakeRuleStore()
 	ok := rs.ParseStringCHRRulesGoals(`
 	data1 @ data() ==> edge(berlin, 230, wolfsburg), edge(hannover, 89, wolfsburg), edge(hannover, 108, bielefeld), edge(bielefeld, 194, köln).
@@ -273,7 +266,6 @@ func TestCHRRule06(t *testing.T) {
 }
 
 func TestCHRRule07(t *testing.T) {
-	CHRtrace = 0
 	rs := MakeRuleStore()
 	ok := rs.ParseStringCHRRulesGoals(`
 
@@ -294,7 +286,6 @@ func TestCHRRule07(t *testing.T) {
 }
 
 func TestCHRRule08(t *testing.T) {
-	CHRtrace = 0
 	rs := MakeRuleStore()
 	ok := rs.ParseStringCHRRulesGoals(`
 
@@ -315,7 +306,6 @@ func TestCHRRule08(t *testing.T) {
 }
 
 func TestCHRRule09(t *testing.T) {
-	CHRtrace = 0
 	rs := MakeRuleStore()
 	ok := rs.ParseStringCHRRulesGoals(`
 
@@ -336,7 +326,6 @@ func TestCHRRule09(t *testing.T) {
 }
 
 func TestCHRRule10(t *testing.T) {
-	CHRtrace = 0
 	rs := MakeRuleStore()
 	ok := rs.ParseStringCHRRulesGoals(`
 // first  rule set: change only the search-rule in orginal code
@@ -410,7 +399,6 @@ add(X,X,s(s(0))).
 
 /* not OK
 func TestCHRRule11(t *testing.T) {
-	CHRtrace = 0
 	src := `
 modus_ponens @ implies(P,Q), P ==> Q.
 implies(farbe(rot), farbe(blau)), farbe(rot) .
@@ -425,7 +413,7 @@ implies(farbe(rot), farbe(blau)), farbe(rot) .
 	rs := MakeRuleStore()
 	ok := parseEvalRules(rs, &s)
 
-	for _, rule := range rs.CHRruleStore {
+	for _, rule := range rs.prog.rules {
 		fmt.Printf(" Rule: %s @ ", rule.name)
 		for _, h := range rule.keepHead {
 			fmt.Printf("%s, ", h)
@@ -452,7 +440,6 @@ implies(farbe(rot), farbe(blau)), farbe(rot) .
 */
 
 func TestCHRRule12(t *testing.T) {
-	CHRtrace = 0
 	src := `
 modus_ponens @ implies(P,Q), P <=> Q.
 implies(farbe(rot), farbe(blau)), farbe(rot) .
@@ -474,7 +461,6 @@ implies(farbe(rot), farbe(blau)), farbe(rot) .
 
 /*
 func TestCHRRule13(t *testing.T) {
-	CHRtrace = 0
 	src := `
 modus_ponens @ implies(P,Q), P ==> Q.
 implies(farbe(rot), farbe(blau)), implies(farbe(blau),farbe(grün)), farbe(rot) .
@@ -495,7 +481,6 @@ implies(farbe(rot), farbe(blau)), implies(farbe(blau),farbe(grün)), farbe(rot)
 }
 
 func TestCHRRule14(t *testing.T) {
-	CHRtrace = 0
 	src := `
 modus_ponens @ implies(P,Q), P ==> Q.
 implies(rot(), blau()), rot() .
@@ -517,7 +502,6 @@ implies(rot(), blau()), rot() .
 */
 
 func TestCHRRule15(t *testing.T) {
-	CHRtrace = 0
 	src := `
 modus_ponens @ implies(P,Q), P <=> Q.
 implies(rot(), blau()), rot() .
@@ -539,7 +523,6 @@ implies(rot(), blau()), rot() .
 
 /*
 func TestCHRRule16(t *testing.T) {
-	CHRtrace = 0
 	src := `
 modus_ponens @ gelb(), implies(P,Q), P ==> Q.
 implies(rot(), blau()), implies(blau(),grün()), rot(), gelb() .
@@ -560,7 +543,6 @@ implies(rot(), blau()), implies(blau(),grün()), rot(), gelb() .
 }
 
 func TestCHRRule17(t *testing.T) {
-	CHRtrace = 0
 	src := `
 modus_ponens @ implies(P,Q), P ==> Q.
 implies(rot, blau), rot .
@@ -581,7 +563,6 @@ implies(rot, blau), rot .
 */
 
 func TestCHRRule18(t *testing.T) {
-	CHRtrace = 0
 	src := `
 modus_ponens @ implies(P,Q), P <=> Q.
 implies(rot, blau), rot .
@@ -602,7 +583,6 @@ implies(rot, blau), rot .
 
 /*
 func TestCHRRule19(t *testing.T) {
-	CHRtrace = 0
 	src := `
 modus_ponens @ gelb, implies(P,Q), P ==> Q.
 implies(rot, blau), implies(blau,grün), rot, gelb .
@@ -644,11 +624,9 @@ func TestRS01(t *testing.T) {
 		fmt.Print(err.Error())
 		t.Error(err.Error())
 	}
-	CHRtrace = 0
 	//	sum([1,2,3,4,5,6,7,8,9,10], S).
 	//	#result: S == 55 .
 	rBool, rList, err := rs.Infer([]string{"sum([1,2,3,4,5,6,7,8,9,10], S)"})
-	CHRtrace = 0
 	if err != nil {
 		fmt.Printf("Infer fail \n")
 		fmt.Print(err.Error())
@@ -685,7 +663,6 @@ func TestRS01(t *testing.T) {
 }
 
 func TestCHRRule20(t *testing.T) {
-	CHRtrace = 0
 	src := `
 gov_stats_scheme @ gov_stats(C,S) ==> safety(C,S), argument(gov_stats_scheme,[C,S]).
 advertising_scheme @ advertising(C,S) ==> safety(C,S), argument(advertising_scheme,[C,S]).
@@ -709,7 +686,6 @@ price(volvo,medium), price(porsche,high), advertising(volvo,high), safety(porsch
 }
 
 func TestCHRRule21a(t *testing.T) {
-	CHRtrace = 1
 	src := `selectors @ dataUseStatement(dus(ResultScope,ID)) ==> resultScope(dus(ResultScope,ID),ResultScope).
 smallerOrEqualScope1 @ resultScope(S11,C),resultScope(S12,C) ==> smallerOrEqualScope(S11,S12),argument(smallerOrEqualScope1,[S11,S12,C]).
 // smallerOrEqualScope2 @ smallerOrEqualScope(S21,S22) \ smallerOrEqualScope(S21,S22) <=> true,argument(smallerOrEqualScope2,[S21,S22]).
@@ -728,6 +704,7 @@ dataUseStatement(dus(capability,id01)),
 
 	s.Error = Err
 	rs := MakeRuleStore()
+	rs.Trace.Level = 1
 	ok := parseEvalRules(rs, &s)
 
 	if !ok {
@@ -736,7 +713,6 @@ dataUseStatement(dus(capability,id01)),
 }
 
 func TestCHRRule21(t *testing.T) {
-	CHRtrace = 1
 	src := `selectors @ dataUseStatement(dus(UseScope,Qualifier,DataCategory,SourceScope,Action,ResultScope,ID,Passive)) ==> resultScope(dus(ResultScope,ID),ResultScope).
 smallerOrEqualScope1 @ resultScope(S1,C),resultScope(S2,C) ==> smallerOrEqualScope(S1,S2),argument(smallerOrEqualScope1,[S1,S2,C]).
 smallerOrEqualScope2 @ smallerOrEqualScope(S1,S2) \ smallerOrEqualScope(S1,S2) <=> true,argument(smallerOrEqualScope2,[S1,S2]).
@@ -756,6 +732,7 @@ dataUseStatement(dus(capability,identified_data,provider_data_authentication,cap
 
 	s.Error = Err
 	rs := MakeRuleStore()
+	rs.Trace.Level = 1
 	ok := parseEvalRules(rs, &s)
 
 	if !ok {
@@ -765,7 +742,6 @@ dataUseStatement(dus(capability,identified_data,provider_data_authentication,cap
 
 /*
 func TestCHRRule21(t *testing.T) {
-	CHRtrace = 1
 	src := `selectors @ dataUseStatement(dus(UseScope,Qualifier,DataCategory,SourceScope,Action,ResultScope,ID,Passive)) ==> resultScope(dus(UseScope,Qualifier,DataCategory,SourceScope,Action,ResultScope,ID,Passive),ResultScope).
 // selectors @ dataUseStatement(dus(UseScope,Qualifier,DataCategory,SourceScope,Action,ResultScope,ID,Passive)) ==> useScope(dus(UseScope,Qualifier,DataCategory,SourceScope,Action,ResultScope,ID,Passive),UseScope),qualifier(dus(UseScope,Qualifier,DataCategory,SourceScope,Action,ResultScope,ID,Passive),Qualifier),dataCategory(dus(UseScope,Qualifier,DataCategory,SourceScope,Action,ResultScope,ID,Passive),DataCategory),sourceScope(dus(UseScope,Qualifier,DataCategory,SourceScope,Action,ResultScope,ID,Passive),SourceScope),action(dus(UseScope,Qualifier,DataCategory,SourceScope,Action,ResultScope,ID,Passive),Action),resultScope(dus(UseScope,Qualifier,DataCategory,SourceScope,Action,ResultScope,ID,Passive),ResultScope),id(dus(UseScope,Qualifier,DataCategory,SourceScope,Action,ResultScope,ID,Passive),ID),passive(dus(UseScope,Qualifier,DataCategory,SourceScope,Action,ResultScope,ID,Passive),Passive),argument(selectors,[UseScope,Qualifier,DataCategory,SourceScope,Action,ResultScope,ID,Passive]).
 //kindOfTransitivity @ kindOf(X,Y),kindOf(Y,Z) ==> kindOf(X,Z),argument(kindOfTransitivity,[X,Y,Z]).
@@ -889,6 +865,7 @@ dataUseStatement(dus(capability,identified_data,provider_data_authentication,cap
 
 	s.Error = Err
 	rs := MakeRuleStore()
+	rs.Trace.Level = 1
 	ok := parseEvalRules(rs, &s)
 
 	if !ok {
@@ -896,3 +873,66 @@ dataUseStatement(dus(capability,identified_data,provider_data_authentication,cap
 	}
 }
 */
+
+func TestCHRRule22(t *testing.T) {
+	// the equation solver, solution "" - the equation is kept, "false" - no solution
+	for _, c := range [][2]string{
+		{"X + 3 == 10", "7"},
+		{"2 * X - 4 == X", "4"},
+		{"X * 2 == 5", "5 rdiv 2"},
+		{"(X - 1) rdiv 3 == 2 - X", "7 rdiv 4"},
+		{"X / 2 == 3", ""},
+		{"X / 2.0 == 3", "6.0"},
+		{"X / (1 rdiv 2) == 3", "3 rdiv 2"},
+		{"X + 7 / 2 == 5", "2"},
+		{"X * 2.5 == 5", "2.0"},
+		{"-X == 18446744073709551616", "-18446744073709551616"},
+		{"X + 1 == X + 2", "false"},
+		{"X + 1 == 1 + X", ""},
+		{"X * X == 4", ""},
+		{"X + Y == 4", ""},
+	} {
+		eq, ok := ReadString(c[0])
+		if !ok {
+			t.Fatalf("TestCHRRule22: parse error: %s", c[0])
+		}
+		args := eq.(Compound).Args
+		env, solved, ok := equationSolver(args[0], args[1], nil)
+		switch {
+		case c[1] == "false":
+			if ok {
+				t.Errorf("TestCHRRule22 fails: %s has a solution", c[0])
+			}
+		case c[1] == "":
+			if !ok || (solved && env != nil) {
+				t.Errorf("TestCHRRule22 fails: %s is solved", c[0])
+			}
+		default:
+			want, _ := ReadString(c[1])
+			if !solved || !ok || env == nil || !Equal(env.T, Eval(want)) {
+				t.Errorf("TestCHRRule22 fails: %s, solved: %v, ok: %v, bindings: %v", c[0], solved, ok, env)
+			}
+		}
+	}
+	// the solutions are bindings of the stores in both orders of the equations
+	for _, mode := range []SolverMode{ModeDefault, ModeRefined} {
+		rs := MakeRuleStore()
+		rs.Mode = mode
+		ok := rs.ParseStringCHRRulesGoals(`
+		r1 @ start1 <=> q(Y), Z == 6, 2*Y == Z+4 .
+		r2 @ start2 <=> q(Y), 2*Y == Z+4, Z == 6 .
+		r3 @ q(5) <=> five.
+		r4 @ go(X, Y) <=> 2*Y == X+4, X + 3 == 9 .
+		r5 @ go(X) <=> X + 1 == X + 2 .
+		start1, start2.
+		#result: five, five.
+		go(A, B).
+		#result: B == 5, A == 6 .
+		go(C).
+		#result: false.
+		`)
+		if !ok {
+			t.Errorf("TestCHRRule22 fails, mode: %s", mode)
+		}
+	}
+}
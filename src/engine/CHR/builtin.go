@@ -0,0 +1,122 @@
+// Copyright © 2016 The Carneades Authors
+// This Source Code Form is subject to the terms of the
+// Mozilla Public License, v. 2.0. If a copy of the MPL
+// was not distributed with this file, You can obtain one
+// at http://mozilla.org/MPL/2.0/.
+
+// Registry of foreign built-in functions, called in guards and bodies
+
+package chr
+
+import (
+	"errors"
+	"fmt"
+
+	. "github.com/hfried/GoCHR/src/engine/terms"
+)
+
+// BuiltinFunc - a Go function, called with the evaluated arguments of a built-in
+type BuiltinFunc func(args []Term) (Term, error)
+
+type builtinKey struct {
+	name  string
+	arity int
+}
+
+type builtins map[builtinKey]BuiltinFunc
+
+// BuiltinError - the error of a failed call of a built-in function
+type BuiltinError struct {
+	Call Term  // the call with the evaluated arguments
+	Err  error // the error of the function
+}
+
+func (e *BuiltinError) Error() string {
+	return fmt.Sprintf("built-in %s failed: %s", e.Call, e.Err)
+}
+
+func (e *BuiltinError) Unwrap() error {
+	return e.Err
+}
+
+// RegisterBuiltin registers the Go function fn as built-in name/arity. A term
+// name(A1,...,An) in a guard or a body is replaced by the result of fn, when all
+// arguments are ground; a Bool result decides a guard or a body goal. If fn returns
+// an error (or panics), the guard or the goal fails and the solver stops with a
+// *BuiltinError. A registered function replaces a CHR-constraint name/arity in the
+// guards and bodies and a standard built-in function (see StdlibHelp). The
+// registration is kept, if the rules are parsed again.
+func (rs *RuleStore) RegisterBuiltin(name string, arity int, fn BuiltinFunc) error {
+	if name == "" {
+		return errors.New("built-in without a name")
+	}
+	if arity < 0 {
+		return fmt.Errorf("built-in %s with negative arity %d", name, arity)
+	}
+	if fn == nil {
+		return fmt.Errorf("built-in %s/%d without a function", name, arity)
+	}
+	if rs.prog.builtins == nil {
+		rs.prog.builtins = builtins{}
+	}
+	rs.prog.builtins[builtinKey{name, arity}] = fn
+	return nil
+}
+
+// call the built-in function of the compound t, if it is registered or a standard
+// built-in function (see StdlibHelp) and all arguments are ground
+func (bi builtins) call(t Compound) (Term, bool, error) {
+	key := builtinKey{t.Functor, len(t.Args)}
+	fn, ok := bi[key]
+	typeTest, isStd := false, !ok
+	if isStd {
+		std := stdlib[key]
+		fn, typeTest = std.fn, std.typeTest
+	}
+	if fn == nil {
+		// no built-in or a standard built-in hidden by a CHR-constraint
+		return t, false, nil
+	}
+	if !typeTest {
+		for _, a := range t.Args {
+			if !isGround(a) {
+				return t, false, nil
+			}
+		}
+	}
+	res, err := callBuiltin(fn, t.Args)
+	if isStd && isTypeError(err) {
+		// a standard built-in with arguments of another type, e.g. a CHR-constraint max(X, a)
+		return t, false, nil
+	}
+	if err == nil && res == nil {
+		err = errors.New("no result")
+	}
+	if err != nil {
+		return t, true, &BuiltinError{Call: t, Err: err}
+	}
+	return res, true, nil
+}
+
+func callBuiltin(fn BuiltinFunc, args []Term) (res Term, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			res, err = nil, fmt.Errorf("panic: %v", r)
+		}
+	}()
+	return fn(args)
+}
+
+// eval evaluates the term t with the built-in functions of the rule store; after the first
+// error of a built-in function the result is false and the error is kept for the solver
+func (rs *Session) eval(t Term) Term {
+	t2, err := evalBuiltins(t, rs.prog.builtins)
+	if err != nil {
+		rs.Trace.Headln(1, 1, "!!! ", err)
+		if rs.evalErr == nil {
+			rs.evalErr = err
+		}
+		return Bool(false)
+	}
+	return t2
+}
@@ -0,0 +1,101 @@
+// Copyright © 2016 The Carneades Authors
+// This Source Code Form is subject to the terms of the
+// Mozilla Public License, v. 2.0. If a copy of the MPL
+// was not distributed with this file, You can obtain one
+// at http://mozilla.org/MPL/2.0/.
+
+package chr
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	. "github.com/hfried/GoCHR/src/engine/parser"
+	. "github.com/hfried/GoCHR/src/engine/terms"
+)
+
+func builtinStore(t *testing.T) *RuleStore {
+	rs := MakeRuleStore()
+	err := rs.RegisterBuiltin("even", 1, func(args []Term) (Term, error) {
+		i, ok := args[0].(Int)
+		if !ok {
+			return nil, errors.New("no integer")
+		}
+		return Bool(i%2 == 0), nil
+	})
+	if err != nil {
+		t.Fatalf("RegisterBuiltin fails: %s", err)
+	}
+	err = rs.RegisterBuiltin("upper", 1, func(args []Term) (Term, error) {
+		return String(strings.ToUpper(string(args[0].(String)))), nil
+	})
+	if err != nil {
+		t.Fatalf("RegisterBuiltin fails: %s", err)
+	}
+	return rs
+}
+
+func TestBuiltin01(t *testing.T) {
+	// built-in in a guard and in a body
+	for _, mode := range []SolverMode{ModeDefault, ModeRefined} {
+		rs := builtinStore(t)
+		rs.Mode = mode
+		ok := rs.ParseStringCHRRulesGoals(`
+		sel @ num(X) <=> even(X) | even_num(X).
+		name @ name(N) <=> up(upper(N)).
+		num(1), num(2), num(3), num(4), name("chr").
+		#result: num(1), num(3), even_num(2), even_num(4), up("CHR").
+		`)
+		if !ok {
+			t.Errorf("TestBuiltin01 fails, mode: %s", mode)
+		}
+	}
+}
+
+func TestBuiltin02(t *testing.T) {
+	// the registration is kept, if the rules are parsed again; not ground arguments
+	rs := builtinStore(t)
+	rs.ParseStringCHRRulesGoals(`p(X) <=> q(upper(X)).`)
+	g, _ := ReadString("[p(\"a\"), p(Y)]")
+	res, err := rs.Solve(context.Background(), g)
+	if err != nil || res.Kind != RStore || len(res.CHRStore) != 2 ||
+		res.CHRStore[0].String() != "q(\"A\")" || res.CHRStore[1].String() != "q(upper(Y))" {
+		t.Errorf("TestBuiltin02 fails, CHR-store: %s, err: %v", res.CHRStore, err)
+	}
+}
+
+func TestBuiltin03(t *testing.T) {
+	// an error of a built-in function stops the solver
+	for _, mode := range []SolverMode{ModeDefault, ModeRefined} {
+		rs := builtinStore(t)
+		rs.Mode = mode
+		rs.ParseStringCHRRulesGoals(`
+		sel @ num(X) <=> even(X) | even_num(X).
+		fail @ bad(X) <=> failed(upper(X)).`)
+		g, _ := ReadString("[num(2), num(a), bad(\"x\")]")
+		_, err := rs.Solve(context.Background(), g)
+		var bErr *BuiltinError
+		if !errors.As(err, &bErr) || bErr.Call.String() != "even(a)" || rs.Err != err {
+			t.Errorf("TestBuiltin03 fails, mode: %s, err: %v", mode, err)
+		}
+	}
+	// a panic of a built-in function
+	rs := builtinStore(t)
+	rs.ParseStringCHRRulesGoals(`bad(X) <=> failed(upper(X)).`)
+	_, err := rs.Solve(context.Background(), Compound{Functor: "bad", Args: []Term{Int(1)}})
+	var bErr *BuiltinError
+	if !errors.As(err, &bErr) || !strings.Contains(err.Error(), "panic") {
+		t.Errorf("TestBuiltin03 fails, panic, err: %v", err)
+	}
+}
+
+func TestBuiltin04(t *testing.T) {
+	rs := MakeRuleStore()
+	fn := func(args []Term) (Term, error) { return Bool(true), nil }
+	if rs.RegisterBuiltin("", 1, fn) == nil || rs.RegisterBuiltin("f", -1, fn) == nil ||
+		rs.RegisterBuiltin("f", 1, nil) == nil {
+		t.Error("TestBuiltin04 fails, wrong registration accepted")
+	}
+}
@@ -0,0 +1,133 @@
+// Copyright © 2016 The Carneades Authors
+// This Source Code Form is subject to the terms of the
+// Mozilla Public License, v. 2.0. If a copy of the MPL
+// was not distributed with this file, You can obtain one
+// at http://mozilla.org/MPL/2.0/.
+
+// check expected results of Constraint Handling Rules
+
+package chr
+
+import (
+	"io"
+	"strings"
+	sc "text/scanner"
+
+	. "github.com/hfried/GoCHR/src/engine/parser"
+	. "github.com/hfried/GoCHR/src/engine/terms"
+)
+
+// CheckResult is the comparison of an expected store ('#result', '#store'
+// or '#bistore') with the computed store of the last goals
+type CheckResult struct {
+	Directive  string      // "result", "store" or "bistore"
+	Goals      string      // the goals before the directive
+	Pos        sc.Position // position of the directive
+	Expected   string
+	Computed   string
+	Missing    []string // expected, but not computed
+	Unexpected []string // computed, but not expected
+	OK         bool
+}
+
+func (c *CheckResult) kind() string {
+	if c.Directive == "bistore" {
+		return "BI"
+	}
+	return "chr"
+}
+
+// parse CHR-rules and goals from inFile, solve the goals and compare the
+// computed stores with all '#result', '#store' and '#bistore' directives.
+// A failed comparison does not stop the evaluation. ok is false,
+// if the parsing failed.
+func (rs *RuleStore) CheckFileCHRRulesGoals(inFile io.Reader) (checks []*CheckResult, ok bool) {
+	var s sc.Scanner
+	// Initialize the scanner.
+	s.Init(inFile)
+
+	s.Error = Err
+	return checkEvalRules(rs, &s)
+}
+
+// CheckStringCHRRulesGoals is CheckFileCHRRulesGoals for the string src
+func (rs *RuleStore) CheckStringCHRRulesGoals(src string) (checks []*CheckResult, ok bool) {
+	return rs.CheckFileCHRRulesGoals(strings.NewReader(src))
+}
+
+func checkEvalRules(rs *RuleStore, s *sc.Scanner) (checks []*CheckResult, ok bool) {
+	checks = []*CheckResult{}
+	rs.checkResults = &checks
+	ok = parseEvalRules(rs, s)
+	rs.checkResults = nil
+	return checks, ok
+}
+
+// checkExpectedStore compares the expected store t with the computed
+// CHR- and Built-In-store ('#result', '#store') or only with the
+// Built-In-store ('#bistore')
+func checkExpectedStore(rs *Session, directive string, t Term) *CheckResult {
+	check := &CheckResult{Directive: directive, Expected: t.String()}
+	compBI := bi2List(rs)
+	if directive == "bistore" {
+		check.Computed = compBI.String()
+		check.OK = EqualVarNameCList(compBI, t)
+		if !check.OK {
+			check.Missing, check.Unexpected = diffStore(compBI, t)
+			check.OK = len(check.Missing) == 0 && len(check.Unexpected) == 0 && len(compBI) != 0
+		}
+		return check
+	}
+	compCHR := chr2List(rs)
+	if EqualVarNameCList(compCHR, t) {
+		check.Computed = compCHR.String()
+		check.OK = true
+		return check
+	}
+	if EqualVarNameCList(compBI, t) {
+		check.Computed = compBI.String()
+		check.OK = true
+		return check
+	}
+	comp := List{}
+	for _, c := range compCHR {
+		comp = append(comp, c)
+	}
+	for _, bi := range compBI {
+		comp = append(comp, bi)
+	}
+	check.Computed = comp.String()
+	check.Missing, check.Unexpected = diffStore(comp, t)
+	check.OK = len(check.Missing) == 0 && len(check.Unexpected) == 0 && len(comp) != 0
+	return check
+}
+
+// diffStore - the constraints of the expected store t, which are not in the
+// computed store comp (missing) and the constraints of comp, which are not in t
+// (unexpected). The order of the constraints is not relevant.
+func diffStore(comp List, t Term) (missing, unexpected []string) {
+	exp, ok := t.(List)
+	if !ok {
+		exp = List{t}
+	}
+	vec := make([]bool, len(exp))
+	for _, c := range comp {
+		found := false
+		for i, e := range exp {
+			if !vec[i] && EqualVarName(c, e) {
+				vec[i] = true
+				found = true
+				break
+			}
+		}
+		if !found {
+			unexpected = append(unexpected, c.String())
+		}
+	}
+	for i, e := range exp {
+		if !vec[i] {
+			missing = append(missing, e.String())
+		}
+	}
+	return
+}
@@ -0,0 +1,74 @@
+// Copyright © 2016 The Carneades Authors
+// This Source Code Form is subject to the terms of the
+// Mozilla Public License, v. 2.0. If a copy of the MPL
+// was not distributed with this file, You can obtain one
+// at http://mozilla.org/MPL/2.0/.
+
+package chr
+
+import (
+	"testing"
+)
+
+func TestCheck01(t *testing.T) {
+	rs := MakeRuleStore()
+	checks, ok := rs.CheckStringCHRRulesGoals(`
+	leq_reflexivity  @ leq(X,X) <=> true.
+	leq_antisymmetry @ leq(X,Y), leq(Y,X) <=> X==Y.
+	leq_idempotence  @ leq(X,Y)\ leq(X,Y) <=> true.
+	leq_transitivity @ leq(X,Y), leq(Y,Z) ==> leq(X,Z).
+	leq(A,B), leq(B,C), leq(C,A).
+	#result: A==C, B==C .
+	#bistore: B==C, A==C .
+	leq(A,B), leq(B,C).
+	#store: leq(A,B), leq(B,C), leq(A,C).
+	`)
+	if !ok {
+		t.Error("TestCheck01 fails, parse error")
+		return
+	}
+	if len(checks) != 3 {
+		t.Errorf("TestCheck01 fails, 3 checks exspected, not %d", len(checks))
+		return
+	}
+	for _, c := range checks {
+		if !c.OK {
+			t.Errorf("TestCheck01 fails, #%s: %s != %s", c.Directive, c.Expected, c.Computed)
+		}
+	}
+	if checks[2].Goals != "[leq(A,B), leq(B,C)]" {
+		t.Errorf("TestCheck01 fails, goals: %s", checks[2].Goals)
+	}
+}
+
+func TestCheck02(t *testing.T) {
+	rs := MakeRuleStore()
+	checks, ok := rs.CheckStringCHRRulesGoals(`
+	gcd01@ gcd(0) <=> true .
+	gcd02@ gcd(N) \ gcd(M) <=> N <= M | gcd(M mod N).
+	gcd(12),gcd(18).
+	#result: gcd(4).
+	#bistore: gcd(6).
+	gcd(3528),gcd(3780).
+	#result == gcd(252).
+	`)
+	if !ok {
+		t.Error("TestCheck02 fails, parse error")
+		return
+	}
+	if len(checks) != 3 {
+		t.Errorf("TestCheck02 fails, 3 checks exspected, not %d", len(checks))
+		return
+	}
+	c := checks[0]
+	if c.OK || len(c.Missing) != 1 || c.Missing[0] != "gcd(4)" ||
+		len(c.Unexpected) != 1 || c.Unexpected[0] != "gcd(6)" {
+		t.Errorf("TestCheck02 fails, #result: %v, missing: %v, unexpected: %v", c.OK, c.Missing, c.Unexpected)
+	}
+	if checks[1].OK {
+		t.Errorf("TestCheck02 fails, #bistore: %s == %s", checks[1].Expected, checks[1].Computed)
+	}
+	if !checks[2].OK {
+		t.Errorf("TestCheck02 fails, #result: %s != %s", checks[2].Expected, checks[2].Computed)
+	}
+}
@@ -0,0 +1,226 @@
+// Copyright © 2016 The Carneades Authors
+// This Source Code Form is subject to the terms of the
+// Mozilla Public License, v. 2.0. If a copy of the MPL
+// was not distributed with this file, You can obtain one
+// at http://mozilla.org/MPL/2.0/.
+
+// Debugger - an interactive tracer, which pauses the solver runs
+
+package chr
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	. "github.com/hfried/GoCHR/src/engine/terms"
+)
+
+const DebuggerHelp = `The commands are:
+
+step, s                     - run up to the next trace event
+next, n                     - run up to the next rule firing
+continue, c                 - run up to the next breakpoint
+break rule <name>           - pause before the rule <name> fires
+break constraint <functor>  - pause when a constraint <functor>(...) is added to the store
+break, b                    - list the breakpoints
+delete, d                   - delete all breakpoints
+print store, p              - print the CHR- and built-in-store
+help, h                     - displays the commands
+quit, q                     - stop debugging
+
+An empty line repeats the last command.`
+
+// the event, at which the debugger pauses next
+type debugMode int
+
+const (
+	debugFiring debugMode = iota // the next rule firing
+	debugEvent                   // the next trace event
+	debugBreak                   // the next breakpoint
+	debugOff                     // no pause, after quit or the end of the input
+)
+
+// Debugger is a Tracer, which pauses the solver runs of a session before
+// every rule firing and reads commands from its input, see DebuggerHelp.
+// At a pause, the fired rule, the active constraint (ModeRefined), the
+// matched constraints of the heads and the bindings are shown.
+type Debugger struct {
+	OnQuit func() // called by the command quit, e.g. to exit; nil = run without pauses
+
+	rs          *Session
+	in          *bufio.Scanner
+	out         io.Writer
+	mode        debugMode
+	ruleBreak   map[string]bool
+	functBreak  map[string]bool
+	active      *Compound // the active constraint of the last tried rule
+	lastCommand string
+}
+
+// NewDebugger attaches a debugger to the session rs, e.g. the session of a rule
+// store or a session of a program: it becomes the tracer of rs; the commands are
+// read from in, the pauses are written to out
+func NewDebugger(rs *Session, in io.Reader, out io.Writer) *Debugger {
+	d := &Debugger{rs: rs, in: bufio.NewScanner(in), out: out, mode: debugFiring,
+		ruleBreak: map[string]bool{}, functBreak: map[string]bool{}}
+	rs.Tracer = d
+	return d
+}
+
+func (d *Debugger) Event(e Event) {
+	switch e.Kind {
+	case EventRuleTried:
+		d.active = e.Constraint
+	case EventRuleFired:
+		if d.mode == debugFiring || d.mode == debugEvent ||
+			d.mode == debugBreak && d.ruleBreak[e.Rule] {
+			d.showFiring(e)
+			d.commands()
+		}
+		d.active = nil
+		return
+	case EventConstraintAdded:
+		if d.mode != debugOff && d.functBreak[e.Constraint.Functor] {
+			fmt.Fprintf(d.out, "[%d] %s\n", e.Firing, e)
+			d.commands()
+			return
+		}
+	}
+	if d.mode == debugEvent {
+		fmt.Fprintf(d.out, "[%d] %s\n", e.Firing, e)
+		d.commands()
+	}
+}
+
+// showFiring writes the rule firing e
+func (d *Debugger) showFiring(e Event) {
+	fmt.Fprintf(d.out, "[%d] rule %s fires\n", e.Firing, e.Rule)
+	for _, r := range d.rs.prog.rules {
+		if r.name == e.Rule {
+			fmt.Fprintf(d.out, "  rule:     %s\n", rule2string(r))
+			break
+		}
+	}
+	if d.active != nil {
+		fmt.Fprintf(d.out, "  active:   %s (Id: %s)\n", d.active, d.active.Id)
+	}
+	fmt.Fprintf(d.out, "  heads:    %s\n", e.Partners)
+	if len(e.Bindings) != 0 {
+		fmt.Fprintf(d.out, "  bindings: %s\n", bindings2string(e.Bindings))
+	}
+}
+
+// commands reads and executes commands, up to a command, which continues the solver run
+func (d *Debugger) commands() {
+	for {
+		fmt.Fprint(d.out, "(debug) ")
+		if !d.in.Scan() {
+			// end of the input, run without pauses
+			fmt.Fprintln(d.out)
+			d.mode = debugOff
+			return
+		}
+		cmd := strings.TrimSpace(d.in.Text())
+		if cmd == "" {
+			cmd = d.lastCommand
+		}
+		d.lastCommand = cmd
+		if d.command(strings.Fields(cmd)) {
+			return
+		}
+	}
+}
+
+// command executes the command args; true, if the solver run continues
+func (d *Debugger) command(args []string) bool {
+	if len(args) == 0 {
+		return false
+	}
+	switch args[0] {
+	case "step", "s":
+		d.mode = debugEvent
+		return true
+	case "next", "n":
+		d.mode = debugFiring
+		return true
+	case "continue", "c":
+		d.mode = debugBreak
+		return true
+	case "break", "b":
+		if len(args) == 1 {
+			d.printBreakpoints()
+			return false
+		}
+		if len(args) != 3 {
+			fmt.Fprintln(d.out, "usage: break rule <name> | break constraint <functor>")
+			return false
+		}
+		switch args[1] {
+		case "rule", "r":
+			if !d.isRule(args[2]) {
+				fmt.Fprintf(d.out, "unknown rule: %s\n", args[2])
+				return false
+			}
+			d.ruleBreak[args[2]] = true
+		case "constraint", "c":
+			d.functBreak[args[2]] = true
+		default:
+			fmt.Fprintln(d.out, "usage: break rule <name> | break constraint <functor>")
+		}
+	case "delete", "d":
+		d.ruleBreak = map[string]bool{}
+		d.functBreak = map[string]bool{}
+	case "print", "p":
+		if len(args) > 1 && args[1] != "store" {
+			fmt.Fprintln(d.out, "usage: print store")
+			return false
+		}
+		fmt.Fprintf(d.out, "CHR-store:      %s\n", clist2string(sortedStore(d.rs, d.rs.CHRstore)))
+		fmt.Fprintf(d.out, "built-in-store: %s\n", clist2string(sortedStore(d.rs, d.rs.BuiltInStore)))
+	case "help", "h", "?":
+		fmt.Fprintln(d.out, DebuggerHelp)
+	case "quit", "q":
+		d.quit()
+		return true
+	default:
+		fmt.Fprintf(d.out, "unknown command: %s ('help' for help)\n", args[0])
+	}
+	return false
+}
+
+func (d *Debugger) quit() {
+	d.mode = debugOff
+	if d.OnQuit != nil {
+		d.OnQuit()
+	}
+}
+
+func (d *Debugger) isRule(name string) bool {
+	for _, r := range d.rs.prog.rules {
+		if r.name == name {
+			return true
+		}
+	}
+	return false
+}
+
+func (d *Debugger) printBreakpoints() {
+	bps := []string{}
+	for name := range d.ruleBreak {
+		bps = append(bps, "rule "+name)
+	}
+	for f := range d.functBreak {
+		bps = append(bps, "constraint "+f)
+	}
+	if len(bps) == 0 {
+		fmt.Fprintln(d.out, "no breakpoints")
+		return
+	}
+	sort.Strings(bps)
+	for _, bp := range bps {
+		fmt.Fprintf(d.out, "break %s\n", bp)
+	}
+}
@@ -0,0 +1,108 @@
+// Copyright © 2016 The Carneades Authors
+// This Source Code Form is subject to the terms of the
+// Mozilla Public License, v. 2.0. If a copy of the MPL
+// was not distributed with this file, You can obtain one
+// at http://mozilla.org/MPL/2.0/.
+
+package chr
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+)
+
+func debugRun(t *testing.T, mode SolverMode, commands string) (string, bool) {
+	rs := MakeRuleStore()
+	rs.Mode = mode
+	rs.Order = OrderId
+	var out bytes.Buffer
+	d := NewDebugger(&rs.Session, strings.NewReader(commands), &out)
+	quit := false
+	d.OnQuit = func() { quit = true }
+	if !rs.ParseStringCHRRulesGoals(gcdRules + `
+	gcd(9), gcd(6), p(Y), q(Y).`) {
+		t.Fatal("parse rules fails")
+	}
+	if rs.Tracer != d {
+		t.Error("debugRun fails, no tracer")
+	}
+	return out.String(), quit
+}
+
+func TestDebugger01(t *testing.T) {
+	// pause before the first firing, breakpoints, print store
+	out, quit := debugRun(t, ModeDefault, "help\nbreak rule gcd1\nbreak rule foo\nbreak constraint q\nb\n"+
+		"continue\np\n\nprint rules\nfoo\ncontinue\nquit\n")
+	for _, want := range []string{
+		"[1] rule gcd2 fires\n" +
+			"  rule:     gcd2 @ gcd(N) \\ gcd(M) <=> N<=M, L:=M mod N | gcd(L).\n" +
+			"  heads:    [gcd(9), gcd(6)]\n" +
+			"  bindings: {L=3, M=9, N=6}\n(debug) ",
+		"step, s                     - run up to the next trace event\n",
+		"(debug) unknown rule: foo\n",
+		"(debug) break constraint q\nbreak rule gcd1\n(debug) ",
+		// continue, breakpoint gcd1, print store twice
+		"(debug) [3] rule gcd1 fires\n" +
+			"  rule:     gcd1 @ gcd(0) <=> true.\n" +
+			"  heads:    [gcd(0)]\n" +
+			"(debug) CHR-store:      [p(Y), q(Y), gcd(3), gcd(0)]\n" +
+			"built-in-store: []\n" +
+			"(debug) CHR-store:      [p(Y), q(Y), gcd(3), gcd(0)]\n" +
+			"built-in-store: []\n" +
+			"(debug) usage: print store\n" +
+			"(debug) unknown command: foo ('help' for help)\n" +
+			// continue, breakpoint q(1)
+			"(debug) [4] constraint added: q(1) (Id: 7)\n(debug) ",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("TestDebugger01 fails, missing:\n%s\nin:\n%s", want, out)
+		}
+	}
+	if !quit || strings.Contains(out, "rule eq fires") {
+		t.Errorf("TestDebugger01 fails, pause without breakpoint:\n%s", out)
+	}
+}
+
+func TestDebugger02(t *testing.T) {
+	// step, next and the active constraint (ModeRefined); end of the input
+	out, quit := debugRun(t, ModeRefined, "step\nstep\nnext\ndelete\nbreak rule eq\nc")
+	for _, want := range []string{
+		"[1] rule gcd2 fires\n" +
+			"  rule:     gcd2 @ gcd(N) \\ gcd(M) <=> N<=M, L:=M mod N | gcd(L).\n" +
+			"  active:   gcd(6) (Id: 1)\n" +
+			"  heads:    [gcd(9), gcd(6)]\n",
+		"(debug) [1] constraint deleted: gcd(9) (Id: 0)\n(debug) [1] constraint added: gcd(3) (Id: 4)\n" +
+			"(debug) [2] rule gcd2 fires\n",
+		"(debug) [4] rule eq fires\n  rule:     eq @ p(X) <=> X==1.\n  active:   p(Y) (Id: 2)\n",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("TestDebugger02 fails, missing:\n%s\nin:\n%s", want, out)
+		}
+	}
+	if quit || !strings.HasSuffix(out, "(debug) \n") {
+		t.Errorf("TestDebugger02 fails, end of input:\n%s", out)
+	}
+}
+
+func TestDebugger03(t *testing.T) {
+	// a debugger of a session of a program, the rule store has no tracer
+	rs := MakeRuleStore()
+	if !rs.ParseStringCHRRulesGoals(gcdRules) {
+		t.Fatal("parse rules fails")
+	}
+	s := rs.Program().NewSession()
+	s.Order = OrderId
+	var out bytes.Buffer
+	NewDebugger(s, strings.NewReader("print\ncontinue\n"), &out)
+	g, _ := ParseGoalString("gcd(9), gcd(6)")
+	res, err := s.Solve(context.Background(), g)
+	if err != nil || res.CHRStore.String() != "[gcd(3)]" || rs.Tracer != nil {
+		t.Errorf("TestDebugger03 fails, result: %v, err: %v", res.CHRStore, err)
+	}
+	if !strings.Contains(out.String(), "[1] rule gcd2 fires\n") ||
+		!strings.Contains(out.String(), "CHR-store:      [gcd(9), gcd(6)]\n") {
+		t.Errorf("TestDebugger03 fails:\n%s", out.String())
+	}
+}
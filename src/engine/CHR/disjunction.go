@@ -0,0 +1,273 @@
+// Copyright © 2016 The Carneades Authors
+// This Source Code Form is subject to the terms of the
+// Mozilla Public License, v. 2.0. If a copy of the MPL
+// was not distributed with this file, You can obtain one
+// at http://mozilla.org/MPL/2.0/.
+
+// Disjunctions in goals and rule bodies (CHR∨) with chronological backtracking
+
+package chr
+
+import (
+	"sort"
+	"strings"
+
+	. "github.com/hfried/GoCHR/src/engine/terms"
+)
+
+// a choice point of a split disjunction or of a rule choice: the state of the
+// solver before the split or the firing and the alternatives, which are not
+// tried up to now
+type choicePoint struct {
+	chr, bi   CList               // copies of the constraints of the CHR- and built-in store
+	herbrand  *herbrand           // the bindings of the variables of the CHR-store
+	his       []history           // propagation histories of the rules of the rule store
+	hisIndex  map[string][]hisRef // constraint Id -> entries of the propagation histories
+	disjuncts []Compound          // disjunctions, not split up to now
+	on        []bool              // the rules to try (isOn), all false at a split (a fixpoint)
+	lin       *linTableau         // the linear constraints of the built-in store
+	result    ResultType
+	alts      []Term     // the remaining alternatives of a disjunction, goal-lists
+	rules     []*chrRule // the remaining alternatives of a rule choice, applicable rules
+}
+
+// isDisjunction - t is a disjunction of goal-lists '(' <goals> ';' <goals> ... ')'
+func isDisjunction(t Term) bool {
+	c, ok := t.(Compound)
+	return ok && c.Functor == ";" && c.Prio != 0
+}
+
+// addDisjunction delays the disjunction d, it is split, if no rule is applicable
+func addDisjunction(rs *Session, d Compound) {
+	rs.Trace.Headln(3, 3, "Add disjunction: ", d)
+	rs.disjuncts = append(rs.disjuncts, d)
+}
+
+// substituteDisjunctions replaces the variables of the delayed disjunctions,
+// bound in the Build-In environment biEnv
+func substituteDisjunctions(rs *Session) {
+	for i, d := range rs.disjuncts {
+		if d1, ok := rs.herbrand.resolve(d); ok {
+			rs.disjuncts[i] = d1.(Compound)
+		}
+	}
+}
+
+// splitDisjunction adds the first alternative of the first delayed disjunction
+// to the stores and keeps the other alternatives in a choice point;
+// false, if there is no delayed disjunction
+func splitDisjunction(rs *Session) bool {
+	if len(rs.disjuncts) == 0 {
+		return false
+	}
+	d := rs.disjuncts[0]
+	rs.disjuncts = rs.disjuncts[1:]
+	rs.Trace.Headln(1, 1, "split disjunction ", d)
+	if len(d.Args) > 1 {
+		rs.choices = append(rs.choices, newChoicePoint(rs, d.Args[1:]))
+	}
+	if len(d.Args) > 0 {
+		addAlternative(rs, d.Args[0])
+	}
+	return true
+}
+
+// addRuleChoice keeps the other rules, which are applicable in the stores, as
+// alternatives of the rule r, which fires next, in a choice point. The rules
+// before r are not applicable, they were tried before r (see defaultCHRsolver).
+func addRuleChoice(rs *Session, r *chrRule) {
+	rename := rs.RenameRuleVars
+	alts := []*chrRule{}
+	for _, r2 := range rs.prog.rules[r.pos+1:] {
+		if !rs.state(r2).isOn {
+			continue
+		}
+		rs.RenameRuleVars = newRenaming(rs)
+		if _, _, ok := matchRule(rs, r2); ok {
+			alts = append(alts, r2)
+		}
+	}
+	rs.RenameRuleVars = rename
+	if len(alts) == 0 {
+		return
+	}
+	if rs.Trace.On(1) {
+		names := make([]string, len(alts))
+		for i, r2 := range alts {
+			names[i] = r2.name
+		}
+		rs.Trace.Headln(1, 1, "rule choice ", r.name, ", alternatives: ", strings.Join(names, ", "))
+	}
+	cp := newChoicePoint(rs, nil)
+	cp.rules = alts
+	rs.choices = append(rs.choices, cp)
+}
+
+// backtrack restores the state of the last choice point and adds its next
+// alternative to the stores or fires its next alternative rule; false, if
+// there is no choice point
+func backtrack(rs *Session) bool {
+	n := len(rs.choices)
+	if n == 0 {
+		return false
+	}
+	cp := rs.choices[n-1]
+	if len(cp.rules) != 0 {
+		r := cp.rules[0]
+		cp.rules = cp.rules[1:]
+		if len(cp.rules) == 0 {
+			rs.choices = rs.choices[:n-1]
+		}
+		rs.Trace.Headln(1, 1, "backtrack to the rule ", r.name)
+		restoreChoicePoint(rs, cp)
+		// the stores are the same, the rule matches again
+		rs.RenameRuleVars = newRenaming(rs)
+		if partners, env, ok := matchRule(rs, r); ok {
+			fireMatchedRule(rs, r, partners, env)
+			rs.Trace.Headln(1, 1, "rule ", r.name, " fired (id: ", r.id, ")")
+		}
+		return true
+	}
+	alt := cp.alts[0]
+	cp.alts = cp.alts[1:]
+	if len(cp.alts) == 0 {
+		rs.choices = rs.choices[:n-1]
+	}
+	rs.Trace.Headln(1, 1, "backtrack to the alternative ", alt)
+	restoreChoicePoint(rs, cp)
+	addAlternative(rs, alt)
+	return true
+}
+
+func newChoicePoint(rs *Session, alts []Term) *choicePoint {
+	cp := &choicePoint{chr: copyStore(rs.CHRstore), bi: copyStore(rs.BuiltInStore), herbrand: rs.herbrand.copy(),
+		his: make([]history, len(rs.states)), hisIndex: copyHisIndex(rs.hisIndex),
+		disjuncts: append([]Compound(nil), rs.disjuncts...), on: make([]bool, len(rs.states)),
+		lin: linearTableau(rs).copy(), result: rs.Result, alts: alts}
+	for i, st := range rs.states {
+		cp.his[i] = copyHistory(st.his)
+		cp.on[i] = st.isOn
+	}
+	return cp
+}
+
+// restoreChoicePoint restores the stores and the propagation histories of the
+// choice point cp; the constraints keep their Id's
+func restoreChoicePoint(rs *Session, cp *choicePoint) {
+	rs.CHRstore = store{}
+	rs.chrSize = 0
+	rs.herbrand = cp.herbrand.copy()
+	for _, c := range cp.chr {
+		c1 := CopyCompound(*c)
+		if _, ok := rs.CHRstore[c1.Functor]; !ok {
+			rs.CHRstore[c1.Functor] = newIndexedArgCHR(rs, c1.Functor)
+		}
+		rs.herbrand.index(&c1)
+		addCHRGoal(rs, &c1)
+	}
+	rs.BuiltInStore = store{}
+	for _, c := range cp.bi {
+		c1 := CopyCompound(*c)
+		addGoal1(rs, &c1, rs.BuiltInStore)
+	}
+	rs.biVersion++
+	rs.lin = cp.lin.copy()
+	rs.lin.version = rs.biVersion
+	for i := range rs.states {
+		st := &rs.states[i]
+		if i < len(cp.his) {
+			st.his = copyHistory(cp.his[i])
+			st.isOn = cp.on[i]
+		} else {
+			st.isOn = false
+		}
+		st.tried = nil
+	}
+	rs.hisIndex = copyHisIndex(cp.hisIndex)
+	rs.disjuncts = append([]Compound(nil), cp.disjuncts...)
+	rs.Result = cp.result
+}
+
+// addAlternative adds the goals of the alternative alt to the stores,
+// like the goals of a rule body; false, if a goal fails
+func addAlternative(rs *Session, alt Term) bool {
+	goals, ok := alt.(List)
+	if !ok {
+		goals = List{alt}
+	}
+	var env, biVarEqTerm Bindings
+	if rs.Justify {
+		cause := rs.cause
+		rs.cause = &derivCause{kind: DerivAlternative}
+		defer func() { rs.cause = cause }()
+	}
+	for _, g := range goals {
+		if env != nil {
+			g = Substitute(g, env)
+		}
+		if isDisjunction(g) {
+			addDisjunction(rs, g.(Compound))
+			rs.Result = RStore
+			continue
+		}
+		g = rs.eval(g)
+		switch g.Type() {
+		case CompoundType:
+			g1 := g.(Compound)
+			if len(g1.Args) == 2 {
+				switch g1.Functor {
+				case ":=", "is", "=":
+					if g1.Args[0].Type() != VariableType {
+						rs.Trace.Headln(1, 3, "Missing Variable in assignment in alternative: ", g1)
+						rs.Result = RFalse
+						return false
+					}
+					env = AddBinding(g1.Args[0].(Variable), g1.Args[1], env)
+				case "==":
+					g1, biVarEqTerm = bodyEquation(g1, biVarEqTerm)
+				}
+			}
+			addConstraintToStore(rs, g1)
+			rs.Result = RStore
+		case BoolType:
+			if !g.(Bool) {
+				rs.Result = RFalse
+				return false
+			}
+		}
+	}
+	if biVarEqTerm != nil {
+		substituteStores(rs, biVarEqTerm)
+	}
+	return true
+}
+
+// copyStore - copies of the constraints of the store s in the order of their Id's
+func copyStore(s store) CList {
+	cl := storeCList(s)
+	sort.SliceStable(cl, func(i, j int) bool { return lessId(cl[i], cl[j]) })
+	for i, c := range cl {
+		c1 := CopyCompound(*c)
+		cl[i] = &c1
+	}
+	return cl
+}
+
+func copyHistory(h history) history {
+	if h == nil {
+		return nil
+	}
+	h1 := make(history, len(h))
+	for k, v := range h {
+		h1[k] = v
+	}
+	return h1
+}
+
+func copyHisIndex(idx map[string][]hisRef) map[string][]hisRef {
+	idx1 := make(map[string][]hisRef, len(idx))
+	for k, refs := range idx {
+		idx1[k] = append([]hisRef(nil), refs...)
+	}
+	return idx1
+}
@@ -0,0 +1,107 @@
+// Copyright © 2016 The Carneades Authors
+// This Source Code Form is subject to the terms of the
+// Mozilla Public License, v. 2.0. If a copy of the MPL
+// was not distributed with this file, You can obtain one
+// at http://mozilla.org/MPL/2.0/.
+
+package chr
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+const succRules = `
+zero1 @ add(0,Y,Z) <=> Y == Z.
+zero2 @ add(X,0,Z) <=> X == Z.
+zero3 @ add(X,Y,0) <=> X == 0, Y == 0 .
+succ1 @ add(s(X),Y,Z) <=> Z == s(W), add(X,Y,W).
+succ2 @ add(X,s(Y),Z) <=> Z == s(W), add(X,Y,W).
+search @ add(X,Y,s(Z)) <=> add(X1,Y1,Z), (X == s(X1), Y == Y1 ; X == X1, Y == s(Y1)).
+`
+
+func TestDisjunction01(t *testing.T) {
+	// the first two alternatives fail
+	for _, mode := range []SolverMode{ModeDefault, ModeRefined} {
+		rs := MakeRuleStore()
+		rs.Mode = mode
+		ok := rs.ParseStringCHRRulesGoals(`
+		dom @ d(X) <=> (X == 1 ; X == 2 ; X == 3).
+		prop @ q(X) ==> X > 0 | r(X).
+		no1 @ r(1) <=> false.
+		no2 @ q(2) <=> false.
+		d(X), q(X).
+		#result: q(3), r(3), X == 3 .
+		`)
+		if !ok {
+			t.Errorf("TestDisjunction01 fails, mode: %s", mode)
+		}
+	}
+}
+
+func TestDisjunction02(t *testing.T) {
+	// search with backtracking over nested choice points
+	for _, mode := range []SolverMode{ModeDefault, ModeRefined} {
+		rs := MakeRuleStore()
+		rs.Mode = mode
+		ok := rs.ParseStringCHRRulesGoals(succRules + `
+		add(X, Y, s(s(0))).
+		#result: X == s(s(0)), Y == 0 .
+		add(X, Y, s(s(0))), add(Y, s(0), s(s(0))).
+		#result: X == s(0), Y == s(0) .
+		`)
+		if !ok {
+			t.Errorf("TestDisjunction02 fails, mode: %s", mode)
+		}
+		// all alternatives fail
+		rs.ParseStringCHRRulesGoals(succRules)
+		g, _ := ParseGoalString("add(X, Y, s(0)), add(X, Y, s(s(0)))")
+		res, err := rs.Solve(context.Background(), g)
+		if err != nil || res.Kind != RFalse {
+			t.Errorf("TestDisjunction02 fails, mode: %s, result: %s, err: %v", mode, res.Kind, err)
+		}
+	}
+}
+
+func TestDisjunction03(t *testing.T) {
+	// disjunctions in goals
+	rs := MakeRuleStore()
+	rs.Order = OrderId
+	ok := rs.ParseStringCHRRulesGoals(`
+	no @ p(1) <=> false.
+	p(1) ; p(2).
+	#result: p(2).
+	q(0), (p(1), q(1) ; p(3), q(3) ; p(4)).
+	#result: q(0), p(3), q(3).
+	`)
+	if !ok {
+		t.Error("TestDisjunction03 fails")
+	}
+	ok, result, err := rs.Infer([]string{"p(X), (X == 1 ; X == 2)"})
+	if !ok || err != nil || strings.Join(result, ", ") != "p(2), X==2" {
+		t.Errorf("TestDisjunction03 fails, result: %v, err: %v", result, err)
+	}
+	g, _ := ParseGoalString("(p(1) ; p(5), q(5))")
+	if g.String() != "[(p(1) ; p(5), q(5))]" {
+		t.Errorf("TestDisjunction03 fails, goal: %s", g)
+	}
+}
+
+func TestDisjunction04(t *testing.T) {
+	// no disjunction in a head or a guard, no single alternative
+	for _, src := range []string{
+		`p(X) <=> (X > 1 ; X < 0) | q(X).`,
+		`(p(X) ; q(X)) <=> r(X).`,
+		`p(X) <=> (q(X), r(X)).`,
+	} {
+		rs := MakeRuleStore()
+		if rs.ParseStringCHRRulesGoals(src) {
+			t.Errorf("TestDisjunction04 fails, rule: %s", src)
+		}
+	}
+	rs := MakeRuleStore()
+	if err := rs.AddRule("r", nil, []string{"p(X)"}, []string{"X > 1 ; X < 0"}, []string{"q(X)"}); err == nil {
+		t.Error("TestDisjunction04 fails, AddRule")
+	}
+}
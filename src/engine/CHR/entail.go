@@ -0,0 +1,313 @@
+// Copyright © 2016 The Carneades Authors
+// This Source Code Form is subject to the terms of the
+// Mozilla Public License, v. 2.0. If a copy of the MPL
+// was not distributed with this file, You can obtain one
+// at http://mozilla.org/MPL/2.0/.
+
+// Entailment - a guard X @ Y (@: ==, !=, <, <=, >, >=, =<) is entailed by the
+// relations of the built-in store: symmetry of == and !=, transitivity of
+// ==, < and <=, and the equality closure over the variables
+
+package chr
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	. "github.com/hfried/GoCHR/src/engine/terms"
+)
+
+// the relations of the built-in store for the entailment
+var entailRelations = []string{"==", "!=", "<", "<=", "=<", ">", ">="}
+
+// normRelation - the relation t as a1 rel a2 with rel: ==, !=, < or <=
+func normRelation(t Compound) (rel string, a1, a2 Term, ok bool) {
+	if t.Prio == 0 || len(t.Args) != 2 {
+		return "", nil, nil, false
+	}
+	a1, a2 = t.Args[0], t.Args[1]
+	switch t.Functor {
+	case "==", "!=", "<", "<=":
+		return t.Functor, a1, a2, true
+	case "=<":
+		return "<=", a1, a2, true
+	case ">":
+		return "<", a2, a1, true
+	case ">=":
+		return "<=", a2, a1, true
+	}
+	return "", nil, nil, false
+}
+
+// termKey - a unique key of the term t, the variables with name and index
+func termKey(t Term) string {
+	var b strings.Builder
+	var key func(t Term)
+	key = func(t Term) {
+		switch t := t.(type) {
+		case Variable:
+			b.WriteString("?" + t.Key())
+		case Compound:
+			fmt.Fprintf(&b, "%s/%d(", t.Functor, t.Prio)
+			for i, a := range t.Args {
+				if i > 0 {
+					b.WriteByte(',')
+				}
+				key(a)
+			}
+			b.WriteByte(')')
+		case List:
+			b.WriteByte('[')
+			for i, a := range t {
+				if i > 0 {
+					b.WriteByte(',')
+				}
+				key(a)
+			}
+			b.WriteByte(']')
+		default:
+			fmt.Fprintf(&b, "%v:%s", t.Type(), t)
+		}
+	}
+	key(t)
+	return b.String()
+}
+
+// entailEdge - the relation from < to (strict) or from <= to
+type entailEdge struct {
+	to     string
+	strict bool
+}
+
+// entailStore - the equivalence classes of the terms of the equations and the
+// order of the classes of the built-in store
+type entailStore struct {
+	varEnv Bindings                // a variable -> the representative variable of its class
+	parent map[string]string       // union-find of the term keys
+	ground map[string]Term         // class -> a ground term of the class
+	edges  map[string][]entailEdge // class -> the classes of the relations <, <=
+	neqs   [][2]string             // the classes of the disequations
+}
+
+// newEntailStore - the equivalence classes and the order of the relations of
+// the built-in store of rs; the variables of an equation X == Y are replaced
+// by one variable of their class in all terms; the numbers of the terms are
+// ordered with the numbers of the store
+func newEntailStore(rs *Session, terms ...Term) *entailStore {
+	es := &entailStore{parent: map[string]string{}, ground: map[string]Term{},
+		edges: map[string][]entailEdge{}}
+	type relation struct {
+		rel    string
+		a1, a2 Term
+	}
+	rels := []relation{}
+	for _, functor := range entailRelations {
+		for _, con := range builtInConstraints(rs, functor) {
+			if rel, a1, a2, ok := normRelation(*con); ok {
+				rels = append(rels, relation{rel, a1, a2})
+			}
+		}
+	}
+	// the classes of the variables
+	varClass := map[string]string{}
+	vars := map[string]Variable{}
+	var findVar func(k string) string
+	findVar = func(k string) string {
+		if p, ok := varClass[k]; ok && p != k {
+			r := findVar(p)
+			varClass[k] = r
+			return r
+		}
+		return k
+	}
+	for _, r := range rels {
+		v1, ok1 := r.a1.(Variable)
+		v2, ok2 := r.a2.(Variable)
+		if r.rel != "==" || !ok1 || !ok2 {
+			continue
+		}
+		k1, k2 := findVar(v1.Key()), findVar(v2.Key())
+		vars[v1.Key()], vars[v2.Key()] = v1, v2
+		if k1 == k2 {
+			continue
+		}
+		// the smaller key represents the class
+		if k2 < k1 {
+			k1, k2 = k2, k1
+		}
+		varClass[k2] = k1
+	}
+	keys := make([]string, 0, len(vars))
+	for k := range vars {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		if r := findVar(k); r != k {
+			es.varEnv = AddBinding(vars[k], vars[r], es.varEnv)
+		}
+	}
+	// the classes of the terms and the order
+	for _, r := range rels {
+		k1, k2 := es.add(r.a1), es.add(r.a2)
+		switch r.rel {
+		case "==":
+			es.union(k1, k2)
+		case "!=":
+			es.neqs = append(es.neqs, [2]string{k1, k2})
+		case "<", "<=":
+			r1 := es.find(k1)
+			es.edges[r1] = append(es.edges[r1], entailEdge{k2, r.rel == "<"})
+		}
+	}
+	for _, t := range terms {
+		es.add(t)
+	}
+	// the order of the numbers
+	nums := []string{}
+	for k, t := range es.ground {
+		if isNumber(t) && es.find(k) == k {
+			nums = append(nums, k)
+		}
+	}
+	sort.Slice(nums, func(i, j int) bool { return cmpNumbers(es.ground[nums[i]], es.ground[nums[j]]) < 0 })
+	for i := 1; i < len(nums); i++ {
+		strict := cmpNumbers(es.ground[nums[i-1]], es.ground[nums[i]]) < 0
+		es.edges[nums[i-1]] = append(es.edges[nums[i-1]], entailEdge{nums[i], strict})
+		if !strict {
+			es.edges[nums[i]] = append(es.edges[nums[i]], entailEdge{nums[i-1], false})
+		}
+	}
+	return es
+}
+
+// add - the key of the term t with the representatives of the variables
+func (es *entailStore) add(t Term) string {
+	if es.varEnv != nil {
+		t = Substitute(t, es.varEnv)
+	}
+	k := termKey(t)
+	if _, ok := es.parent[k]; !ok {
+		es.parent[k] = k
+		if isGround(t) {
+			es.ground[k] = t
+		}
+	}
+	return k
+}
+
+func (es *entailStore) find(k string) string {
+	p, ok := es.parent[k]
+	if !ok || p == k {
+		return k
+	}
+	r := es.find(p)
+	es.parent[k] = r
+	return r
+}
+
+func (es *entailStore) union(k1, k2 string) {
+	r1, r2 := es.find(k1), es.find(k2)
+	if r1 == r2 {
+		return
+	}
+	es.parent[r2] = r1
+	if g, ok := es.ground[r2]; ok {
+		if _, ok := es.ground[r1]; !ok {
+			es.ground[r1] = g
+		}
+	}
+	es.edges[r1] = append(es.edges[r1], es.edges[r2]...)
+	delete(es.edges, r2)
+}
+
+// less - the class of k2 is reachable from the class of k1 by the relations <
+// and <= with at least one < (strict) or with any relations
+func (es *entailStore) less(k1, k2 string, strict bool) bool {
+	from, to := es.find(k1), es.find(k2)
+	if !strict && from == to {
+		return true
+	}
+	// visited[class] == true: reached with a strict relation
+	visited := map[string]bool{}
+	type node struct {
+		class  string
+		strict bool
+	}
+	queue := []node{{from, false}}
+	for len(queue) > 0 {
+		n := queue[0]
+		queue = queue[1:]
+		for _, e := range es.edges[n.class] {
+			next := node{es.find(e.to), n.strict || e.strict}
+			if next.class == to && (next.strict || !strict) {
+				return true
+			}
+			if s, ok := visited[next.class]; ok && (s || !next.strict) {
+				continue
+			}
+			visited[next.class] = next.strict
+			queue = append(queue, next)
+		}
+	}
+	return false
+}
+
+// entails - the relation a1 rel a2 follows from the relations of the store
+func (es *entailStore) entails(rel string, a1, a2 Term) bool {
+	k1, k2 := es.add(a1), es.add(a2)
+	r1, r2 := es.find(k1), es.find(k2)
+	switch rel {
+	case "==":
+		return r1 == r2
+	case "<":
+		return es.less(k1, k2, true)
+	case "<=":
+		return es.less(k1, k2, false)
+	case "!=":
+		if r1 == r2 {
+			return false
+		}
+		for _, n := range es.neqs {
+			n1, n2 := es.find(n[0]), es.find(n[1])
+			if (n1 == r1 && n2 == r2) || (n1 == r2 && n2 == r1) {
+				return true
+			}
+		}
+		// different ground terms of the classes
+		if g1, ok := es.ground[r1]; ok {
+			if g2, ok := es.ground[r2]; ok {
+				if isNumber(g1) && isNumber(g2) {
+					return cmpNumbers(g1, g2) != 0
+				}
+				return !Equal(g1, g2)
+			}
+		}
+		return es.less(k1, k2, true) || es.less(k2, k1, true)
+	}
+	return false
+}
+
+// guardEntailed - the relation g of a guard is entailed by the relations of the
+// built-in store of rs
+func guardEntailed(rs *Session, g Compound) bool {
+	rel, a1, a2, ok := normRelation(g)
+	if !ok {
+		return false
+	}
+	return newEntailStore(rs, a1, a2).entails(rel, a1, a2)
+}
+
+// builtInConstraints - the constraints of the built-in store of rs with the functor
+func builtInConstraints(rs *Session, functor string) CList {
+	cl := CList{}
+	if aChr, ok := rs.BuiltInStore[functor]; ok {
+		for _, con := range aChr.varArg {
+			if con != nil && !con.IsDeleted {
+				cl = append(cl, con)
+			}
+		}
+	}
+	return cl
+}
@@ -0,0 +1,79 @@
+// Copyright © 2016 The Carneades Authors
+// This Source Code Form is subject to the terms of the
+// Mozilla Public License, v. 2.0. If a copy of the MPL
+// was not distributed with this file, You can obtain one
+// at http://mozilla.org/MPL/2.0/.
+
+package chr
+
+import (
+	"context"
+	"testing"
+
+	. "github.com/hfried/GoCHR/src/engine/parser"
+	. "github.com/hfried/GoCHR/src/engine/terms"
+)
+
+func TestEntail01(t *testing.T) {
+	// the entailment of the relations of the query
+	for _, c := range []struct {
+		store, guard string
+		entailed     bool
+	}{
+		{"[A == B]", "B == A", true},
+		{"[A == B, B == C]", "C == A", true},
+		{"[A == B, B == C]", "f(C, [A]) == f(A, [B])", true},
+		{"[A == B]", "A == C", false},
+		{"[A != B]", "B != A", true},
+		{"[A == a, B == b]", "A != B", true},
+		{"[A == a, B == a]", "A != B", false},
+		{"[A == B, f(A) < g(B)]", "f(B) < g(A)", true},
+		{"[f(A) < g(B), g(B) <= h(C)]", "f(A) < h(C)", true},
+		{"[f(A) <= g(B), g(B) <= h(C)]", "f(A) < h(C)", false},
+		{"[f(A) <= g(B), g(B) <= h(C)]", "h(C) >= f(A)", true},
+		{"[f(A) <= g(B), g(B) <= f(A)]", "f(A) =< g(B)", true},
+		{"[f(A) < g(B)]", "g(B) != f(A)", true},
+		{"[len(L) < 3]", "len(L) < 5", true},
+		{"[len(L) < 3]", "len(L) < 2", false},
+		{"[len(L) >= 3, len(K) == len(L)]", "2 < len(K)", true},
+		{"[A < B]", "B > A", true},
+	} {
+		rs := MakeRuleStore()
+		g, _ := ReadString(c.store)
+		for _, con := range g.(List) {
+			con := con.(Compound)
+			addRefConstraintToStore(&rs.Session, &con)
+		}
+		guard, _ := ReadString(c.guard)
+		if guardEntailed(&rs.Session, guard.(Compound)) != c.entailed {
+			t.Errorf("TestEntail01 fails: %s entails %s should be %v", c.store, c.guard, c.entailed)
+		}
+	}
+}
+
+func TestEntail02(t *testing.T) {
+	// guards entailed by the built-in constraints of the bodies and the query
+	for _, mode := range []SolverMode{ModeDefault, ModeRefined} {
+		rs := MakeRuleStore()
+		rs.Mode = mode
+		ok := rs.ParseStringCHRRulesGoals(`
+		r1 @ go1(X, Y, Z) <=> f(X) < f(Y), f(Y) <= f(Z), p(X, Z).
+		r2 @ p(X, Z) <=> f(X) < f(Z) | lt(X, Z).
+		r3 @ go2(L, K) <=> len(L) > 3, len(K) >= len(L), q(K).
+		r4 @ q(K) <=> len(K) >= 2 | long(K).
+		go1(A, B, C).
+		#result: lt(A, C).
+		go2(L, K).
+		#result: long(K).
+		`)
+		if !ok {
+			t.Errorf("TestEntail02 fails, mode: %s", mode)
+		}
+		g, _ := ParseGoalString("A == B, B == C, s(C, A)")
+		rs.ParseStringCHRRulesGoals(`r5 @ s(X, Y) <=> Y == X | same(X).`)
+		res, err := rs.Solve(context.Background(), g)
+		if err != nil || findConstraint(res.CHRStore, "same(C)") == nil {
+			t.Errorf("TestEntail02 fails, mode: %s, store: %s", mode, res.CHRStore)
+		}
+	}
+}
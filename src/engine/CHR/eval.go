@@ -10,19 +10,31 @@ package chr
 
 import (
 	// "fmt"
+	"math"
+	"math/big"
+
 	. "github.com/hfried/GoCHR/src/engine/terms"
 )
 
 func Eval(t1 Term) Term {
+	t2, _ := evalBuiltins(t1, nil)
+	return t2
+}
+
+// evalBuiltins evaluates the term t1 like Eval and calls the built-in functions bi
+func evalBuiltins(t1 Term, bi builtins) (Term, error) {
 	switch t1.Type() {
-	case AtomType, BoolType, IntType, FloatType, StringType:
-		return t1
+	case AtomType, BoolType, IntType, FloatType, StringType, BigIntType, RationalType:
+		return t1, nil
 	case CompoundType:
 
 		args := []Term{}
 		tArgs := []Type{}
 		for _, a := range t1.(Compound).Args {
-			a = Eval(a)
+			a, err := evalBuiltins(a, bi)
+			if err != nil {
+				return t1, err
+			}
 			args = append(args, a)
 			tArgs = append(tArgs, a.Type())
 		}
@@ -33,42 +45,56 @@ func Eval(t1 Term) Term {
 			an := len(args)
 			switch an {
 			case 1:
-				return evalUnaryOperator(t1, args[0], tArgs[0])
+				return evalUnaryOperator(t1, args[0], tArgs[0]), nil
 			case 2:
-				return evalBinaryOperator(t1, args[0], tArgs[0], args[1], tArgs[1])
+				return evalBinaryOperator(t1, args[0], tArgs[0], args[1], tArgs[1]), nil
 			default:
-				return evalN_aryOperator(t1, args, tArgs, an)
+				return evalN_aryOperator(t1, args, tArgs, an), nil
 			}
 		}
+		t3, _, err := bi.call(t2)
+		return t3, err
 	case ListType:
 		t2 := t1.(List)
 		lent2 := len(t2)
 		if lent2 == 0 {
-			return t1
+			return t1, nil
 		}
 		lent2m1 := lent2 - 1
 		last := t2[lent2m1]
 		t3 := List{}
 		if last.Type() == CompoundType && last.(Compound).Functor == "|" {
 			for i := 0; i < lent2m1; i++ {
-				t3 = append(t3, Eval(t2[i]))
+				t5, err := evalBuiltins(t2[i], bi)
+				if err != nil {
+					return t1, err
+				}
+				t3 = append(t3, t5)
 			}
 			t4 := last.(Compound).Args[0]
 			if t4.Type() == ListType {
 				for _, t5 := range t4.(List) {
-					t3 = append(t3, Eval(t5))
+					t5, err := evalBuiltins(t5, bi)
+					if err != nil {
+						return t1, err
+					}
+					t3 = append(t3, t5)
 				}
 			}
 			t1 = t3
 		} else {
 
 			for _, t4 := range t2 {
-				t3 = append(t3, Eval(t4))
+				t4, err := evalBuiltins(t4, bi)
+				if err != nil {
+					return t1, err
+				}
+				t3 = append(t3, t4)
 			}
 			t1 = t3
 		}
 	}
-	return t1
+	return t1, nil
 }
 
 func evalUnaryOperator(t1, arg Term, typ Type) Term {
@@ -93,6 +119,8 @@ func evalBinaryOperator(t1, a1 Term, typ1 Type, a2 Term, typ2 Type) Term {
 		return evalDivision(t1, a1, typ1, a2, typ2)
 	case "div":
 		return evalDiv(t1, a1, typ1, a2, typ2)
+	case "rdiv":
+		return evalRDiv(t1, a1, typ1, a2, typ2)
 	case "%", "mod":
 		return evalMod(t1, a1, typ1, a2, typ2)
 	case "&":
@@ -123,6 +151,8 @@ func evalBinaryOperator(t1, a1 Term, typ1 Type, a2 Term, typ2 Type) Term {
 		return evalGt(t1, a1, typ1, a2, typ2)
 	case ">=":
 		return evalGtEq(t1, a1, typ1, a2, typ2)
+	case "in":
+		return evalIn(t1, a1, typ1, a2)
 	case "&&":
 		return evalLogAnd(t1, a1, typ1, a2, typ2)
 	case "||":
@@ -141,9 +171,16 @@ func evalUnaryMinus(t1 Term, a1 Term, typ1 Type) Term {
 	// -a1
 	switch typ1 {
 	case IntType:
+		if a1.(Int) == math.MinInt {
+			return NewBigInt(new(big.Int).Neg(bigInt(a1)))
+		}
 		return -a1.(Int)
 	case FloatType:
 		return -a1.(Float)
+	case BigIntType:
+		return NewBigInt(new(big.Int).Neg(bigInt(a1)))
+	case RationalType:
+		return NewRational(new(big.Rat).Neg(bigRat(a1)))
 	}
 	return t1
 }
@@ -196,19 +233,25 @@ func evalNot(t1 Term, a1 Term, typ1 Type) Term {
 
 func evalComp(t1 Term, a1 Term, typ1 Type) Term {
 	// ^a1
-	if typ1 == IntType {
+	switch typ1 {
+	case IntType:
 		return ^a1.(Int)
+	case BigIntType:
+		return NewBigInt(new(big.Int).Not(bigInt(a1)))
 	}
 	return t1
 }
 
 func evalTimes(t1 Term, a1 Term, typ1 Type, a2 Term, typ2 Type) Term {
 	// a1 * a2
+	if isBigNumber(typ1, typ2) {
+		return evalBigNumber(t1, a1, typ1, a2, typ2, (*big.Int).Mul, (*big.Rat).Mul, func(x, y float64) float64 { return x * y })
+	}
 	switch typ1 {
 	case IntType:
 		switch typ2 {
 		case IntType:
-			return a1.(Int) * a2.(Int)
+			return timesInt(a1.(Int), a2.(Int))
 		case FloatType:
 			return Float(float64(a1.(Int)) * float64(a2.(Float)))
 		default:
@@ -228,13 +271,19 @@ func evalTimes(t1 Term, a1 Term, typ1 Type, a2 Term, typ2 Type) Term {
 }
 
 func evalDivision(t1 Term, a1 Term, typ1 Type, a2 Term, typ2 Type) Term {
-	// if a2 != 0 { a1 / a2 }
+	// if a2 != 0 { a1 / a2 }, the integer division for integers
+	if isBigNumber(typ1, typ2) {
+		if isZero(a2) {
+			return t1
+		}
+		return evalBigNumber(t1, a1, typ1, a2, typ2, (*big.Int).Quo, (*big.Rat).Quo, func(x, y float64) float64 { return x / y })
+	}
 	switch typ1 {
 	case IntType:
 		switch typ2 {
 		case IntType:
 			if a2.(Int) != 0 {
-				return a1.(Int) / a2.(Int)
+				return divInt(a1.(Int), a2.(Int))
 			}
 		case FloatType:
 			if a2.(Float) != 0.0 {
@@ -262,14 +311,28 @@ func evalDivision(t1 Term, a1 Term, typ1 Type, a2 Term, typ2 Type) Term {
 
 func evalDiv(t1 Term, a1 Term, typ1 Type, a2 Term, typ2 Type) Term {
 	// a1 / a2 for integer
+	if isBigNumber(typ1, typ2) && !isZero(a2) {
+		return evalBigNumber(t1, a1, typ1, a2, typ2, (*big.Int).Quo, nil, nil)
+	}
 	if typ1 != IntType || typ2 != IntType || a2.(Int) == 0 {
 		return t1
 	}
-	return a1.(Int) / a2.(Int)
+	return divInt(a1.(Int), a2.(Int))
+}
+
+func evalRDiv(t1 Term, a1 Term, typ1 Type, a2 Term, typ2 Type) Term {
+	// a1 / a2 for integer and rational numbers, a rational number
+	if !isExact(typ1) || !isExact(typ2) || isZero(a2) {
+		return t1
+	}
+	return NewRational(new(big.Rat).Quo(bigRat(a1), bigRat(a2)))
 }
 
 func evalMod(t1 Term, a1 Term, typ1 Type, a2 Term, typ2 Type) Term {
 	// a1 % a2 for integer
+	if isBigNumber(typ1, typ2) && !isZero(a2) {
+		return evalBigNumber(t1, a1, typ1, a2, typ2, (*big.Int).Rem, nil, nil)
+	}
 	if typ1 != IntType || typ2 != IntType || a2.(Int) == 0 {
 		return t1
 	}
@@ -278,6 +341,9 @@ func evalMod(t1 Term, a1 Term, typ1 Type, a2 Term, typ2 Type) Term {
 
 func evalBitAnd(t1 Term, a1 Term, typ1 Type, a2 Term, typ2 Type) Term {
 	// a1 & a2 for integer
+	if isBigNumber(typ1, typ2) {
+		return evalBigNumber(t1, a1, typ1, a2, typ2, (*big.Int).And, nil, nil)
+	}
 	if typ1 != IntType || typ2 != IntType {
 		return t1
 	}
@@ -286,6 +352,9 @@ func evalBitAnd(t1 Term, a1 Term, typ1 Type, a2 Term, typ2 Type) Term {
 
 func evalBitAndNot(t1 Term, a1 Term, typ1 Type, a2 Term, typ2 Type) Term {
 	// a1 &^ a2 for integer
+	if isBigNumber(typ1, typ2) {
+		return evalBigNumber(t1, a1, typ1, a2, typ2, (*big.Int).AndNot, nil, nil)
+	}
 	if typ1 != IntType || typ2 != IntType {
 		return t1
 	}
@@ -294,14 +363,20 @@ func evalBitAndNot(t1 Term, a1 Term, typ1 Type, a2 Term, typ2 Type) Term {
 
 func evalLeftShift(t1 Term, a1 Term, typ1 Type, a2 Term, typ2 Type) Term {
 	// a1 << a2 for integer
+	if typ1 == BigIntType && typ2 == IntType && a2.(Int) >= 0 {
+		return NewBigInt(new(big.Int).Lsh(bigInt(a1), uint(a2.(Int))))
+	}
 	if typ1 != IntType || typ2 != IntType {
 		return t1
 	}
-	return Int(uint(a1.(Int)) << uint(a2.(Int)))
+	return leftShiftInt(a1.(Int), a2.(Int))
 }
 
 func evalRightShift(t1 Term, a1 Term, typ1 Type, a2 Term, typ2 Type) Term {
 	// a1 >> a2 for integer
+	if typ1 == BigIntType && typ2 == IntType && a2.(Int) >= 0 {
+		return NewBigInt(new(big.Int).Rsh(bigInt(a1), uint(a2.(Int))))
+	}
 	if typ1 != IntType || typ2 != IntType {
 		return t1
 	}
@@ -309,11 +384,14 @@ func evalRightShift(t1 Term, a1 Term, typ1 Type, a2 Term, typ2 Type) Term {
 }
 
 func evalPlus(t1 Term, a1 Term, typ1 Type, a2 Term, typ2 Type) Term {
+	if isBigNumber(typ1, typ2) {
+		return evalBigNumber(t1, a1, typ1, a2, typ2, (*big.Int).Add, (*big.Rat).Add, func(x, y float64) float64 { return x + y })
+	}
 	switch typ1 {
 	case IntType:
 		switch typ2 {
 		case IntType:
-			return a1.(Int) + a2.(Int)
+			return plusInt(a1.(Int), a2.(Int))
 		case FloatType:
 			return Float(float64(a1.(Int)) + float64(a2.(Float)))
 		default:
@@ -337,11 +415,14 @@ func evalPlus(t1 Term, a1 Term, typ1 Type, a2 Term, typ2 Type) Term {
 }
 
 func evalMinus(t1 Term, a1 Term, typ1 Type, a2 Term, typ2 Type) Term {
+	if isBigNumber(typ1, typ2) {
+		return evalBigNumber(t1, a1, typ1, a2, typ2, (*big.Int).Sub, (*big.Rat).Sub, func(x, y float64) float64 { return x - y })
+	}
 	switch typ1 {
 	case IntType:
 		switch typ2 {
 		case IntType:
-			return a1.(Int) - a2.(Int)
+			return minusInt(a1.(Int), a2.(Int))
 		case FloatType:
 			return Float(float64(a1.(Int)) - float64(a2.(Float)))
 		default:
@@ -362,6 +443,9 @@ func evalMinus(t1 Term, a1 Term, typ1 Type, a2 Term, typ2 Type) Term {
 
 func evalBitOr(t1 Term, a1 Term, typ1 Type, a2 Term, typ2 Type) Term {
 	// a1 or a2 == a1 | a2 for integer
+	if isBigNumber(typ1, typ2) {
+		return evalBigNumber(t1, a1, typ1, a2, typ2, (*big.Int).Or, nil, nil)
+	}
 	if typ1 != IntType || typ2 != IntType {
 		return t1
 	}
@@ -370,6 +454,9 @@ func evalBitOr(t1 Term, a1 Term, typ1 Type, a2 Term, typ2 Type) Term {
 
 func evalBitXOr(t1 Term, a1 Term, typ1 Type, a2 Term, typ2 Type) Term {
 	// a1 ^ a2 for integer
+	if isBigNumber(typ1, typ2) {
+		return evalBigNumber(t1, a1, typ1, a2, typ2, (*big.Int).Xor, nil, nil)
+	}
 	if typ1 != IntType || typ2 != IntType {
 		return t1
 	}
@@ -378,6 +465,9 @@ func evalBitXOr(t1 Term, a1 Term, typ1 Type, a2 Term, typ2 Type) Term {
 
 func evalEq(t1 Term, a1 Term, typ1 Type, a2 Term, typ2 Type) Term {
 	// a1 == a2
+	if isBigNumber(typ1, typ2) {
+		return Bool(cmpNumbers(a1, a2) == 0)
+	}
 	switch typ1 {
 	case IntType:
 		switch typ2 {
@@ -415,6 +505,9 @@ func evalEq(t1 Term, a1 Term, typ1 Type, a2 Term, typ2 Type) Term {
 
 func evalNotEq(t1 Term, a1 Term, typ1 Type, a2 Term, typ2 Type) Term {
 	// a1 != a2
+	if isBigNumber(typ1, typ2) {
+		return Bool(cmpNumbers(a1, a2) != 0)
+	}
 	switch typ1 {
 	case IntType:
 		switch typ2 {
@@ -452,6 +545,9 @@ func evalNotEq(t1 Term, a1 Term, typ1 Type, a2 Term, typ2 Type) Term {
 
 func evalLess(t1 Term, a1 Term, typ1 Type, a2 Term, typ2 Type) Term {
 	// a1 < a2
+	if isBigNumber(typ1, typ2) {
+		return Bool(cmpNumbers(a1, a2) < 0)
+	}
 	switch typ1 {
 	case IntType:
 		switch typ2 {
@@ -481,6 +577,9 @@ func evalLess(t1 Term, a1 Term, typ1 Type, a2 Term, typ2 Type) Term {
 
 func evalLessEq(t1 Term, a1 Term, typ1 Type, a2 Term, typ2 Type) Term {
 	// a1 <= a2 or a1 =< a2
+	if isBigNumber(typ1, typ2) {
+		return Bool(cmpNumbers(a1, a2) <= 0)
+	}
 	switch typ1 {
 	case IntType:
 		switch typ2 {
@@ -510,6 +609,9 @@ func evalLessEq(t1 Term, a1 Term, typ1 Type, a2 Term, typ2 Type) Term {
 
 func evalGt(t1 Term, a1 Term, typ1 Type, a2 Term, typ2 Type) Term {
 	// a1 > a2
+	if isBigNumber(typ1, typ2) {
+		return Bool(cmpNumbers(a1, a2) > 0)
+	}
 	switch typ1 {
 	case IntType:
 		switch typ2 {
@@ -537,6 +639,9 @@ func evalGt(t1 Term, a1 Term, typ1 Type, a2 Term, typ2 Type) Term {
 
 func evalGtEq(t1 Term, a1 Term, typ1 Type, a2 Term, typ2 Type) Term {
 	// a1 >= a2
+	if isBigNumber(typ1, typ2) {
+		return Bool(cmpNumbers(a1, a2) >= 0)
+	}
 	switch typ1 {
 	case IntType:
 		switch typ2 {
@@ -562,6 +667,17 @@ func evalGtEq(t1 Term, a1 Term, typ1 Type, a2 Term, typ2 Type) Term {
 	return c
 }
 
+func evalIn(t1 Term, a1 Term, typ1 Type, a2 Term) Term {
+	// a1 in a2, a2 a range L..U or a list of integers and ranges
+	if typ1 != IntType {
+		return t1
+	}
+	if d, ok := parseFDDomain(a2); ok {
+		return Bool(d.contains(int(a1.(Int))))
+	}
+	return t1
+}
+
 func evalLogAnd(t1 Term, a1 Term, typ1 Type, a2 Term, typ2 Type) Term {
 	// a1 && a2
 	if typ1 == BoolType && typ2 == BoolType {
@@ -635,3 +751,148 @@ func evalCons(t1 Term, a1 Term, typ1 Type, a2 Term, typ2 Type) Term {
 	// fmt.Printf(" Eval-Cons:  A1: '%s', | A2: '%s', == '%s' \n", a1, a2, t2)
 	return t2
 }
+
+// Integers and rational numbers of arbitrary size: an Int is promoted to a
+// BigInt on overflow, the results are normalized by NewBigInt and NewRational
+
+// isExact - typ is the type of an integer or a rational number
+func isExact(typ Type) bool {
+	return typ == IntType || typ == BigIntType || typ == RationalType
+}
+
+// isBigNumber - typ1 and typ2 are number types and one of them is BigInt or Rational
+func isBigNumber(typ1, typ2 Type) bool {
+	return (typ1 == BigIntType || typ1 == RationalType || typ2 == BigIntType || typ2 == RationalType) &&
+		(isExact(typ1) || typ1 == FloatType) && (isExact(typ2) || typ2 == FloatType)
+}
+
+// isZero - the number t is 0; a BigInt or a Rational is never 0
+func isZero(t Term) bool {
+	switch t := t.(type) {
+	case Int:
+		return t == 0
+	case Float:
+		return t == 0.0
+	}
+	return false
+}
+
+// bigInt - the value of an Int or a BigInt
+func bigInt(t Term) *big.Int {
+	if i, ok := t.(Int); ok {
+		return big.NewInt(int64(i))
+	}
+	return t.(BigInt).Big()
+}
+
+// bigRat - the value of an Int, a BigInt or a Rational
+func bigRat(t Term) *big.Rat {
+	switch t := t.(type) {
+	case Int:
+		return new(big.Rat).SetInt64(int64(t))
+	case BigInt:
+		return new(big.Rat).SetInt(t.Big())
+	}
+	return t.(Rational).Rat()
+}
+
+// numberRat - the exact value of a number, nil for an infinite Float or NaN
+func numberRat(t Term) *big.Rat {
+	if f, ok := t.(Float); ok {
+		return new(big.Rat).SetFloat64(float64(f))
+	}
+	return bigRat(t)
+}
+
+func toFloat(t Term) float64 {
+	switch t := t.(type) {
+	case Int:
+		return float64(t)
+	case Float:
+		return float64(t)
+	}
+	f, _ := bigRat(t).Float64()
+	return f
+}
+
+// evalBigNumber evaluates the operation t1 for the numbers a1 and a2, one of them a
+// BigInt or a Rational: with ff, if one of them is a Float, with fr, if one of them
+// is a Rational, else with fi; t1, if the operation is not defined (nil)
+func evalBigNumber(t1 Term, a1 Term, typ1 Type, a2 Term, typ2 Type,
+	fi func(z, x, y *big.Int) *big.Int, fr func(z, x, y *big.Rat) *big.Rat, ff func(x, y float64) float64) Term {
+	switch {
+	case typ1 == FloatType || typ2 == FloatType:
+		if ff != nil {
+			return Float(ff(toFloat(a1), toFloat(a2)))
+		}
+	case typ1 == RationalType || typ2 == RationalType:
+		if fr != nil {
+			return NewRational(fr(new(big.Rat), bigRat(a1), bigRat(a2)))
+		}
+	default:
+		if fi != nil {
+			return NewBigInt(fi(new(big.Int), bigInt(a1), bigInt(a2)))
+		}
+	}
+	return t1
+}
+
+// cmpNumbers compares the numbers a1 and a2: -1, 0 or +1
+func cmpNumbers(a1, a2 Term) int {
+	r1, r2 := numberRat(a1), numberRat(a2)
+	if r1 != nil && r2 != nil {
+		return r1.Cmp(r2)
+	}
+	x, y := toFloat(a1), toFloat(a2)
+	switch {
+	case x < y:
+		return -1
+	case x > y:
+		return 1
+	}
+	return 0
+}
+
+// plusInt - x + y, a BigInt on overflow
+func plusInt(x, y Int) Term {
+	z := x + y
+	if (z > x) == (y > 0) {
+		return z
+	}
+	return NewBigInt(new(big.Int).Add(bigInt(x), bigInt(y)))
+}
+
+// minusInt - x - y, a BigInt on overflow
+func minusInt(x, y Int) Term {
+	z := x - y
+	if (z < x) == (y > 0) {
+		return z
+	}
+	return NewBigInt(new(big.Int).Sub(bigInt(x), bigInt(y)))
+}
+
+// timesInt - x * y, a BigInt on overflow
+func timesInt(x, y Int) Term {
+	z := x * y
+	if x == 0 || z/x == y && !(x == -1 && y == math.MinInt) {
+		return z
+	}
+	return NewBigInt(new(big.Int).Mul(bigInt(x), bigInt(y)))
+}
+
+// divInt - x / y for y != 0, a BigInt on overflow
+func divInt(x, y Int) Term {
+	if x == math.MinInt && y == -1 {
+		return NewBigInt(new(big.Int).Neg(bigInt(x)))
+	}
+	return x / y
+}
+
+// leftShiftInt - x << n, a BigInt on overflow
+func leftShiftInt(x, n Int) Term {
+	z := Int(uint(x) << uint(n))
+	if n < 0 || z>>uint(n) == x {
+		return z
+	}
+	return NewBigInt(new(big.Int).Lsh(bigInt(x), uint(n)))
+}
@@ -8,6 +8,9 @@ package chr
 
 import (
 	"testing"
+
+	. "github.com/hfried/GoCHR/src/engine/parser"
+	. "github.com/hfried/GoCHR/src/engine/terms"
 )
 
 func TestEval01(t *testing.T) {
@@ -341,3 +344,117 @@ func TestEval39(t *testing.T) {
 //		t.Errorf("TestEval40 failed\n")
 //	}
 //}
+
+func TestEval41(t *testing.T) {
+	// check the promotion of Int to BigInt on overflow
+	for _, c := range [][2]string{
+		{"9223372036854775807 + 1", "9223372036854775808"},
+		{"4294967296 * 4294967296", "18446744073709551616"},
+		{"1 << 70", "1180591620717411303424"},
+		{"0 - 9223372036854775807 - 2 < 0", "true"},
+		{"18446744073709551616 - 18446744073709551615", "1"},
+		{"18446744073709551616 / 4294967296", "4294967296"},
+	} {
+		if !teval(t, c[0], c[1]) {
+			t.Errorf("TestEval41 failed: %s\n", c[0])
+		}
+	}
+}
+
+func TestEval42(t *testing.T) {
+	// check operators and comparisons with BigInt
+	for _, c := range [][2]string{
+		{"100000000000000000000 div 3", "33333333333333333333"},
+		{"100000000000000000000 mod 7", "2"},
+		{"100000000000000000000 / 2.0", "50000000000000000000.0"},
+		{"1180591620717411303424 >> 70", "1"},
+		{"18446744073709551616 & 18446744073709551617", "18446744073709551616"},
+		{"18446744073709551616 > 9223372036854775807", "true"},
+		{"18446744073709551616 == 18446744073709551616", "true"},
+		{"18446744073709551616 != 18446744073709551617", "true"},
+		{"18446744073709551616 <= 1.5", "false"},
+		{"-(-18446744073709551616)", "18446744073709551616"},
+		{"123456789012345678901234567890 == a", "123456789012345678901234567890 == a"},
+	} {
+		if !teval(t, c[0], c[1]) {
+			t.Errorf("TestEval42 failed: %s\n", c[0])
+		}
+	}
+}
+
+func TestEval43(t *testing.T) {
+	// check rational numbers
+	for _, c := range [][2]string{
+		{"1 rdiv 3 + 1 rdiv 6 == 1 rdiv 2", "true"},
+		{"(2 rdiv 3) * 3", "2"},
+		{"(1 rdiv 3) / (1 rdiv 3)", "1"},
+		{"1 rdiv 3 < 0.34", "true"},
+		{"-(1 rdiv 3) < 0", "true"},
+		{"1 rdiv 3 != 1 rdiv 4", "true"},
+		{"1 rdiv 0", "1 rdiv 0"},
+	} {
+		if !teval(t, c[0], c[1]) {
+			t.Errorf("TestEval43 failed: %s\n", c[0])
+		}
+	}
+	r := Eval(Compound{Functor: "rdiv", Prio: 5, Args: []Term{Int(-2), Int(6)}})
+	if r.Type() != RationalType || r.String() != "-1 rdiv 3" {
+		t.Errorf("TestEval43 failed: -2 rdiv 6 == %s\n", r)
+	}
+}
+
+func TestEval45(t *testing.T) {
+	// a printed rational number is read back as the same number
+	third := Eval(Compound{Functor: "rdiv", Prio: 5, Args: []Term{Int(1), Int(3)}})
+	x := NewVariable("X")
+	for _, c := range []struct {
+		t    Term
+		want string
+	}{
+		{third, "1 rdiv 3"},
+		{Compound{Functor: "*", Prio: 5, Args: []Term{x, third}}, "X * (1 rdiv 3)"},
+		{Compound{Functor: "*", Prio: 5, Args: []Term{third, x}}, "1 rdiv 3*X"},
+		{Compound{Functor: "+", Prio: 4, Args: []Term{x, third}}, "X+1 rdiv 3"},
+		{Compound{Functor: "-", Prio: 6, Args: []Term{third}}, "-(1 rdiv 3)"},
+	} {
+		if got := c.t.String(); got != c.want {
+			t.Errorf("TestEval45 failed: %s, should be %s\n", got, c.want)
+		}
+		t1, ok := ReadString(c.t.String())
+		if !ok || !Equal(Eval(Substitute(t1, AddBinding(x, Int(6), nil))),
+			Eval(Substitute(c.t, AddBinding(x, Int(6), nil)))) {
+			t.Errorf("TestEval45 failed: %s read as %s\n", c.t, t1)
+		}
+	}
+	rs := MakeRuleStore()
+	ok := rs.ParseStringCHRRulesGoals(`
+	third @ third(X) <=> Y := X rdiv 3 | part(Y).
+	third(1), third(-2).
+	#result: part(1 rdiv 3), part(-2 rdiv 3).
+	`)
+	if !ok {
+		t.Error("TestEval45 fails, #result")
+	}
+}
+
+func TestEval44(t *testing.T) {
+	// big numbers in rules, goals and the store index
+	for _, mode := range []SolverMode{ModeDefault, ModeRefined} {
+		rs := MakeRuleStore()
+		rs.Mode = mode
+		ok := rs.ParseStringCHRRulesGoals(`
+		fac @ fac(N, F) <=> N > 0, M := N - 1, G := F * N | fac(M, G).
+		gcd1 @ gcd(0) <=> true .
+		gcd2 @ gcd(N) \ gcd(M) <=> N <= M, L := M mod N | gcd(L).
+		big @ num(18446744073709551616) <=> found.
+		half @ half(X) <=> Y := X rdiv 2, Y * 2 == X, Y < X | halved.
+		fac(25, 1), gcd(123456789012345678901234567890), gcd(987654321098765432109876543210),
+		  num(1), num(18446744073709551616), half(7).
+		#result: fac(0, 15511210043330985984000000), gcd(9000000000900000000090),
+		  num(1), found, halved.
+		`)
+		if !ok {
+			t.Errorf("TestEval44 fails, mode: %s", mode)
+		}
+	}
+}
@@ -0,0 +1,668 @@
+// Copyright © 2016 The Carneades Authors
+// This Source Code Form is subject to the terms of the
+// Mozilla Public License, v. 2.0. If a copy of the MPL
+// was not distributed with this file, You can obtain one
+// at http://mozilla.org/MPL/2.0/.
+
+// Finite domains - the built-in constraints X in 1..9, alldifferent(L) and label(L)
+// with the propagators of the domains of the integer variables
+
+package chr
+
+import (
+	"math/big"
+	"sort"
+
+	. "github.com/hfried/GoCHR/src/engine/terms"
+)
+
+const FDHelp = `The finite domain constraints are:
+
+X in L..U           - the integer variable X has a value from L to U
+X in [1, 3, 5..7]   - the domain as list of integers and ranges
+[X, Y] in 1..9      - X in 1..9, Y in 1..9
+alldifferent(L)     - the variables and integers of the list L are pairwise different
+label(L)            - the variables of L get the values of their domains, one variable
+                      after the other, as split disjunction (X == 1 ; X == 2 ; ...)
+
+The constraints are built-in constraints. When no rule is applicable, the domains
+are narrowed: the linear constraints ==, !=, <, <=, >, >= of variables with domains
+narrow the bounds of the domains, alldifferent removes the values of the variables
+with a value and of the Hall intervals from the other domains. A variable with a
+single value is bound by the equation X == value, an empty domain fails. In a guard,
+X in D is true, if X is an integer of D. A CHR-constraint in a rule head hides
+alldifferent/1 and label/1.`
+
+// fdInterval - the integers from lo to hi
+type fdInterval struct {
+	lo, hi int
+}
+
+// fdDomain - a finite domain, sorted, disjoint and not adjacent intervals
+type fdDomain []fdInterval
+
+// newFDDomain - the domain of the values of the intervals ivs
+func newFDDomain(ivs []fdInterval) fdDomain {
+	sort.Slice(ivs, func(i, j int) bool { return ivs[i].lo < ivs[j].lo })
+	d := fdDomain{}
+	for _, iv := range ivs {
+		if iv.lo > iv.hi {
+			continue
+		}
+		if n := len(d); n > 0 && iv.lo <= d[n-1].hi+1 {
+			if iv.hi > d[n-1].hi {
+				d[n-1].hi = iv.hi
+			}
+			continue
+		}
+		d = append(d, iv)
+	}
+	return d
+}
+
+// parseFDDomain - the domain of the range L..U or the list of integers and ranges t
+func parseFDDomain(t Term) (fdDomain, bool) {
+	ivs := []fdInterval{}
+	l, ok := t.(List)
+	if !ok {
+		l = List{t}
+	}
+	for _, t1 := range l {
+		switch t1 := t1.(type) {
+		case Int:
+			ivs = append(ivs, fdInterval{int(t1), int(t1)})
+		case Compound:
+			if t1.Functor != ".." || len(t1.Args) != 2 {
+				return nil, false
+			}
+			lo, ok1 := t1.Args[0].(Int)
+			hi, ok2 := t1.Args[1].(Int)
+			if !ok1 || !ok2 {
+				return nil, false
+			}
+			ivs = append(ivs, fdInterval{int(lo), int(hi)})
+		default:
+			return nil, false
+		}
+	}
+	return newFDDomain(ivs), true
+}
+
+// term - the domain d as range or as list of integers and ranges
+func (d fdDomain) term() Term {
+	rng := func(iv fdInterval) Term {
+		if iv.lo == iv.hi {
+			return Int(iv.lo)
+		}
+		return Compound{Functor: "..", Prio: 3, Args: []Term{Int(iv.lo), Int(iv.hi)}}
+	}
+	if len(d) == 1 && d[0].lo != d[0].hi {
+		return rng(d[0])
+	}
+	l := List{}
+	for _, iv := range d {
+		l = append(l, rng(iv))
+	}
+	return l
+}
+
+func (d fdDomain) min() int {
+	return d[0].lo
+}
+
+func (d fdDomain) max() int {
+	return d[len(d)-1].hi
+}
+
+// value - the value of a domain with a single value
+func (d fdDomain) value() (int, bool) {
+	if len(d) == 1 && d[0].lo == d[0].hi {
+		return d[0].lo, true
+	}
+	return 0, false
+}
+
+func (d fdDomain) contains(v int) bool {
+	for _, iv := range d {
+		if v >= iv.lo && v <= iv.hi {
+			return true
+		}
+	}
+	return false
+}
+
+func (d fdDomain) equal(d2 fdDomain) bool {
+	if len(d) != len(d2) {
+		return false
+	}
+	for i := range d {
+		if d[i] != d2[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// intersect - the values of d and of d2
+func (d fdDomain) intersect(d2 fdDomain) fdDomain {
+	d3 := fdDomain{}
+	for i, j := 0, 0; i < len(d) && j < len(d2); {
+		lo, hi := d[i].lo, d[i].hi
+		if d2[j].lo > lo {
+			lo = d2[j].lo
+		}
+		if d2[j].hi < hi {
+			hi = d2[j].hi
+		}
+		if lo <= hi {
+			d3 = append(d3, fdInterval{lo, hi})
+		}
+		if d[i].hi < d2[j].hi {
+			i++
+		} else {
+			j++
+		}
+	}
+	return d3
+}
+
+// remove - the values of d without the values from lo to hi
+func (d fdDomain) remove(lo, hi int) fdDomain {
+	d2 := fdDomain{}
+	for _, iv := range d {
+		if iv.hi < lo || iv.lo > hi {
+			d2 = append(d2, iv)
+			continue
+		}
+		if iv.lo < lo {
+			d2 = append(d2, fdInterval{iv.lo, lo - 1})
+		}
+		if iv.hi > hi {
+			d2 = append(d2, fdInterval{hi + 1, iv.hi})
+		}
+	}
+	return d2
+}
+
+// values - the values of d in ascending order
+func (d fdDomain) values() []int {
+	vals := []int{}
+	for _, iv := range d {
+		for v := iv.lo; v <= iv.hi; v++ {
+			vals = append(vals, v)
+		}
+	}
+	return vals
+}
+
+// fdLinear - the linear constraint sum a[i]*vars[i] + c rel 0 with integers,
+// rel: "<=", "==" or "!="
+type fdLinear struct {
+	vars []string
+	a    []*big.Int
+	c    *big.Int
+	rel  string
+}
+
+// newFDLinear - the linear constraint c multiplied by the least common multiple of
+// the denominators, e < 0 as e + 1 <= 0
+func newFDLinear(c linConstraint) fdLinear {
+	den := new(big.Int).Set(c.e.c.Denom())
+	for _, a := range c.e.coef {
+		g := new(big.Int).GCD(nil, nil, den, a.Denom())
+		den.Mul(den, new(big.Int).Quo(a.Denom(), g))
+	}
+	scale := func(r *big.Rat) *big.Int {
+		return new(big.Int).Mul(r.Num(), new(big.Int).Quo(den, r.Denom()))
+	}
+	fl := fdLinear{c: scale(c.e.c), rel: c.rel}
+	for _, v := range c.e.vars() {
+		fl.vars = append(fl.vars, v)
+		fl.a = append(fl.a, scale(c.e.coef[v]))
+	}
+	if fl.rel == "<" {
+		fl.rel = "<="
+		fl.c.Add(fl.c, bigOne)
+	}
+	return fl
+}
+
+// fdAllDiff - a constraint alldifferent with its variables with domains and its integers
+type fdAllDiff struct {
+	con    *Compound
+	vars   []string
+	consts []int
+	others bool // other terms, e.g. variables without domain
+}
+
+// fdStore - the finite domain constraints of the built-in store
+type fdStore struct {
+	lin      *linSystem          // the variables of the constraints
+	dom      map[string]fdDomain // the domains of the variables
+	dom0     map[string]fdDomain // the domains before the propagation
+	bound    map[string]bool     // the variable is bound by an equation X == value of the store
+	ins      map[string]CList    // the in-constraints of the variables
+	rels     []fdLinear
+	alldiffs []fdAllDiff
+	labels   CList
+}
+
+// isFDConstraint - g is a finite domain constraint of the built-in store; a rule
+// head with alldifferent or label hides the built-in constraint
+func isFDConstraint(rs *Session, g *Compound) bool {
+	switch g.Functor {
+	case "in":
+		return g.Prio != 0 && len(g.Args) == 2
+	case "alldifferent", "label":
+		_, hidden := rs.prog.pred2rule[g.Functor]
+		return g.Prio == 0 && len(g.Args) == 1 && !hidden
+	}
+	return false
+}
+
+// splitFDList adds the constraint [X, Y, ...] in D as X in D, Y in D, ...
+func splitFDList(rs *Session, g *Compound) bool {
+	if g.Functor != "in" || g.Prio == 0 || len(g.Args) != 2 {
+		return false
+	}
+	l, ok := g.Args[0].(List)
+	if !ok {
+		return false
+	}
+	for _, t := range l {
+		addConstraintToStore(rs, Compound{Functor: "in", Prio: g.Prio, Args: []Term{t, g.Args[1]}})
+	}
+	return true
+}
+
+// newFDStore - the finite domain constraints of the built-in store of rs: the
+// in-constraints, the equations X == value, the linear constraints of variables
+// with domains, alldifferent and label
+func newFDStore(rs *Session) *fdStore {
+	fs := &fdStore{lin: &linSystem{vars: map[string]Variable{}}, dom: map[string]fdDomain{},
+		bound: map[string]bool{}, ins: map[string]CList{}}
+	for _, con := range builtInConstraints(rs, "in") {
+		v, ok := con.Args[0].(Variable)
+		if !ok || len(con.Args) != 2 {
+			continue
+		}
+		if d, ok := parseFDDomain(Eval(con.Args[1])); ok {
+			fs.addDomain(v, d)
+			fs.ins[v.Key()] = append(fs.ins[v.Key()], con)
+		}
+	}
+	for _, con := range builtInConstraints(rs, "==") {
+		v, ok1 := con.Args[0].(Variable)
+		i, ok2 := con.Args[1].(Int)
+		if !ok1 || !ok2 {
+			v, ok1 = con.Args[1].(Variable)
+			i, ok2 = con.Args[0].(Int)
+		}
+		if ok1 && ok2 {
+			fs.addDomain(v, fdDomain{{int(i), int(i)}})
+			fs.bound[v.Key()] = true
+		}
+	}
+	for _, rel := range linRelations {
+	cons:
+		for _, con := range builtInConstraints(rs, rel) {
+			c, ok := fs.lin.linearRelation(*con)
+			if !ok || len(c.e.coef) == 0 {
+				continue
+			}
+			for v := range c.e.coef {
+				if _, ok := fs.dom[v]; !ok {
+					continue cons
+				}
+			}
+			fs.rels = append(fs.rels, newFDLinear(c))
+		}
+	}
+	for _, con := range builtInConstraints(rs, "alldifferent") {
+		l, ok := con.Args[0].(List)
+		if !ok {
+			continue
+		}
+		ad := fdAllDiff{con: con}
+		for _, t := range l {
+			switch t := t.(type) {
+			case Int:
+				ad.consts = append(ad.consts, int(t))
+			case Variable:
+				if _, ok := fs.dom[t.Key()]; ok {
+					ad.vars = append(ad.vars, t.Key())
+				} else {
+					ad.others = true
+				}
+			default:
+				ad.others = true
+			}
+		}
+		fs.alldiffs = append(fs.alldiffs, ad)
+	}
+	for _, con := range builtInConstraints(rs, "label") {
+		if _, ok := con.Args[0].(List); ok {
+			fs.labels = append(fs.labels, con)
+		}
+	}
+	fs.dom0 = make(map[string]fdDomain, len(fs.dom))
+	for k, d := range fs.dom {
+		fs.dom0[k] = d
+	}
+	return fs
+}
+
+func (fs *fdStore) addDomain(v Variable, d fdDomain) {
+	k := v.Key()
+	fs.lin.vars[k] = v
+	if d0, ok := fs.dom[k]; ok {
+		d = d0.intersect(d)
+	}
+	fs.dom[k] = d
+}
+
+// narrow sets the domain of the variable v to d; ok == false: d is empty
+func (fs *fdStore) narrow(v string, d fdDomain) (changed, ok bool) {
+	if len(d) == 0 {
+		return false, false
+	}
+	if d.equal(fs.dom[v]) {
+		return false, true
+	}
+	fs.dom[v] = d
+	return true, true
+}
+
+// propagate narrows the domains up to a fixpoint; false: a domain is empty
+func (fs *fdStore) propagate() bool {
+	for _, d := range fs.dom {
+		if len(d) == 0 {
+			return false
+		}
+	}
+	for changed := true; changed; {
+		changed = false
+		for _, r := range fs.rels {
+			ch, ok := fs.propagateLinear(r)
+			if !ok {
+				return false
+			}
+			changed = changed || ch
+		}
+		for _, ad := range fs.alldiffs {
+			ch, ok := fs.propagateAllDiff(ad)
+			if !ok {
+				return false
+			}
+			changed = changed || ch
+		}
+	}
+	return true
+}
+
+func (fs *fdStore) propagateLinear(r fdLinear) (changed, ok bool) {
+	switch r.rel {
+	case "<=":
+		return fs.propagateLeq(r.vars, r.a, r.c)
+	case "==":
+		ch1, ok := fs.propagateLeq(r.vars, r.a, r.c)
+		if !ok {
+			return false, false
+		}
+		na := make([]*big.Int, len(r.a))
+		for i, a := range r.a {
+			na[i] = new(big.Int).Neg(a)
+		}
+		ch2, ok := fs.propagateLeq(r.vars, na, new(big.Int).Neg(r.c))
+		return ch1 || ch2, ok
+	case "!=":
+		return fs.propagateNeq(r)
+	}
+	return false, true
+}
+
+// propagateLeq narrows the bounds of the variables with sum a[i]*vars[i] + c <= 0:
+// a[i]*vars[i] <= -c - sum of the minima of the other a[j]*vars[j]
+func (fs *fdStore) propagateLeq(vars []string, a []*big.Int, c *big.Int) (changed, ok bool) {
+	mins := make([]*big.Int, len(vars))
+	sum := new(big.Int).Set(c)
+	for i, v := range vars {
+		d := fs.dom[v]
+		b := d.min()
+		if a[i].Sign() < 0 {
+			b = d.max()
+		}
+		mins[i] = new(big.Int).Mul(a[i], big.NewInt(int64(b)))
+		sum.Add(sum, mins[i])
+	}
+	if sum.Sign() > 0 {
+		return false, false
+	}
+	for i, v := range vars {
+		rest := new(big.Int).Sub(mins[i], sum)
+		d := fs.dom[v]
+		// big.Int.Div rounds down for a positive, up for a negative divisor
+		bound := new(big.Int).Div(rest, a[i])
+		var d2 fdDomain
+		if a[i].Sign() > 0 {
+			if bound.Cmp(big.NewInt(int64(d.max()))) >= 0 {
+				continue
+			}
+			d2 = d.intersect(fdDomain{{d.min(), int(bound.Int64())}})
+		} else {
+			if bound.Cmp(big.NewInt(int64(d.min()))) <= 0 {
+				continue
+			}
+			d2 = d.intersect(fdDomain{{int(bound.Int64()), d.max()}})
+		}
+		ch, ok := fs.narrow(v, d2)
+		if !ok {
+			return false, false
+		}
+		changed = changed || ch
+	}
+	return changed, true
+}
+
+// propagateNeq removes the value of the only variable without a value
+func (fs *fdStore) propagateNeq(r fdLinear) (changed, ok bool) {
+	sum := new(big.Int).Set(r.c)
+	free := -1
+	for i, v := range r.vars {
+		if val, ok := fs.dom[v].value(); ok {
+			sum.Add(sum, new(big.Int).Mul(r.a[i], big.NewInt(int64(val))))
+			continue
+		}
+		if free >= 0 {
+			return false, true
+		}
+		free = i
+	}
+	if free < 0 {
+		return false, sum.Sign() != 0
+	}
+	// a*v + sum != 0: v != -sum/a
+	q, m := new(big.Int).QuoRem(new(big.Int).Neg(sum), r.a[free], new(big.Int))
+	if m.Sign() != 0 || !q.IsInt64() {
+		return false, true
+	}
+	v := r.vars[free]
+	return fs.narrow(v, fs.dom[v].remove(int(q.Int64()), int(q.Int64())))
+}
+
+// propagateAllDiff removes the values of the integers and of the variables with
+// a value and the values of the Hall intervals from the other domains: if the
+// domains of n variables are in lo..hi and lo..hi has n values, the values of
+// lo..hi are used by these variables
+func (fs *fdStore) propagateAllDiff(ad fdAllDiff) (changed, ok bool) {
+	used := map[int]bool{}
+	for _, c := range ad.consts {
+		if used[c] {
+			return false, false
+		}
+		used[c] = true
+	}
+	for _, v := range ad.vars {
+		if val, ok := fs.dom[v].value(); ok {
+			if used[val] {
+				return false, false
+			}
+			used[val] = true
+		}
+	}
+	for _, v := range ad.vars {
+		d := fs.dom[v]
+		if _, ok := d.value(); ok {
+			continue
+		}
+		for val := range used {
+			d = d.remove(val, val)
+		}
+		ch, ok := fs.narrow(v, d)
+		if !ok {
+			return false, false
+		}
+		changed = changed || ch
+	}
+	for _, v1 := range ad.vars {
+		for _, v2 := range ad.vars {
+			lo, hi := fs.dom[v1].min(), fs.dom[v2].max()
+			if lo > hi {
+				continue
+			}
+			n := 0
+			for _, v := range ad.vars {
+				if d := fs.dom[v]; d.min() >= lo && d.max() <= hi {
+					n++
+				}
+			}
+			if n > hi-lo+1 {
+				return false, false
+			}
+			if n < hi-lo+1 {
+				continue
+			}
+			for _, v := range ad.vars {
+				d := fs.dom[v]
+				if d.min() >= lo && d.max() <= hi {
+					continue
+				}
+				ch, ok := fs.narrow(v, d.remove(lo, hi))
+				if !ok {
+					return false, false
+				}
+				changed = changed || ch
+			}
+		}
+	}
+	return changed, true
+}
+
+// hasValues - all variables of vars have a value
+func (fs *fdStore) hasValues(vars []string) bool {
+	for _, v := range vars {
+		if _, ok := fs.dom[v].value(); !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// fdPropagate narrows the domains of the finite domain constraints of the
+// built-in store of rs and replaces the in-constraints of the narrowed domains;
+// true: variables got a value, their equations are substituted in the CHR-store,
+// which is solved again. An empty domain sets rs.Result to RFalse.
+func fdPropagate(rs *Session) bool {
+	fs := newFDStore(rs)
+	if !fs.propagate() {
+		rs.Trace.Headln(1, 1, "empty finite domain")
+		rs.Result = RFalse
+		return false
+	}
+	cause := rs.cause
+	defer func() { rs.cause = cause }()
+	// the replaced and solved constraints are deleted in place
+	rs.biVersion++
+	keys := make([]string, 0, len(fs.dom))
+	for k := range fs.dom {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var env Bindings
+	for _, k := range keys {
+		d := fs.dom[k]
+		val, hasValue := d.value()
+		if len(fs.ins[k]) == 0 || (!hasValue && d.equal(fs.dom0[k])) {
+			continue
+		}
+		if rs.Justify {
+			rs.cause = &derivCause{kind: DerivPropagation, premises: fs.ins[k]}
+		}
+		for _, con := range fs.ins[k] {
+			con.IsDeleted = true
+		}
+		v := fs.lin.vars[k]
+		switch {
+		case !hasValue:
+			addConstraintToStore(rs, Compound{Functor: "in", Prio: 3, Args: []Term{v, d.term()}})
+		case !fs.bound[k]:
+			rs.Trace.Headln(1, 1, "finite domain value ", v, " == ", val)
+			addConstraintToStore(rs, Compound{Functor: "==", Prio: 3, Args: []Term{v, Int(val)}})
+			env = AddBinding(v, Int(val), env)
+		}
+	}
+	// alldifferent and label with values for all variables are solved
+	for _, ad := range fs.alldiffs {
+		if !ad.others && fs.hasValues(ad.vars) {
+			ad.con.IsDeleted = true
+		}
+	}
+	for _, l := range fs.labels {
+		if len(fs.unlabeled(l)) == 0 {
+			l.IsDeleted = true
+		}
+	}
+	if env == nil {
+		return false
+	}
+	substituteStores(rs, env)
+	return true
+}
+
+// unlabeled - the variables with domains of the label-constraint l without a value
+func (fs *fdStore) unlabeled(l *Compound) (vars []Variable) {
+	for _, t := range l.Args[0].(List) {
+		v, ok := t.(Variable)
+		if !ok {
+			continue
+		}
+		if d, ok := fs.dom[v.Key()]; ok {
+			if _, ok := d.value(); !ok {
+				vars = append(vars, v)
+			}
+		}
+	}
+	return
+}
+
+// fdLabel splits the domain of the first variable without a value of the first
+// label-constraint: the disjunction (X == 1 ; X == 2 ; ...) of the values of the
+// domain; false, if all variables of the label-constraints have values
+func fdLabel(rs *Session) bool {
+	fs := newFDStore(rs)
+	for _, l := range fs.labels {
+		vars := fs.unlabeled(l)
+		if len(vars) == 0 {
+			continue
+		}
+		v := vars[0]
+		alts := List{}
+		for _, val := range fs.dom[v.Key()].values() {
+			alts = append(alts, List{Compound{Functor: "==", Prio: 3, Args: []Term{v, Int(val)}}})
+		}
+		rs.Trace.Headln(1, 1, "label ", v, " in ", fs.dom[v.Key()].term())
+		addDisjunction(rs, Compound{Functor: ";", Prio: 1, Args: alts})
+		return splitDisjunction(rs)
+	}
+	return false
+}
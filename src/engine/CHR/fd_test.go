@@ -0,0 +1,163 @@
+// Copyright © 2016 The Carneades Authors
+// This Source Code Form is subject to the terms of the
+// Mozilla Public License, v. 2.0. If a copy of the MPL
+// was not distributed with this file, You can obtain one
+// at http://mozilla.org/MPL/2.0/.
+
+package chr
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	. "github.com/hfried/GoCHR/src/engine/parser"
+)
+
+// tDomain - the domain of the range or list src
+func tDomain(t *testing.T, src string) fdDomain {
+	t1, ok := ReadString(src)
+	if !ok {
+		t.Fatalf("parse error: %s", src)
+	}
+	d, ok := parseFDDomain(Eval(t1))
+	if !ok {
+		t.Fatalf("no domain: %s", src)
+	}
+	return d
+}
+
+func TestFD01(t *testing.T) {
+	// the operations of the domains
+	for _, c := range []struct {
+		op, d1, d2, want string
+	}{
+		{"term", "[3..5, 1, 2, 9]", "", "[1..5, 9]"},
+		{"term", "[4..6, 1..5]", "", "1..6"},
+		{"term", "[7]", "", "[7]"},
+		{"intersect", "1..9", "[0..2, 5, 7..12]", "[1..2, 5, 7..9]"},
+		{"intersect", "1..3", "4..6", "[]"},
+		{"remove", "1..9", "3..4", "[1..2, 5..9]"},
+		{"remove", "[1..2, 5..9]", "1..5", "6..9"},
+	} {
+		d := tDomain(t, c.d1)
+		switch c.op {
+		case "intersect":
+			d = d.intersect(tDomain(t, c.d2))
+		case "remove":
+			d2 := tDomain(t, c.d2)
+			d = d.remove(d2.min(), d2.max())
+		}
+		if got := d.term().String(); got != c.want {
+			t.Errorf("TestFD01 fails: %s %s %s = %s, should be %s", c.op, c.d1, c.d2, got, c.want)
+		}
+	}
+	d := tDomain(t, "[1..3, 7]")
+	if _, ok := d.value(); ok || !d.contains(7) || d.contains(5) || d.min() != 1 || d.max() != 7 ||
+		len(d.values()) != 4 {
+		t.Errorf("TestFD01 fails: %s", d.term())
+	}
+	for _, c := range [][2]string{
+		{"3 in 1..5", "true"},
+		{"6 in [1..5, 7]", "false"},
+		{"X in 1..5", "X in 1..5"},
+	} {
+		if !teval(t, c[0], c[1]) {
+			t.Errorf("TestFD01 failed: %s\n", c[0])
+		}
+	}
+}
+
+func TestFD02(t *testing.T) {
+	// the propagation of the linear constraints and of alldifferent
+	for _, mode := range []SolverMode{ModeDefault, ModeRefined} {
+		rs := MakeRuleStore()
+		rs.Mode = mode
+		ok := rs.ParseStringCHRRulesGoals(`
+		r1 @ bounds(X, Y) <=> X in 1..5, Y in 2..7, X > Y, dom(X, Y).
+		r2 @ values(X, Y) <=> X in 1..9, Y in 1..9, X + Y == 10, X - Y == 4, val(X, Y).
+		r3 @ neq(X, Y) <=> X in 1..3, Y in 1..2, Y != 2, X != Y + 1, X < 3, val(X, Y).
+		r4 @ hall(X, Y, Z) <=> [X, Y] in 1..2, Z in 1..3, alldifferent([X, Y, Z]), val(Z).
+		r5 @ empty(X) <=> X in 1..3, X in 5..9 .
+		r6 @ alldiff(X, Y, Z) <=> [X, Y, Z] in 1..2, alldifferent([X, Y, Z]).
+		r7 @ small(X) <=> X in [1..3, 5] | val(X).
+		bounds(A, B).
+		#store: dom(A, B).
+		#bistore: B < A, A in 3..5, B in 2..4 .
+		values(A, B).
+		#result: val(7, 3).
+		neq(A, B).
+		#result: val(1, 1).
+		hall(A, B, C).
+		#result: val(3).
+		empty(A).
+		#result: false.
+		alldiff(A, B, C).
+		#result: false.
+		small(5), small(4).
+		#result: val(5), small(4).
+		`)
+		if !ok {
+			t.Errorf("TestFD02 fails, mode: %s", mode)
+		}
+	}
+}
+
+func TestFD03(t *testing.T) {
+	// labeling with backtracking, a CHR-constraint hides label
+	for _, mode := range []SolverMode{ModeDefault, ModeRefined} {
+		rs := MakeRuleStore()
+		rs.Mode = mode
+		ok := rs.ParseStringCHRRulesGoals(`
+		check @ pick(X) <=> X in [2, 4..5] | picked(X).
+		reject @ pick(X) <=> X < 4 | false.
+		go @ go(X) <=> pick(X), X in 1..5, label([X]).
+		send @ puzzle([S, E, N, D, M, O, R, Y]) <=>
+			[S, E, N, D, M, O, R, Y] in 0..9, S != 0, M != 0,
+			alldifferent([S, E, N, D, M, O, R, Y]),
+			1000*S + 100*E + 10*N + D + 1000*M + 100*O + 10*R + E ==
+			10000*M + 1000*O + 100*N + 10*E + Y,
+			label([S, E, N, D, M, O, R, Y]),
+			money([M, O, N, E, Y]).
+		go(A).
+		#result: picked(2).
+		puzzle([S, E, N, D, M, O, R, Y]).
+		#result: money([1, 0, 6, 5, 2]).
+		`)
+		if !ok {
+			t.Errorf("TestFD03 fails, mode: %s", mode)
+		}
+		ok = rs.ParseStringCHRRulesGoals(`
+		l1 @ label(X) <=> labeled(X).
+		l2 @ go(X) <=> X in 1..3, label([X]).
+		go(A).
+		#result: labeled([A]).
+		#bistore: A in 1..3 .
+		`)
+		if !ok {
+			t.Errorf("TestFD03 fails, hidden label, mode: %s", mode)
+		}
+	}
+}
+
+func TestFD04(t *testing.T) {
+	// the finite domain constraints of the query, all solutions, the derivation of a domain
+	rs := MakeRuleStore()
+	rs.Justify = true
+	rs.Order = OrderTerm
+	g, _ := ParseGoalString("X in 1..3, Y in 1..3, X < Y, label([X, Y])")
+	sols := solutions(rs.Solutions(context.Background(), SolutionOptions{}, g))
+	if strings.Join(sols, "; ") != "[] [X==1, Y==2]; [] [X==1, Y==3]; [] [X==2, Y==3]" {
+		t.Errorf("TestFD04 fails, solutions: %v", sols)
+	}
+	g, _ = ParseGoalString("X in 1..5, X > 3")
+	res, err := rs.Solve(context.Background(), g)
+	if err != nil || res.BuiltInStore.String() != "[X>3, X in 4..5]" {
+		t.Fatalf("TestFD04 fails, store: %s, err: %v", res.BuiltInStore, err)
+	}
+	d := rs.Explain(findConstraint(res.BuiltInStore, "X in 4..5"))
+	if d == nil || d.Kind != DerivPropagation || len(d.Premises) != 1 ||
+		d.Premises[0].Constraint.String() != "X in 1..5" {
+		t.Errorf("TestFD04 fails, derivation: %v", d)
+	}
+}
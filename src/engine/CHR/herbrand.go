@@ -0,0 +1,278 @@
+// Copyright © 2016 The Carneades Authors
+// This Source Code Form is subject to the terms of the
+// Mozilla Public License, v. 2.0. If a copy of the MPL
+// was not distributed with this file, You can obtain one
+// at http://mozilla.org/MPL/2.0/.
+
+// Herbrand - the equation solver of the variables of the CHR-store: the bindings
+// of the variables as union-find and the index of the occurrences of the variables
+// in the CHR-constraints; a binding wakes only the constraints with the variable
+
+package chr
+
+import (
+	"sort"
+
+	. "github.com/hfried/GoCHR/src/engine/terms"
+)
+
+// herbrand - the bindings and the occurrences of the variables of the CHR-store
+type herbrand struct {
+	bound map[string]Term  // variable key -> the bound term, a variable or a non-variable term
+	occ   map[string]CList // variable key -> the CHR-constraints with the variable
+}
+
+func newHerbrand() *herbrand {
+	return &herbrand{bound: map[string]Term{}, occ: map[string]CList{}}
+}
+
+// copy - the bindings of h without the occurrences, for a choice point
+func (h *herbrand) copy() *herbrand {
+	h1 := newHerbrand()
+	for k, t := range h.bound {
+		h1.bound[k] = t
+	}
+	return h1
+}
+
+// deref - the term bound to the variable t or the unbound variable of its class;
+// the variables on the path are bound to the result (path compression)
+func (h *herbrand) deref(t Term) Term {
+	v, ok := t.(Variable)
+	if !ok {
+		return t
+	}
+	path := []string{}
+	for {
+		t1, ok := h.bound[v.Key()]
+		if !ok {
+			break
+		}
+		path = append(path, v.Key())
+		t = t1
+		if v, ok = t1.(Variable); !ok {
+			break
+		}
+	}
+	if len(path) > 1 {
+		for _, k := range path[:len(path)-1] {
+			h.bound[k] = t
+		}
+	}
+	return t
+}
+
+// resolve - the term t with the bound variables replaced by their terms;
+// false, if t has no bound variable
+func (h *herbrand) resolve(t Term) (Term, bool) {
+	switch t := t.(type) {
+	case Variable:
+		if _, ok := h.bound[t.Key()]; !ok {
+			return t, false
+		}
+		t1, _ := h.resolve(h.deref(t))
+		return t1, true
+	case Compound:
+		var args []Term
+		for i, a := range t.Args {
+			a1, ok := h.resolve(a)
+			if ok && args == nil {
+				args = append([]Term{}, t.Args[:i]...)
+			}
+			if args != nil {
+				args = append(args, a1)
+			}
+		}
+		if args == nil {
+			return t, false
+		}
+		return Compound{Functor: t.Functor, Id: t.Id, Prio: t.Prio, Args: args}, true
+	case List:
+		var l List
+		for i, a := range t {
+			a1, ok := h.resolve(a)
+			if ok && l == nil {
+				l = append(List{}, t[:i]...)
+			}
+			if l != nil {
+				l = append(l, a1)
+			}
+		}
+		if l == nil {
+			return t, false
+		}
+		return l, true
+	}
+	return t, false
+}
+
+// bindings - the bound variables of the term t with their terms
+func (h *herbrand) bindings(t Term) (env Bindings) {
+	seen := map[string]bool{}
+	for _, v := range t.OccurVars() {
+		if _, ok := h.bound[v.Key()]; ok && !seen[v.Key()] {
+			seen[v.Key()] = true
+			t1, _ := h.resolve(v)
+			env = AddBinding(v, t1, env)
+		}
+	}
+	return
+}
+
+// occurs - the variable v occurs in the term t
+func occurs(v Variable, t Term) bool {
+	for _, v1 := range t.OccurVars() {
+		if v1.Key() == v.Key() {
+			return true
+		}
+	}
+	return false
+}
+
+// unify - the bindings of the unifier of the terms t1 and t2 added to env; only
+// the constructor terms (predicates and lists) are decomposed, not the operators
+// like X + 1. Of two variables the variable of the query or the variable with more
+// occurrences in the CHR-store represents the class, otherwise the second one.
+func (h *herbrand) unify(t1, t2 Term, env Bindings) (Bindings, bool) {
+	t1, t2 = walk(t1, env), walk(t2, env)
+	v1, ok1 := t1.(Variable)
+	v2, ok2 := t2.(Variable)
+	if ok1 && ok2 {
+		if v1.Key() == v2.Key() {
+			return env, true
+		}
+		if q1, q2 := IsNewVariable(v1), IsNewVariable(v2); q1 && !q2 ||
+			q1 == q2 && len(h.occ[v1.Key()]) > len(h.occ[v2.Key()]) {
+			return AddBinding(v2, v1, env), true
+		}
+		return AddBinding(v1, v2, env), true
+	}
+	if ok1 {
+		if occurs(v1, Substitute(t2, env)) {
+			return env, false
+		}
+		return AddBinding(v1, t2, env), true
+	}
+	if ok2 {
+		if occurs(v2, Substitute(t1, env)) {
+			return env, false
+		}
+		return AddBinding(v2, t1, env), true
+	}
+	switch t1 := t1.(type) {
+	case Compound:
+		c2, ok := t2.(Compound)
+		if ok && t1.Prio == 0 && c2.Prio == 0 && t1.Functor == c2.Functor && len(t1.Args) == len(c2.Args) {
+			return h.unifyArgs(t1.Args, c2.Args, env)
+		}
+	case List:
+		if l2, ok := t2.(List); ok && len(t1) == len(l2) {
+			return h.unifyArgs(t1, l2, env)
+		}
+	}
+	return env, Equal(t1, t2)
+}
+
+func (h *herbrand) unifyArgs(args1, args2 []Term, env Bindings) (Bindings, bool) {
+	ok := true
+	for i := range args1 {
+		if env, ok = h.unify(args1[i], args2[i], env); !ok {
+			return env, false
+		}
+	}
+	return env, true
+}
+
+// walk - the term bound to the variable t in env
+func walk(t Term, env Bindings) Term {
+	for {
+		v, ok := t.(Variable)
+		if !ok {
+			return t
+		}
+		t1, ok := GetBinding(v, env)
+		if !ok {
+			return t
+		}
+		t = t1
+	}
+}
+
+// bind adds the bindings of biEnv, the variables are unified with their bound terms;
+// the keys of the newly bound variables. Terms, which do not unify, e.g. 1 and X + 1,
+// bind no variable, the equations of the built-in store are checked in reduceStore.
+func (h *herbrand) bind(biEnv Bindings) (keys []string) {
+	// the first binding of a variable in biEnv first
+	bl := []*BindEle{}
+	for b := biEnv; b != nil; b = b.Next {
+		if b.T != nil {
+			bl = append(bl, b)
+		}
+	}
+	for i := len(bl) - 1; i >= 0; i-- {
+		t1, _ := h.resolve(bl[i].Var)
+		t2, _ := h.resolve(bl[i].T)
+		env, ok := h.unify(t1, t2, nil)
+		if !ok {
+			continue
+		}
+		for b := env; b != nil; b = b.Next {
+			keys = append(keys, b.Var.Key())
+		}
+		for b := env; b != nil; b = b.Next {
+			h.bound[b.Var.Key()] = b.T
+		}
+	}
+	return
+}
+
+// index adds the CHR-constraint c to the occurrences of its variables; the bound
+// variables of c are replaced by their terms before
+func (h *herbrand) index(c *Compound) {
+	if len(h.bound) != 0 {
+		if c1, ok := h.resolve(*c); ok {
+			c.Args = c1.(Compound).Args
+		}
+	}
+	for _, v := range c.OccurVars() {
+		k := v.Key()
+		cl := h.occ[k]
+		if n := len(cl); n >= 8 && n&(n-1) == 0 {
+			cl = compactCList(cl)
+		}
+		if n := len(cl); n > 0 && cl[n-1] == c {
+			// a variable with more than one occurrence in c
+			continue
+		}
+		h.occ[k] = append(cl, c)
+	}
+}
+
+// compactCList - the constraints of cl, which are not deleted
+func compactCList(cl CList) CList {
+	cl1 := cl[:0]
+	for _, c := range cl {
+		if !c.IsDeleted {
+			cl1 = append(cl1, c)
+		}
+	}
+	return cl1
+}
+
+// wake - the CHR-constraints with the variables keys in the order of their Id's;
+// the occurrences of the variables are removed
+func (h *herbrand) wake(keys []string) CList {
+	cl := CList{}
+	seen := map[*Compound]bool{}
+	for _, k := range keys {
+		for _, c := range h.occ[k] {
+			if !c.IsDeleted && !seen[c] {
+				seen[c] = true
+				cl = append(cl, c)
+			}
+		}
+		delete(h.occ, k)
+	}
+	sort.SliceStable(cl, func(i, j int) bool { return lessId(cl[i], cl[j]) })
+	return cl
+}
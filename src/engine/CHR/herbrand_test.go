@@ -0,0 +1,106 @@
+// Copyright © 2016 The Carneades Authors
+// This Source Code Form is subject to the terms of the
+// Mozilla Public License, v. 2.0. If a copy of the MPL
+// was not distributed with this file, You can obtain one
+// at http://mozilla.org/MPL/2.0/.
+
+package chr
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	. "github.com/hfried/GoCHR/src/engine/parser"
+	. "github.com/hfried/GoCHR/src/engine/terms"
+)
+
+// tBind - the bindings of the equations of the list src
+func tBind(t *testing.T, h *herbrand, src string) []string {
+	l, ok := ReadString(src)
+	if !ok {
+		t.Fatalf("parse error: %s", src)
+	}
+	var env Bindings
+	for _, eq := range l.(List) {
+		eq := eq.(Compound)
+		env = AddBinding(eq.Args[0].(Variable), eq.Args[1], env)
+	}
+	return h.bind(env)
+}
+
+func TestHerbrand01(t *testing.T) {
+	// unification of the bound terms, the occurs check, no decomposition of operators
+	for _, c := range []struct {
+		eqs, term, want string
+	}{
+		{"[X == Y, Y == Z]", "p(X, Y, Z)", "p(Z, Z, Z)"},
+		{"[X == f(Y), X == f(3)]", "p(X, Y)", "p(f(3), 3)"},
+		{"[X == [A, B], X == [1, Y], Y == 2]", "p(A, B)", "p(1, 2)"},
+		{"[X == f(X)]", "p(X)", "p(X)"},
+		{"[X == Y + 1, X == 5]", "p(X, Y)", "p(Y + 1, Y)"},
+		{"[X == f(1), X == f(2)]", "p(X)", "p(f(1))"},
+	} {
+		h := newHerbrand()
+		tBind(t, h, c.eqs)
+		term, _ := ReadString(c.term)
+		want, _ := ReadString(c.want)
+		if got, _ := h.resolve(term); !Equal(got, want) {
+			t.Errorf("TestHerbrand01 fails: %s, %s = %s, should be %s", c.eqs, c.term, got, c.want)
+		}
+	}
+}
+
+func TestHerbrand02(t *testing.T) {
+	// the occurrences: a binding wakes only the constraints with the variable,
+	// the variable with more occurrences represents the class
+	rs := MakeRuleStore()
+	h := rs.herbrand
+	for _, src := range []string{"p(X)", "q(X, Y)", "r(Y)", "s(Z)", "t(U)"} {
+		c, _ := ReadString(src)
+		c1 := c.(Compound)
+		addRefConstraintToStore(&rs.Session, &c1)
+	}
+	woken := h.wake(tBind(t, h, "[Y == X]"))
+	if woken.String() != "[q(X,Y), r(Y)]" {
+		t.Errorf("TestHerbrand02 fails, woken: %s", woken)
+	}
+	woken = h.wake(tBind(t, h, "[U == 1]"))
+	if woken.String() != "[t(U)]" {
+		t.Errorf("TestHerbrand02 fails, woken: %s", woken)
+	}
+	if b := h.bindings(Compound{Functor: "f", Args: []Term{NewVariable("Y"), NewVariable("Z")}}); b == nil ||
+		b.Next != nil || b.Var.Name != "Y" || !Equal(b.T, NewVariable("X")) {
+		t.Errorf("TestHerbrand02 fails, bindings: %v", b)
+	}
+}
+
+func TestHerbrand03(t *testing.T) {
+	// the bindings of the equations of the bodies, in the constraints of the
+	// CHR-store and the disjunctions, restored on backtracking
+	for _, mode := range []SolverMode{ModeDefault, ModeRefined} {
+		rs := MakeRuleStore()
+		rs.Mode = mode
+		ok := rs.ParseStringCHRRulesGoals(`
+		r1 @ go1(X, Y) <=> X == f(Y), p(Y), X == f(3).
+		r2 @ p(3) <=> three.
+		r3 @ go2(X, Y) <=> q(X), X == Y, Y == a.
+		r4 @ go3 <=> (X == 1, p(X) ; X == 3, p(X)), fail(X).
+		r5 @ fail(1) <=> false.
+		go1(A, B).
+		#result: three.
+		go2(A, B).
+		#result: q(a).
+		go3.
+		#result: three, fail(3).
+		`)
+		if !ok {
+			t.Errorf("TestHerbrand03 fails, mode: %s", mode)
+		}
+		g, _ := ParseGoalString("go1(A, B), go2(C, D)")
+		sols := solutions(rs.Solutions(context.Background(), SolutionOptions{}, g))
+		if strings.Join(sols, "; ") == "" {
+			t.Errorf("TestHerbrand03 fails, mode: %s, no solution", mode)
+		}
+	}
+}
@@ -0,0 +1,67 @@
+// Copyright © 2016 The Carneades Authors
+// This Source Code Form is subject to the terms of the
+// Mozilla Public License, v. 2.0. If a copy of the MPL
+// was not distributed with this file, You can obtain one
+// at http://mozilla.org/MPL/2.0/.
+
+// Propagation history
+
+package chr
+
+import (
+	"strings"
+
+	. "github.com/hfried/GoCHR/src/engine/terms"
+)
+
+// history of a propagation rule: the keys of the tuples of constraint Id's,
+// for which the rule fired
+type history map[string]bool
+
+// an entry of the propagation history of the rule 'rule'
+type hisRef struct {
+	rule *chrRule
+	key  string
+}
+
+// historyKey - the key of the tuple of the Id's of the constraints 'partners'
+func historyKey(partners CList) string {
+	key := make([]string, len(partners))
+	for i, p := range partners {
+		key[i] = p.Id.String()
+	}
+	return strings.Join(key, ",")
+}
+
+// inHistory - the rule r fired with the constraints 'partners'
+func inHistory(rs *Session, r *chrRule, partners CList) bool {
+	return rs.state(r).his[historyKey(partners)]
+}
+
+// addHistory adds the tuple of the constraints 'partners' to the
+// propagation history of the rule r
+func addHistory(rs *Session, r *chrRule, partners CList) {
+	st := rs.state(r)
+	if st.his == nil {
+		st.his = history{}
+	}
+	key := historyKey(partners)
+	st.his[key] = true
+	for _, p := range partners {
+		id := p.Id.String()
+		rs.hisIndex[id] = append(rs.hisIndex[id], hisRef{rule: r, key: key})
+	}
+}
+
+// gcHistory removes the entries of the deleted constraint c
+// from the propagation histories
+func gcHistory(rs *Session, c *Compound) {
+	if c.Id == nil {
+		return
+	}
+	id := c.Id.String()
+	for _, ref := range rs.hisIndex[id] {
+		delete(rs.state(ref.rule).his, ref.key)
+	}
+	delete(rs.hisIndex, id)
+}
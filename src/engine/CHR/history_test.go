@@ -0,0 +1,71 @@
+// Copyright © 2016 The Carneades Authors
+// This Source Code Form is subject to the terms of the
+// Mozilla Public License, v. 2.0. If a copy of the MPL
+// was not distributed with this file, You can obtain one
+// at http://mozilla.org/MPL/2.0/.
+
+package chr
+
+import (
+	"testing"
+)
+
+func TestHistory01(t *testing.T) {
+	// leq_transitivity fires once for every combination, whatever the store order
+	rules := `
+	leq_reflexivity  @ leq(X,X) <=> true.
+	leq_antisymmetry @ leq(X,Y), leq(Y,X) <=> X==Y.
+	leq_idempotence  @ leq(X,Y) \ leq(X,Y) <=> true.
+	leq_transitivity @ leq(X,Y), leq(Y,Z) ==> leq(X,Z).
+	`
+	result := "#result: leq(A,B), leq(B,C), leq(C,D), leq(A,C), leq(B,D), leq(A,D).\n"
+	for _, goals := range []string{
+		"leq(A,B), leq(B,C), leq(C,D).\n",
+		"leq(C,D), leq(B,C), leq(A,B).\n",
+		"leq(B,C), leq(A,B), leq(C,D).\n",
+		"leq(B,C), leq(C,D), leq(A,B).\n",
+	} {
+		for _, mode := range []SolverMode{ModeDefault, ModeRefined} {
+			rs := MakeRuleStore()
+			rs.Mode = mode
+			if !rs.ParseStringCHRRulesGoals(rules + goals + result) {
+				t.Errorf("TestHistory01 fails, goals: %s, mode: %s", goals, mode)
+			}
+		}
+	}
+}
+
+func TestHistory02(t *testing.T) {
+	// propagation rule with two kept heads of the same constraint
+	for _, mode := range []SolverMode{ModeDefault, ModeRefined} {
+		rs := MakeRuleStore()
+		rs.Mode = mode
+		ok := rs.ParseStringCHRRulesGoals(`
+		pair @ p(X), p(Y) ==> X < Y | pair(X,Y).
+		p(1), p(2), p(3).
+		#result: p(1), p(2), p(3), pair(1,2), pair(1,3), pair(2,3).
+		`)
+		if !ok || rs.ruleFirings != 3 {
+			t.Errorf("TestHistory02 fails, mode: %s, rule firings: %d", mode, rs.ruleFirings)
+		}
+	}
+}
+
+func TestHistory03(t *testing.T) {
+	// the entries of a deleted constraint are removed from the history
+	for _, mode := range []SolverMode{ModeDefault, ModeRefined} {
+		rs := MakeRuleStore()
+		rs.Mode = mode
+		ok := rs.ParseStringCHRRulesGoals(`
+		p1 @ p(X) ==> q(X).
+		p2 @ q(X) ==> r(X).
+		del @ p(X), stop <=> true.
+		p(1), stop.
+		#result: q(1), r(1).
+		`)
+		p1, p2 := rs.state(rs.prog.rules[0]), rs.state(rs.prog.rules[1])
+		if !ok || len(p1.his) != 0 || len(p2.his) != 1 || len(rs.hisIndex) != 1 {
+			t.Errorf("TestHistory03 fails, mode: %s, history p1: %v, p2: %v", mode, p1.his, p2.his)
+		}
+	}
+}
@@ -0,0 +1,189 @@
+// Copyright © 2016 The Carneades Authors
+// This Source Code Form is subject to the terms of the
+// Mozilla Public License, v. 2.0. If a copy of the MPL
+// was not distributed with this file, You can obtain one
+// at http://mozilla.org/MPL/2.0/.
+
+// Hash indexes on argument positions of the CHR-store
+
+package chr
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	. "github.com/hfried/GoCHR/src/engine/terms"
+)
+
+// hash index on the argument positions 'pos' of the constraints of a functor;
+// the key is build from the arguments at the positions 'pos'
+type argIndex struct {
+	pos   []int
+	table map[string]CList
+}
+
+// argKey - the key of the argument t; equal terms have the same key
+func argKey(t Term) string {
+	return fmt.Sprintf("%d:%s", t.Type(), t.String())
+}
+
+func posKey(pos []int) string {
+	s := make([]string, len(pos))
+	for i, p := range pos {
+		s[i] = fmt.Sprintf("%d", p)
+	}
+	return strings.Join(s, ",")
+}
+
+func (ix *argIndex) add(g *Compound) {
+	if ix.pos[len(ix.pos)-1] >= len(g.Args) {
+		return
+	}
+	key := make([]string, len(ix.pos))
+	for i, p := range ix.pos {
+		key[i] = argKey(g.Args[p])
+	}
+	k := strings.Join(key, "|")
+	ix.table[k] = append(ix.table[k], g)
+}
+
+// headIndexPos - the argument positions of the head h, which are known
+// when h is matched: constants and variables of the other heads 'vars'
+func headIndexPos(h *Compound, vars map[string]bool) []int {
+	pos := []int{}
+	for i, arg := range h.Args {
+		if arg.Type() == VariableType {
+			if vars[arg.(Variable).Name] {
+				pos = append(pos, i)
+			}
+		} else if isGround(arg) {
+			pos = append(pos, i)
+		}
+	}
+	return pos
+}
+
+// isGround - the term t has no variables, e.g. a constant or the atom 'a' (parsed as a())
+func isGround(t Term) bool {
+	switch t.Type() {
+	case AtomType, BoolType, IntType, FloatType, StringType, BigIntType, RationalType:
+		return true
+	case CompoundType, ListType:
+		return len(t.OccurVars()) == 0
+	}
+	return false
+}
+
+// addRuleIndexes analyses the heads of the rule r for join keys and
+// adds hash indexes for the positions of the join keys to the CHR-store
+func addRuleIndexes(rs *RuleStore, r *chrRule) {
+	heads := ruleHeads(r)
+	for i, h := range heads {
+		if h.Functor == "" {
+			continue
+		}
+		// the variables of the other heads
+		vars := map[string]bool{}
+		for j, h2 := range heads {
+			if j != i {
+				for _, v := range h2.OccurVars() {
+					vars[v.Name] = true
+				}
+			}
+		}
+		pos := headIndexPos(h, vars)
+		if len(pos) == 0 {
+			continue
+		}
+		addIndex(rs, h.Functor, pos)
+		if len(pos) > 1 {
+			for _, p := range pos {
+				addIndex(rs, h.Functor, []int{p})
+			}
+		}
+	}
+}
+
+// addIndex adds a hash index on the positions 'pos' for the constraints
+// with the functor 'functor', if it does not exists
+func addIndex(rs *RuleStore, functor string, pos []int) {
+	key := posKey(pos)
+	for _, p := range rs.prog.indexSpecs[functor] {
+		if posKey(p) == key {
+			return
+		}
+	}
+	rs.prog.indexSpecs[functor] = append(rs.prog.indexSpecs[functor], pos)
+	aArg, ok := rs.CHRstore[functor]
+	if !ok {
+		return
+	}
+	// the tried candidates refer to the positions in the read constraint lists
+	for i := range rs.states {
+		rs.states[i].tried = nil
+	}
+	// index the constraints in the store
+	ix := &argIndex{pos: pos, table: map[string]CList{}}
+	for _, g := range aArg.varArg {
+		if g != nil && !g.IsDeleted {
+			ix.add(g)
+		}
+	}
+	aArg.idx = append(aArg.idx, ix)
+	sortIndexes(aArg)
+}
+
+// sortIndexes sorts the hash indexes, the index with most positions first
+func sortIndexes(aArg *argCHR) {
+	sort.SliceStable(aArg.idx, func(i, j int) bool { return len(aArg.idx[i].pos) > len(aArg.idx[j].pos) })
+}
+
+// newIndexedArgCHR - the store of the functor with the hash indexes of the rules
+func newIndexedArgCHR(rs *Session, functor string) *argCHR {
+	aArg := NewArgCHR()
+	for _, pos := range rs.prog.indexSpecs[functor] {
+		aArg.idx = append(aArg.idx, &argIndex{pos: pos, table: map[string]CList{}})
+	}
+	sortIndexes(aArg)
+	return aArg
+}
+
+// readIndexedConstraints - the constraints of the hash index with the most positions,
+// which are known for the head t in the environment env
+func readIndexedConstraints(t *Compound, aAtt *argCHR, env Bindings) (CList, bool) {
+	if len(aAtt.idx) == 0 {
+		return nil, false
+	}
+	keys := map[int]string{}
+	for i, arg := range t.Args {
+		if arg.Type() == VariableType {
+			// same as in Match: a bound variable is compared with Equal
+			if b, ok := GetBinding(arg.(Variable), env); ok && b != nil {
+				keys[i] = argKey(b)
+			}
+		} else if isGround(arg) {
+			keys[i] = argKey(arg)
+		}
+	}
+	if len(keys) == 0 {
+		return nil, false
+	}
+next:
+	for _, ix := range aAtt.idx {
+		key := make([]string, len(ix.pos))
+		for i, p := range ix.pos {
+			k, ok := keys[p]
+			if !ok {
+				continue next
+			}
+			key[i] = k
+		}
+		cl, ok := ix.table[strings.Join(key, "|")]
+		if !ok {
+			return CList{}, true
+		}
+		return cl, true
+	}
+	return nil, false
+}
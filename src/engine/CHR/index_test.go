@@ -0,0 +1,67 @@
+// Copyright © 2016 The Carneades Authors
+// This Source Code Form is subject to the terms of the
+// Mozilla Public License, v. 2.0. If a copy of the MPL
+// was not distributed with this file, You can obtain one
+// at http://mozilla.org/MPL/2.0/.
+
+package chr
+
+import (
+	"testing"
+)
+
+func TestIndex01(t *testing.T) {
+	// join on a shared variable and on a constant
+	for _, mode := range []SolverMode{ModeDefault, ModeRefined} {
+		rs := MakeRuleStore()
+		rs.Mode = mode
+		ok := rs.ParseStringCHRRulesGoals(`
+		path @ edge(X, Y), edge(Y, Z) ==> path(X, Z).
+		from_a @ edge(a, Y), path(Y, Z) ==> reach(Z).
+		edge(a, b), edge(b, c), edge(c, d), edge(d, b).
+		#result: edge(a, b), edge(b, c), edge(c, d), edge(d, b),
+		  path(a, c), path(b, d), path(c, b), path(d, c), reach(d).
+		`)
+		if !ok {
+			t.Errorf("TestIndex01 fails, mode: %s", mode)
+		}
+		aArg := rs.CHRstore["edge"]
+		if aArg == nil || len(aArg.idx) == 0 || len(aArg.idx[0].table) == 0 {
+			t.Errorf("TestIndex01 fails, mode: %s, no index for edge", mode)
+		}
+	}
+}
+
+func TestIndex02(t *testing.T) {
+	// join on two argument positions
+	rs := MakeRuleStore()
+	ok := rs.ParseStringCHRRulesGoals(`
+	dup @ e(X, Y, A) | e(X, Y, B) <=> A < B | removed(B).
+	e(1, 2, 3), e(1, 2, 1), e(2, 1, 0), e(1, 3, 5), e(1, 2, 2).
+	#result: e(1, 2, 1), e(2, 1, 0), e(1, 3, 5), removed(3), removed(2).
+	`)
+	if !ok {
+		t.Error("TestIndex02 fails")
+	}
+	aArg := rs.CHRstore["e"]
+	if aArg == nil || len(aArg.idx) != 3 || len(aArg.idx[0].pos) != 2 {
+		t.Error("TestIndex02 fails, missing index on (X, Y)")
+	}
+}
+
+func TestIndex03(t *testing.T) {
+	// an index of a new rule contains the constraints of the CHR-store
+	rs := MakeRuleStore()
+	_, ok := rs.AddStringCHRRulesGoals("p(1, a), p(2, b), q(2).\n")
+	if !ok {
+		t.Error("TestIndex03 fails, goals")
+	}
+	_, ok = rs.AddStringCHRRulesGoals("pq @ q(X), p(X, Y) ==> r(Y).\n")
+	if !ok {
+		t.Error("TestIndex03 fails, rule")
+	}
+	_, ok = rs.AddStringCHRRulesGoals("q(1).\n#result: p(1, a), p(2, b), q(2), q(1), r(b), r(a).\n")
+	if !ok {
+		t.Error("TestIndex03 fails, result")
+	}
+}
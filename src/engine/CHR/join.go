@@ -0,0 +1,124 @@
+// Copyright © 2016 The Carneades Authors
+// This Source Code Form is subject to the terms of the
+// Mozilla Public License, v. 2.0. If a copy of the MPL
+// was not distributed with this file, You can obtain one
+// at http://mozilla.org/MPL/2.0/.
+
+// Join order of the heads of a rule
+
+package chr
+
+import (
+	. "github.com/hfried/GoCHR/src/engine/terms"
+)
+
+// a step of the join order: match the head 'head' (index in ruleHeads)
+// and check the guards 'guards', which variables are bound now
+type joinStep struct {
+	head   int
+	guards CList
+}
+
+// compileRule adds the hash indexes of the rule r to the CHR-store
+// and computes the join orders of the heads
+func compileRule(rs *RuleStore, r *chrRule) {
+	addRuleIndexes(rs, r)
+	compileJoin(r)
+}
+
+// compileJoin computes the join orders of the rule r: r.join for the
+// rule tried without an active constraint and r.occJoin[i] for
+// the head i (index in ruleHeads) matched with the active constraint
+func compileJoin(r *chrRule) {
+	n := len(r.delHead) + len(r.keepHead)
+	r.join = joinOrder(r, -1)
+	r.occJoin = make([][]joinStep, n)
+	for i := 0; i < n; i++ {
+		r.occJoin[i] = joinOrder(r, i)
+	}
+}
+
+// joinOrder - the join order of the heads of the rule r, beginning with the head 'first'
+// (first < 0: the best head). The next head is the head with the most known argument
+// positions (constants and bound variables, see the hash indexes), then the head,
+// which enables the most guards. The guards are checked in textual order.
+func joinOrder(r *chrRule, first int) []joinStep {
+	heads := ruleHeads(r)
+	headVars := map[string]bool{}
+	for _, h := range heads {
+		for _, v := range h.OccurVars() {
+			headVars[v.Name] = true
+		}
+	}
+	placed := make([]bool, len(heads))
+	bound := map[string]bool{}
+	nextGuard := 0
+	order := []joinStep{}
+	for len(order) < len(heads) {
+		h := first
+		if len(order) > 0 || first < 0 {
+			h = bestHead(r, heads, placed, bound, headVars, nextGuard)
+		}
+		placed[h] = true
+		for _, v := range heads[h].OccurVars() {
+			bound[v.Name] = true
+		}
+		step := joinStep{head: h, guards: CList{}}
+		for ; nextGuard < len(r.guard) && guardReady(r.guard[nextGuard], headVars, bound); nextGuard++ {
+			step.guards = append(step.guards, r.guard[nextGuard])
+		}
+		order = append(order, step)
+	}
+	if len(order) > 0 {
+		last := &order[len(order)-1]
+		last.guards = append(last.guards, r.guard[nextGuard:]...)
+	}
+	return order
+}
+
+// bestHead - the next head of the join order, on equal ratings the
+// head first in the source (keep-heads before del-heads)
+func bestHead(r *chrRule, heads CList, placed []bool, bound, headVars map[string]bool, nextGuard int) int {
+	best, bestKnown, bestGuards, bestRank := -1, 0, 0, 0
+	for i, h := range heads {
+		if placed[i] {
+			continue
+		}
+		known := len(headIndexPos(h, bound))
+		if h.Functor == "" && known == 0 {
+			// variable in head, not bound
+			known = -1
+		}
+		bound1 := map[string]bool{}
+		for v := range bound {
+			bound1[v] = true
+		}
+		for _, v := range h.OccurVars() {
+			bound1[v.Name] = true
+		}
+		guards := 0
+		for g := nextGuard; g < len(r.guard) && guardReady(r.guard[g], headVars, bound1); g++ {
+			guards++
+		}
+		// source position
+		rank := i - len(r.delHead)
+		if rank < 0 {
+			rank = i + len(heads)
+		}
+		if best < 0 || known > bestKnown || known == bestKnown &&
+			(guards > bestGuards || guards == bestGuards && rank < bestRank) {
+			best, bestKnown, bestGuards, bestRank = i, known, guards, rank
+		}
+	}
+	return best
+}
+
+// guardReady - all head variables of the guard g are bound
+func guardReady(g *Compound, headVars, bound map[string]bool) bool {
+	for _, v := range g.OccurVars() {
+		if headVars[v.Name] && !bound[v.Name] {
+			return false
+		}
+	}
+	return true
+}
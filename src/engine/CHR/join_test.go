@@ -0,0 +1,130 @@
+// Copyright © 2016 The Carneades Authors
+// This Source Code Form is subject to the terms of the
+// Mozilla Public License, v. 2.0. If a copy of the MPL
+// was not distributed with this file, You can obtain one
+// at http://mozilla.org/MPL/2.0/.
+
+package chr
+
+import (
+	"fmt"
+	"sort"
+	"testing"
+)
+
+func TestJoin01(t *testing.T) {
+	// the guard 'Max > N2' is checked before the third head is matched
+	rs := MakeRuleStore()
+	ok := rs.ParseStringCHRRulesGoals(`
+	fib01@ upto(A) ==> fib(0,1), fib(1,1).
+	fib02@ upto(Max), fib(N1,M1), fib(N2,M2) ==> Max > N2, N2 == N1+1 | fib(N2+1,M1+M2).
+	upto(5).
+	#result: upto(5), fib(0,1), fib(1,1), fib(2,2), fib(3,3), fib(4,5), fib(5,8).
+	`)
+	if !ok {
+		t.Error("TestJoin01 fails")
+	}
+	join := rs.prog.rules[1].join
+	if len(join) != 3 || join[0].head != 0 || join[1].head != 2 || join[2].head != 1 ||
+		len(join[0].guards) != 0 || len(join[1].guards) != 1 || len(join[2].guards) != 1 {
+		t.Errorf("TestJoin01 fails, join order: %v", join)
+	}
+}
+
+func TestJoin02(t *testing.T) {
+	// the head with a constant first, then the head with a bound variable
+	for _, mode := range []SolverMode{ModeDefault, ModeRefined} {
+		rs := MakeRuleStore()
+		rs.Mode = mode
+		ok := rs.ParseStringCHRRulesGoals(`
+		from_a @ edge(Y, Z), edge(X, Y) \ todo(a, X) <=> reach(Z).
+		edge(a, b), edge(b, c), edge(c, d), edge(b, e), todo(a, a), todo(a, b).
+		#result: edge(a, b), edge(b, c), edge(c, d), edge(b, e), reach(c), reach(d).
+		`)
+		if !ok {
+			t.Errorf("TestJoin02 fails, mode: %s", mode)
+		}
+		join := rs.prog.rules[0].join
+		// ruleHeads: todo(a, X), edge(Y, Z), edge(X, Y)
+		if len(join) != 3 || join[0].head != 0 || join[1].head != 2 || join[2].head != 1 {
+			t.Errorf("TestJoin02 fails, mode: %s, join order: %v", mode, join)
+		}
+	}
+}
+
+func TestJoin03(t *testing.T) {
+	// the tried candidates are skipped, new constraints are tried
+	rs := MakeRuleStore()
+	_, ok := rs.AddStringCHRRulesGoals(`
+	pair @ a(X) \ b(Y) <=> X < Y | c(X, Y).
+	a(5), b(1), b(2).
+	`)
+	if !ok {
+		t.Error("TestJoin03 fails, first goals")
+	}
+	_, ok = rs.AddStringCHRRulesGoals("b(7), a(0).\n#result: a(5), a(0), c(5, 7), c(0, 1), c(0, 2).\n")
+	if !ok {
+		t.Error("TestJoin03 fails, second goals")
+	}
+}
+
+func TestJoin04(t *testing.T) {
+	// a candidate, which failed the guard, is tried again, when the built-in store grows
+	rs := MakeRuleStore()
+	ok := rs.ParseStringCHRRulesGoals(`
+	r @ p(X), q(Y) <=> X < Y | ok(X, Y).
+	g @ go(X, Y) <=> X < Y, q(c).
+	p(A), q(B), go(A, B).
+	#result: q(c), ok(A, B), A < B.
+	`)
+	if !ok {
+		t.Error("TestJoin04 fails")
+	}
+}
+
+func TestJoin05(t *testing.T) {
+	// the skipped candidates of the rules with del-head change neither the stores
+	// nor the number of rule firings, also if the built-in store is reduced or
+	// narrowed by finite domains
+	progs := []string{`
+	gcd01@ gcd(0) <=> true .
+	gcd02@ gcd(N) \ gcd(M) <=> 0<N, N=<M, L := M - N | gcd(L).
+	gcd(94017), gcd(1155), gcd(2035).`, `
+	prime01 @ prime(N) ==> N>2 | prime(N-1).
+	prime02 @ prime(A) \ prime(B) <=> B > A, B mod A == 0 | true.
+	prime(100).`, `
+	source @ source(V) ==> dist(V, [V], 0).
+	del @ dist(V, L, D1) \ dist(V, M, D2) <=> D1 <= D2 | true.
+	dist_plus @ dist(V, L, D1), edge(V, D2, V2) ==> dist(V2, [V2|L], D1+D2).
+	edge(a, 3, b), edge(a, 1, c), edge(c, 1, b), edge(b, 2, d), source(a).`, `
+	r @ p(X), q(Y) <=> X < Y | ok(X, Y).
+	g @ go(X, Y) <=> X < Y, q(c).
+	p(A), q(B), go(A, B).`, `
+	r @ p(X) \ q(Y) <=> X > Y | ok(Y).
+	g @ go(A, B) <=> A == B + 1, B == 2 .
+	q(1), q(2), q(4), p(A), go(A, B).`, `
+	lt @ lt(X) \ n(Y) <=> Y < X | small(Y).
+	set @ set(X) <=> X in 1..3, X != 1, X != 2 .
+	n(1), n(2), n(3), n(5), lt(Z), set(Z).`}
+	for i, prog := range progs {
+		res := [2]string{}
+		for j, skip := range []bool{true, false} {
+			skipTried = skip
+			rs := MakeRuleStore()
+			_, ok := rs.AddStringCHRRulesGoals(prog)
+			if !ok {
+				t.Errorf("TestJoin05 fails, program %d, skipTried: %v", i, skip)
+			}
+			chr := []string{}
+			for _, c := range chr2CList1(&rs.Session) {
+				chr = append(chr, c.String())
+			}
+			sort.Strings(chr)
+			res[j] = fmt.Sprintf("%v %s %d", chr, bi2string(&rs.Session), rs.ruleFirings)
+		}
+		skipTried = true
+		if res[0] != res[1] {
+			t.Errorf("TestJoin05 fails, program %d:\n%s\n%s", i, res[0], res[1])
+		}
+	}
+}
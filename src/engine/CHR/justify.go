@@ -0,0 +1,217 @@
+// Copyright © 2016 The Carneades Authors
+// This Source Code Form is subject to the terms of the
+// Mozilla Public License, v. 2.0. If a copy of the MPL
+// was not distributed with this file, You can obtain one
+// at http://mozilla.org/MPL/2.0/.
+
+// Justifications - the derivations of the constraints of the stores
+
+package chr
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	. "github.com/hfried/GoCHR/src/engine/terms"
+)
+
+// DerivationKind - how a constraint was added to the stores
+type DerivationKind int
+
+const (
+	DerivGoal         DerivationKind = iota // a goal of the query
+	DerivRule                               // a goal of the body of a fired rule
+	DerivSubstitution                       // a constraint of the CHR-store with substituted variables
+	DerivAlternative                        // a goal of an alternative of a split disjunction
+	DerivPropagation                        // a domain or a value narrowed by the finite domain propagation
+)
+
+func (k DerivationKind) String() string {
+	switch k {
+	case DerivGoal:
+		return "goal"
+	case DerivRule:
+		return "rule"
+	case DerivSubstitution:
+		return "substitution"
+	case DerivAlternative:
+		return "alternative"
+	case DerivPropagation:
+		return "propagation"
+	}
+	return "unknown"
+}
+
+// Derivation - the justification of a constraint: the fired rule, which added
+// the constraint, with the derivations of the head constraints as premises,
+// or the constraint before a substitution as premise. The derivations of
+// the premises are shared, a derivation tree is a directed acyclic graph.
+type Derivation struct {
+	Constraint *Compound       // copy of the constraint, with its Id
+	Kind       DerivationKind  // how the constraint was added
+	Rule       string          // the fired rule (DerivRule)
+	Firing     int             // the number of the rule firing in the solver run (DerivRule)
+	Premises   []*Derivation   // del-heads first (DerivRule); the constraint before the substitution
+	Bindings   map[string]Term // the bindings of the rule variables; the substituted variables
+}
+
+// the cause of the constraints, which are added next
+type derivCause struct {
+	kind     DerivationKind
+	rule     string
+	firing   int
+	premises CList
+	bindings Bindings
+}
+
+// ruleCause - the cause of the goals of the body of the rule r, fired with the partners and env
+func ruleCause(rs *Session, r *chrRule, partners CList, env Bindings) *derivCause {
+	return &derivCause{kind: DerivRule, rule: r.name, firing: rs.ruleFirings,
+		premises: append(CList{}, partners...), bindings: env}
+}
+
+// substCause - the cause of the constraint c with the variables substituted by biEnv
+func substCause(c *Compound, biEnv Bindings) *derivCause {
+	return &derivCause{kind: DerivSubstitution, premises: CList{c}, bindings: biEnv}
+}
+
+// justify records the derivation of the constraint c, added to the stores
+// with the cause rs.cause; if c has an Id with a derivation, it is replaced
+func justify(rs *Session, c *Compound) {
+	if c.Id == nil {
+		return
+	}
+	c1 := CopyCompound(*c)
+	d := &Derivation{Constraint: &c1, Kind: DerivGoal}
+	if cause := rs.cause; cause != nil {
+		d.Kind = cause.kind
+		d.Rule = cause.rule
+		d.Firing = cause.firing
+		for _, p := range cause.premises {
+			d.Premises = append(d.Premises, derivationOf(rs, p))
+		}
+		if cause.bindings != nil {
+			d.Bindings = env2map(cause.bindings)
+		}
+	}
+	if rs.derivations == nil {
+		rs.derivations = map[string]*Derivation{}
+	}
+	rs.derivations[c.Id.String()] = d
+}
+
+// derivationOf - the recorded derivation of the constraint c; a constraint without
+// a recorded derivation (added before Justify was set) is taken as goal
+func derivationOf(rs *Session, c *Compound) *Derivation {
+	if c.Id != nil {
+		if d, ok := rs.derivations[c.Id.String()]; ok {
+			return d
+		}
+	}
+	c1 := CopyCompound(*c)
+	return &Derivation{Constraint: &c1, Kind: DerivGoal}
+}
+
+// Explain returns the derivation of the constraint c of the stores of the last
+// solver run, identified by its Id; nil, if rs.Justify was not set during the
+// run or c is unknown
+func (rs *Session) Explain(c *Compound) *Derivation {
+	if c == nil || c.Id == nil || rs.derivations == nil {
+		return nil
+	}
+	return rs.derivations[c.Id.String()]
+}
+
+func (d *Derivation) String() string {
+	s := fmt.Sprintf("%s (Id: %s) <- %s", d.Constraint, d.Constraint.Id, d.Kind)
+	if d.Rule != "" {
+		s += fmt.Sprintf(" %s [%d]", d.Rule, d.Firing)
+	}
+	if len(d.Premises) != 0 {
+		ps := make([]string, len(d.Premises))
+		for i, p := range d.Premises {
+			ps[i] = p.Constraint.String()
+		}
+		s += " [" + strings.Join(ps, ", ") + "]"
+	}
+	if len(d.Bindings) != 0 {
+		s += " " + bindings2string(d.Bindings)
+	}
+	return s
+}
+
+type jsonDerivation struct {
+	Constraint string            `json:"constraint"`
+	Id         string            `json:"id"`
+	Kind       string            `json:"kind"`
+	Rule       string            `json:"rule,omitempty"`
+	Firing     int               `json:"firing,omitempty"`
+	Bindings   map[string]string `json:"bindings,omitempty"`
+	Premises   []*jsonDerivation `json:"premises,omitempty"`
+}
+
+func (d *Derivation) toJSON() *jsonDerivation {
+	jd := &jsonDerivation{Constraint: d.Constraint.String(), Kind: d.Kind.String(),
+		Rule: d.Rule, Firing: d.Firing}
+	if d.Constraint.Id != nil {
+		jd.Id = d.Constraint.Id.String()
+	}
+	if len(d.Bindings) != 0 {
+		jd.Bindings = map[string]string{}
+		for name, t := range d.Bindings {
+			jd.Bindings[name] = t.String()
+		}
+	}
+	for _, p := range d.Premises {
+		jd.Premises = append(jd.Premises, p.toJSON())
+	}
+	return jd
+}
+
+// WriteJSON writes the derivation tree as nested JSON-objects to w; a shared
+// premise is written at every use; the terms are written in the CHR-syntax
+func (d *Derivation) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(d.toJSON())
+}
+
+// WriteDOT writes the derivation tree as Graphviz DOT graph to w: a node for
+// every derivation, an edge from every premise to the derived constraint,
+// labeled with the rule or the substitution
+func (d *Derivation) WriteDOT(w io.Writer) error {
+	var b strings.Builder
+	b.WriteString("digraph derivation {\n\trankdir=BT;\n")
+	names := map[*Derivation]string{}
+	var node func(d *Derivation) string
+	node = func(d *Derivation) string {
+		if name, ok := names[d]; ok {
+			return name
+		}
+		name := fmt.Sprintf("n%d", len(names))
+		names[d] = name
+		shape := "ellipse"
+		if d.Kind == DerivGoal || d.Kind == DerivAlternative {
+			shape = "box"
+		}
+		fmt.Fprintf(&b, "\t%s [label=%q, shape=%s];\n", name,
+			fmt.Sprintf("%s\nId: %s", d.Constraint, d.Constraint.Id), shape)
+		label := d.Kind.String()
+		if d.Rule != "" {
+			label = fmt.Sprintf("%s [%d]", d.Rule, d.Firing)
+		}
+		if len(d.Bindings) != 0 {
+			label += "\n" + bindings2string(d.Bindings)
+		}
+		for _, p := range d.Premises {
+			fmt.Fprintf(&b, "\t%s -> %s [label=%q];\n", node(p), name, label)
+		}
+		return name
+	}
+	node(d)
+	b.WriteString("}\n")
+	_, err := io.WriteString(w, b.String())
+	return err
+}
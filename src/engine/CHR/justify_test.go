@@ -0,0 +1,135 @@
+// Copyright © 2016 The Carneades Authors
+// This Source Code Form is subject to the terms of the
+// Mozilla Public License, v. 2.0. If a copy of the MPL
+// was not distributed with this file, You can obtain one
+// at http://mozilla.org/MPL/2.0/.
+
+package chr
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	. "github.com/hfried/GoCHR/src/engine/terms"
+)
+
+func justifyGoal(t *testing.T, mode SolverMode, goal string) (*RuleStore, *Result) {
+	rs := MakeRuleStore()
+	rs.Mode = mode
+	rs.Order = OrderTerm
+	rs.Justify = true
+	if !rs.ParseStringCHRRulesGoals(gcdRules + `
+	d1 @ d(X) <=> (e(X) ; f(X)).`) {
+		t.Fatal("parse rules fails")
+	}
+	g, _ := ParseGoalString(goal)
+	res, err := rs.Solve(context.Background(), g)
+	if err != nil {
+		t.Fatalf("Solve(%s) fails: %s", goal, err)
+	}
+	return rs, res
+}
+
+func findConstraint(cl CList, s string) *Compound {
+	for _, c := range cl {
+		if c.String() == s {
+			return c
+		}
+	}
+	return nil
+}
+
+func TestJustify01(t *testing.T) {
+	// the derivations of both modes
+	for _, mode := range []SolverMode{ModeDefault, ModeRefined} {
+		rs, res := justifyGoal(t, mode, "gcd(9), gcd(6), p(Y), q(Y), d(2)")
+		if res.CHRStore.String() != "[e(2), gcd(3), q(1)]" {
+			t.Fatalf("TestJustify01 fails, mode: %s, store: %s", mode, res.CHRStore)
+		}
+		for _, tc := range []struct {
+			c    string
+			want []string
+		}{
+			{"gcd(3)", []string{
+				"gcd(3) (Id: 5) <- rule gcd2 [1] [gcd(9), gcd(6)] {L=3, M=9, N=6}",
+				"gcd(9) (Id: 0) <- goal",
+				"gcd(6) (Id: 1) <- goal"}},
+			{"q(1)", []string{
+				"q(1) (Id: #) <- substitution [q(Y)] {Y=1}",
+				"q(Y) (Id: 3) <- goal"}},
+			{"e(2)", []string{
+				"e(2) (Id: #) <- alternative"}},
+		} {
+			c := findConstraint(res.CHRStore, tc.c)
+			d := rs.Explain(c)
+			if d == nil {
+				t.Errorf("TestJustify01 fails, mode: %s, no derivation of %s", mode, tc.c)
+				continue
+			}
+			got := []string{d.String()}
+			for _, p := range d.Premises {
+				got = append(got, p.String())
+			}
+			// the Id depends on the mode: ModeDefault adds a substituted constraint again
+			tc.want[0] = strings.Replace(tc.want[0], "#", c.Id.String(), 1)
+			if strings.Join(got, "\n") != strings.Join(tc.want, "\n") {
+				t.Errorf("TestJustify01 fails, mode: %s, derivation of %s:\n%s\nexpected:\n%s",
+					mode, tc.c, strings.Join(got, "\n"), strings.Join(tc.want, "\n"))
+			}
+		}
+		if d := rs.Explain(findConstraint(res.BuiltInStore, "Y==1")); d == nil ||
+			d.Kind != DerivRule || d.Rule != "eq" || d.Premises[0].Constraint.String() != "p(Y)" {
+			t.Errorf("TestJustify01 fails, mode: %s, derivation of Y==1: %v", mode, d)
+		}
+	}
+}
+
+func TestJustify02(t *testing.T) {
+	// export as JSON and DOT, no derivations without Justify
+	rs, res := justifyGoal(t, ModeDefault, "gcd(4), gcd(6)")
+	d := rs.Explain(findConstraint(res.CHRStore, "gcd(2)"))
+	if d == nil {
+		t.Fatalf("TestJustify02 fails, no derivation, store: %s", res.CHRStore)
+	}
+
+	var out bytes.Buffer
+	if err := d.WriteJSON(&out); err != nil {
+		t.Fatal(err)
+	}
+	var jd jsonDerivation
+	if err := json.Unmarshal(out.Bytes(), &jd); err != nil {
+		t.Fatalf("TestJustify02 fails, JSON: %s, err: %s", out.String(), err)
+	}
+	// gcd(2) <- gcd(4) \ gcd(6) <=> gcd(2)
+	if jd.Constraint != "gcd(2)" || jd.Rule != "gcd2" || jd.Firing != 1 || len(jd.Premises) != 2 ||
+		jd.Premises[0].Constraint != "gcd(6)" || jd.Premises[1].Kind != "goal" || jd.Bindings["L"] != "2" {
+		t.Errorf("TestJustify02 fails, JSON: %s", out.String())
+	}
+
+	out.Reset()
+	if err := d.WriteDOT(&out); err != nil {
+		t.Fatal(err)
+	}
+	dot := out.String()
+	for _, want := range []string{
+		"digraph derivation {",
+		`n0 [label="gcd(2)\nId: 2", shape=ellipse];`,
+		`n1 [label="gcd(6)\nId: 1", shape=box];`,
+		`n1 -> n0 [label="gcd2 [1]\n{L=2, M=6, N=4}"];`,
+		`n2 -> n0 [label="gcd2 [1]\n{L=2, M=6, N=4}"];`,
+	} {
+		if !strings.Contains(dot, want) {
+			t.Errorf("TestJustify02 fails, missing: %s, in DOT:\n%s", want, dot)
+		}
+	}
+
+	rs.Justify = false
+	g, _ := ParseGoalString("gcd(4), gcd(6)")
+	res, _ = rs.Solve(context.Background(), g)
+	if d := rs.Explain(res.CHRStore[0]); d != nil {
+		t.Errorf("TestJustify02 fails, derivation without Justify: %s", d)
+	}
+}
@@ -0,0 +1,643 @@
+// Copyright © 2016 The Carneades Authors
+// This Source Code Form is subject to the terms of the
+// Mozilla Public License, v. 2.0. If a copy of the MPL
+// was not distributed with this file, You can obtain one
+// at http://mozilla.org/MPL/2.0/.
+
+// Linear arithmetic - the linear constraints ==, !=, <, <=, > and >= of the
+// built-in store over the rational numbers: Gaussian elimination for the
+// equations and the simplex method for the inequations
+
+package chr
+
+import (
+	"math/big"
+	"sort"
+
+	. "github.com/hfried/GoCHR/src/engine/terms"
+)
+
+var (
+	ratZero     = new(big.Rat)
+	ratOne      = big.NewRat(1, 1)
+	ratMinusOne = big.NewRat(-1, 1)
+)
+
+// linExpr - the linear expression c + sum coef[v]*v, v the key of a variable;
+// the rational numbers are not changed after their creation
+type linExpr struct {
+	coef map[string]*big.Rat // coefficients != 0
+	c    *big.Rat
+}
+
+func newLinExpr(c *big.Rat) linExpr {
+	return linExpr{coef: map[string]*big.Rat{}, c: c}
+}
+
+// addScaled - the new expression e + f*e2
+func (e linExpr) addScaled(f *big.Rat, e2 linExpr) linExpr {
+	e3 := newLinExpr(new(big.Rat).Add(e.c, new(big.Rat).Mul(f, e2.c)))
+	for v, a := range e.coef {
+		e3.coef[v] = a
+	}
+	for v, a := range e2.coef {
+		a3 := new(big.Rat).Mul(f, a)
+		if a1, ok := e3.coef[v]; ok {
+			a3.Add(a3, a1)
+		}
+		if a3.Sign() == 0 {
+			delete(e3.coef, v)
+		} else {
+			e3.coef[v] = a3
+		}
+	}
+	return e3
+}
+
+// scale - the new expression f*e
+func (e linExpr) scale(f *big.Rat) linExpr {
+	return newLinExpr(ratZero).addScaled(f, e)
+}
+
+// substVar - e with the expression ev for the variable v
+func (e linExpr) substVar(v string, ev linExpr) linExpr {
+	a, ok := e.coef[v]
+	if !ok {
+		return e
+	}
+	e2 := e.addScaled(a, ev)
+	delete(e2.coef, v)
+	return e2
+}
+
+// vars - the variables of e in the order of their keys
+func (e linExpr) vars() []string {
+	vs := make([]string, 0, len(e.coef))
+	for v := range e.coef {
+		vs = append(vs, v)
+	}
+	sort.Strings(vs)
+	return vs
+}
+
+// linConstraint - the linear constraint e rel 0, rel: "==", "!=", "<" or "<="
+type linConstraint struct {
+	e   linExpr
+	rel string
+}
+
+// linSystem - the linear constraints of the built-in store
+type linSystem struct {
+	vars map[string]Variable // key -> variable
+	cons []linConstraint
+}
+
+// the relations of the linear constraints in the built-in store
+var linRelations = []string{"==", "!=", "<", "<=", "=<", ">", ">="}
+
+// linearStore - the linear constraints of the built-in store of rs; the other
+// built-in constraints, e.g. X == f(Y) or constraints with floats, are ignored
+func linearStore(rs *Session) *linSystem {
+	s := &linSystem{vars: map[string]Variable{}}
+	for _, rel := range linRelations {
+		aChr, ok := rs.BuiltInStore[rel]
+		if !ok {
+			continue
+		}
+		for _, con := range aChr.varArg {
+			if con == nil || con.IsDeleted {
+				continue
+			}
+			if c, ok := s.linearRelation(*con); ok && len(c.e.coef) != 0 {
+				s.cons = append(s.cons, c)
+			}
+		}
+	}
+	return s
+}
+
+// linearRelation - the linear constraint of the relation t, e.g. X <= Y + 3: X - Y - 3 <= 0
+func (s *linSystem) linearRelation(t Compound) (linConstraint, bool) {
+	if len(t.Args) != 2 {
+		return linConstraint{}, false
+	}
+	rel, a1, a2 := t.Functor, t.Args[0], t.Args[1]
+	switch rel {
+	case "==", "!=", "<", "<=":
+	case "=<":
+		rel = "<="
+	case ">":
+		rel, a1, a2 = "<", a2, a1
+	case ">=":
+		rel, a1, a2 = "<=", a2, a1
+	default:
+		return linConstraint{}, false
+	}
+	e1, ok := s.linearExpr(a1)
+	if !ok {
+		return linConstraint{}, false
+	}
+	e2, ok := s.linearExpr(a2)
+	if !ok {
+		return linConstraint{}, false
+	}
+	return linConstraint{e: e1.addScaled(ratMinusOne, e2), rel: rel}, true
+}
+
+// linearExpr - the linear expression of the term t with integers, rationals,
+// variables, +, -, * by a number and rdiv by a number
+func (s *linSystem) linearExpr(t Term) (linExpr, bool) {
+	switch t := t.(type) {
+	case Int, BigInt, Rational:
+		return newLinExpr(bigRat(t)), true
+	case Variable:
+		k := t.Key()
+		s.vars[k] = t
+		e := newLinExpr(ratZero)
+		e.coef[k] = ratOne
+		return e, true
+	case Compound:
+		if t.Prio == 0 || len(t.Args) == 0 || len(t.Args) > 2 {
+			return linExpr{}, false
+		}
+		e1, ok := s.linearExpr(t.Args[0])
+		if !ok {
+			return linExpr{}, false
+		}
+		if len(t.Args) == 1 {
+			switch t.Functor {
+			case "+":
+				return e1, true
+			case "-":
+				return e1.scale(ratMinusOne), true
+			}
+			return linExpr{}, false
+		}
+		e2, ok := s.linearExpr(t.Args[1])
+		if !ok {
+			return linExpr{}, false
+		}
+		switch t.Functor {
+		case "+":
+			return e1.addScaled(ratOne, e2), true
+		case "-":
+			return e1.addScaled(ratMinusOne, e2), true
+		case "*":
+			if len(e1.coef) == 0 {
+				return e2.scale(e1.c), true
+			}
+			if len(e2.coef) == 0 {
+				return e1.scale(e2.c), true
+			}
+		case "rdiv":
+			if len(e2.coef) == 0 && e2.c.Sign() != 0 {
+				return e1.scale(new(big.Rat).Inv(e2.c)), true
+			}
+		}
+	}
+	return linExpr{}, false
+}
+
+// pivot - the solved equation v == e of the Gaussian elimination,
+// e has no pivot variables
+type pivot struct {
+	v string
+	e linExpr
+}
+
+// linTableau - the solved form of linear constraints, updated by add: the equations
+// in reduced row echelon form (Gaussian elimination) and the inequations and
+// disequations without the pivot variables. The tableau of the built-in store of a
+// session is kept in the session and updated, if a relation is added, see linearAdd.
+type linTableau struct {
+	vars     map[string]Variable // key -> variable, shared by the copies
+	n        int                 // number of the added constraints
+	pivots   []pivot
+	ineqs    []linConstraint // the inequations e < 0 and e <= 0 without the pivot variables
+	diseqs   []linConstraint // the disequations e != 0 without the pivot variables
+	unsolved bool            // the equations have no solution
+	checked  bool            // feasible is the result of the simplex method for the constraints
+	feasible bool
+	version  int // the version of the built-in store (Session.biVersion) of the tableau
+}
+
+func newLinTableau(vars map[string]Variable) *linTableau {
+	return &linTableau{vars: vars}
+}
+
+// tableau - the tableau of the constraints of s
+func (s *linSystem) tableau() *linTableau {
+	t := newLinTableau(s.vars)
+	for _, c := range s.cons {
+		t.add(c)
+	}
+	return t
+}
+
+// relation - the linear constraint of the relation g with the variables of t
+func (t *linTableau) relation(g Compound) (linConstraint, bool) {
+	return (&linSystem{vars: t.vars}).linearRelation(g)
+}
+
+// copy - a copy of t, which is updated independently of t
+func (t *linTableau) copy() *linTableau {
+	t1 := *t
+	t1.pivots = append([]pivot(nil), t.pivots...)
+	t1.ineqs = append([]linConstraint(nil), t.ineqs...)
+	t1.diseqs = append([]linConstraint(nil), t.diseqs...)
+	return &t1
+}
+
+// add adds the constraint c to t: an equation is solved for its first variable,
+// which is substituted in the other constraints; the other constraints are added
+// without the pivot variables
+func (t *linTableau) add(c linConstraint) {
+	t.n++
+	t.checked = false
+	if t.unsolved {
+		return
+	}
+	e := substPivots(c.e, t.pivots)
+	if c.rel != "==" {
+		if c.rel == "!=" {
+			t.diseqs = append(t.diseqs, linConstraint{e: e, rel: c.rel})
+		} else {
+			t.ineqs = append(t.ineqs, linConstraint{e: e, rel: c.rel})
+		}
+		return
+	}
+	if len(e.coef) == 0 {
+		t.unsolved = e.c.Sign() != 0
+		return
+	}
+	// a*v + e' == 0: v == -e'/a
+	v := e.vars()[0]
+	ev := e.scale(new(big.Rat).Neg(new(big.Rat).Inv(e.coef[v])))
+	delete(ev.coef, v)
+	for i := range t.pivots {
+		t.pivots[i].e = t.pivots[i].e.substVar(v, ev)
+	}
+	for i := range t.ineqs {
+		t.ineqs[i].e = t.ineqs[i].e.substVar(v, ev)
+	}
+	for i := range t.diseqs {
+		t.diseqs[i].e = t.diseqs[i].e.substVar(v, ev)
+	}
+	t.pivots = append(t.pivots, pivot{v: v, e: ev})
+}
+
+func substPivots(e linExpr, pivots []pivot) linExpr {
+	for _, p := range pivots {
+		e = e.substVar(p.v, p.e)
+	}
+	return e
+}
+
+// isFeasible - the constraints of t have a solution; the result is kept up to
+// the next added constraint
+func (t *linTableau) isFeasible() bool {
+	if t.unsolved {
+		return false
+	}
+	if t.checked {
+		return t.feasible
+	}
+	t.checked, t.feasible = true, feasibleIneqs(t.ineqs)
+	// a convex set is not covered by a finite number of hyperplanes,
+	// if it is not contained in one of them
+	n := len(t.ineqs)
+	for _, d := range t.diseqs {
+		if !t.feasible {
+			break
+		}
+		if !feasibleIneqs(append(t.ineqs[:n:n], linConstraint{e: d.e, rel: "<"})) &&
+			!feasibleIneqs(append(t.ineqs[:n:n], linConstraint{e: d.e.scale(ratMinusOne), rel: "<"})) {
+			t.feasible = false
+		}
+	}
+	return t.feasible
+}
+
+// with - a copy of t with the constraint c
+func (t *linTableau) with(c linConstraint) *linTableau {
+	t1 := t.copy()
+	t1.add(c)
+	return t1
+}
+
+// entails - every solution of t is a solution of c
+func (t *linTableau) entails(c linConstraint) bool {
+	switch c.rel {
+	case "<=":
+		return !t.with(linConstraint{e: c.e.scale(ratMinusOne), rel: "<"}).isFeasible()
+	case "<":
+		return !t.with(linConstraint{e: c.e.scale(ratMinusOne), rel: "<="}).isFeasible()
+	case "==":
+		return t.entails(linConstraint{e: c.e, rel: "<="}) &&
+			t.entails(linConstraint{e: c.e.scale(ratMinusOne), rel: "<="})
+	case "!=":
+		return !t.with(linConstraint{e: c.e, rel: "=="}).isFeasible()
+	}
+	return false
+}
+
+// determined - the bindings of the variables with values determined by the equations of t
+func (t *linTableau) determined() (env Bindings) {
+	if t.unsolved {
+		return nil
+	}
+	for _, p := range t.pivots {
+		if len(p.e.coef) == 0 {
+			env = AddBinding(t.vars[p.v], NewRational(p.e.c), env)
+		}
+	}
+	return env
+}
+
+// feasible - the constraints of s have a solution
+func (s *linSystem) feasible() bool {
+	return s.tableau().isFeasible()
+}
+
+// entails - every solution of s is a solution of c
+func (s *linSystem) entails(c linConstraint) bool {
+	return s.tableau().entails(c)
+}
+
+// determined - the bindings of the variables with values determined by the equations of s
+func (s *linSystem) determined() Bindings {
+	return s.tableau().determined()
+}
+
+// feasibleIneqs - the inequations e <= 0 and e < 0 have a solution; the
+// simplex method maximizes d with e + d <= 0 for the strict inequations and
+// 0 <= d <= 1, the strict inequations have a solution, if the maximum is > 0
+func feasibleIneqs(ineqs []linConstraint) bool {
+	col := map[string]int{}
+	rows := []linConstraint{}
+	strict := false
+	for _, c := range ineqs {
+		if len(c.e.coef) == 0 {
+			if sign := c.e.c.Sign(); sign > 0 || (sign == 0 && c.rel == "<") {
+				return false
+			}
+			continue
+		}
+		rows = append(rows, c)
+		strict = strict || c.rel == "<"
+		for v := range c.e.coef {
+			col[v] = 0
+		}
+	}
+	if len(rows) == 0 {
+		return true
+	}
+	// the columns: v = v+ - v- with v+, v- >= 0 for the variables in key order and d
+	vs := make([]string, 0, len(col))
+	for v := range col {
+		vs = append(vs, v)
+	}
+	sort.Strings(vs)
+	for i, v := range vs {
+		col[v] = 2 * i
+	}
+	n := 2*len(vs) + 1
+	d := n - 1
+	newRow := func() []*big.Rat {
+		row := make([]*big.Rat, n)
+		for j := range row {
+			row[j] = ratZero
+		}
+		return row
+	}
+	A := [][]*big.Rat{}
+	b := []*big.Rat{}
+	for _, c := range rows {
+		row := newRow()
+		for v, a := range c.e.coef {
+			row[col[v]] = a
+			row[col[v]+1] = new(big.Rat).Neg(a)
+		}
+		if c.rel == "<" {
+			row[d] = ratOne
+		}
+		A = append(A, row)
+		b = append(b, new(big.Rat).Neg(c.e.c))
+	}
+	obj := newRow()
+	if strict {
+		row := newRow()
+		row[d] = ratOne
+		A = append(A, row)
+		b = append(b, ratOne)
+		obj[d] = ratOne
+	}
+	feasible, max := simplex(A, b, obj)
+	return feasible && (!strict || max.Sign() > 0)
+}
+
+// simplex - the maximum of obj*x with A*x <= b and x >= 0; the two-phase simplex
+// method with Bland's rule over the rational numbers; feasible == false: there is
+// no x, the maximum is nil, if obj*x is unbounded
+func simplex(A [][]*big.Rat, b []*big.Rat, obj []*big.Rat) (feasible bool, max *big.Rat) {
+	m, n := len(A), len(obj)
+	arts := 0
+	for _, bi := range b {
+		if bi.Sign() < 0 {
+			arts++
+		}
+	}
+	// the tableau: the columns of x, of the slack variables, of the artificial
+	// variables of the rows with b < 0 and the right sides
+	cols := n + m + arts
+	t := make([][]*big.Rat, m)
+	basis := make([]int, m)
+	art := n + m
+	for i := range A {
+		row := make([]*big.Rat, cols+1)
+		copy(row, A[i])
+		for j := n; j < cols; j++ {
+			row[j] = ratZero
+		}
+		row[n+i] = ratOne
+		row[cols] = b[i]
+		basis[i] = n + i
+		if b[i].Sign() < 0 {
+			for j := 0; j <= cols; j++ {
+				row[j] = new(big.Rat).Neg(row[j])
+			}
+			row[art] = ratOne
+			basis[i] = art
+			art++
+		}
+		t[i] = row
+	}
+	// phase 1: maximize -sum of the artificial variables
+	z := make([]*big.Rat, cols+1)
+	for j := range z {
+		z[j] = ratZero
+	}
+	for i := range t {
+		if basis[i] >= n+m {
+			for j := 0; j < n+m; j++ {
+				z[j] = new(big.Rat).Add(z[j], t[i][j])
+			}
+			z[cols] = new(big.Rat).Add(z[cols], t[i][cols])
+		}
+	}
+	simplexLoop(t, basis, z, n+m)
+	if z[cols].Sign() != 0 {
+		return false, nil
+	}
+	// the artificial variables left in the basis are 0: they leave the basis,
+	// if their row is not redundant
+	for i := range t {
+		if basis[i] < n+m {
+			continue
+		}
+		for j := 0; j < n+m; j++ {
+			if t[i][j].Sign() != 0 {
+				simplexPivot(t, basis, z, i, j)
+				break
+			}
+		}
+	}
+	// phase 2: maximize obj*x, the objective in the non-basic variables
+	z = make([]*big.Rat, cols+1)
+	for j := range z {
+		z[j] = ratZero
+	}
+	copy(z, obj)
+	for i := range t {
+		if bj := basis[i]; bj < n && obj[bj].Sign() != 0 {
+			eliminate(z, t[i], obj[bj])
+		}
+	}
+	if !simplexLoop(t, basis, z, n+m) {
+		return true, nil
+	}
+	return true, new(big.Rat).Neg(z[cols])
+}
+
+// simplexLoop - pivots up to the maximum of the objective row z, only the columns < ncols
+// enter the basis; false: the objective is unbounded. z[j] is the gain of the non-basic
+// variable j, z[len(z)-1] the negative value of the objective
+func simplexLoop(t [][]*big.Rat, basis []int, z []*big.Rat, ncols int) bool {
+	rhs := len(z) - 1
+	for {
+		// Bland's rule: the first column with a gain enters the basis, of the rows with the
+		// smallest ratio the row with the first basic variable leaves the basis
+		j := -1
+		for k := 0; k < ncols; k++ {
+			if z[k].Sign() > 0 {
+				j = k
+				break
+			}
+		}
+		if j < 0 {
+			return true
+		}
+		r := -1
+		var ratio *big.Rat
+		for i := range t {
+			if t[i][j].Sign() <= 0 {
+				continue
+			}
+			q := new(big.Rat).Quo(t[i][rhs], t[i][j])
+			if r < 0 || q.Cmp(ratio) < 0 || (q.Cmp(ratio) == 0 && basis[i] < basis[r]) {
+				r, ratio = i, q
+			}
+		}
+		if r < 0 {
+			return false
+		}
+		simplexPivot(t, basis, z, r, j)
+	}
+}
+
+// simplexPivot - the variable of column j enters the basis in row r
+func simplexPivot(t [][]*big.Rat, basis []int, z []*big.Rat, r, j int) {
+	f := new(big.Rat).Inv(t[r][j])
+	for k := range t[r] {
+		if t[r][k].Sign() != 0 {
+			t[r][k] = new(big.Rat).Mul(t[r][k], f)
+		}
+	}
+	for i := range t {
+		if i != r && t[i][j].Sign() != 0 {
+			eliminate(t[i], t[r], t[i][j])
+		}
+	}
+	if z[j].Sign() != 0 {
+		eliminate(z, t[r], z[j])
+	}
+	basis[r] = j
+}
+
+// eliminate - row = row - f*prow
+func eliminate(row, prow []*big.Rat, f *big.Rat) {
+	for k := range row {
+		if prow[k].Sign() != 0 {
+			row[k] = new(big.Rat).Sub(row[k], new(big.Rat).Mul(f, prow[k]))
+		}
+	}
+}
+
+// linearTableau - the tableau of the linear constraints of the built-in store of rs;
+// it is built again, if the built-in store changed otherwise than by linearAdd
+func linearTableau(rs *Session) *linTableau {
+	if rs.lin == nil || rs.lin.version != rs.biVersion {
+		rs.lin = linearStore(rs).tableau()
+		rs.lin.version = rs.biVersion
+	}
+	return rs.lin
+}
+
+// linearAdd adds the built-in constraint g, which was added to the built-in store
+// of rs as version rs.biVersion, to the tableau of rs, if g is a linear relation;
+// a tableau of an older version is built again by linearTableau
+func linearAdd(rs *Session, g Compound) {
+	t := rs.lin
+	if t == nil || t.version != rs.biVersion-1 {
+		return
+	}
+	t.version = rs.biVersion
+	if g.Prio == 0 || len(g.Args) != 2 {
+		return
+	}
+	if c, ok := t.relation(g); ok && len(c.e.coef) != 0 {
+		t.add(c)
+	}
+}
+
+// linearConsistent - the built-in store of rs has a solution after the built-in
+// constraint g was added, if g is a linear inequation or an arithmetic equation
+func linearConsistent(rs *Session, g Compound) bool {
+	if g.Prio == 0 || len(g.Args) != 2 {
+		return true
+	}
+	switch g.Functor {
+	case "==":
+		if !isArithmetic(g.Args[0]) && !isArithmetic(g.Args[1]) {
+			return true
+		}
+	case "!=", "<", "<=", "=<", ">", ">=":
+	default:
+		return true
+	}
+	t := linearTableau(rs)
+	if c, ok := t.relation(g); !ok || len(c.e.coef) == 0 {
+		return true
+	}
+	return t.isFeasible()
+}
+
+// linearEntailed - the linear constraint g is entailed by the built-in store of rs
+func linearEntailed(rs *Session, g Compound) bool {
+	t := linearTableau(rs)
+	if t.n == 0 {
+		return false
+	}
+	c, ok := t.relation(g)
+	return ok && len(c.e.coef) != 0 && t.entails(c)
+}
@@ -0,0 +1,197 @@
+// Copyright © 2016 The Carneades Authors
+// This Source Code Form is subject to the terms of the
+// Mozilla Public License, v. 2.0. If a copy of the MPL
+// was not distributed with this file, You can obtain one
+// at http://mozilla.org/MPL/2.0/.
+
+package chr
+
+import (
+	"fmt"
+	"testing"
+
+	. "github.com/hfried/GoCHR/src/engine/parser"
+	. "github.com/hfried/GoCHR/src/engine/terms"
+)
+
+// tLinSystem - the linear system of the constraints in the list src
+func tLinSystem(t *testing.T, src string) *linSystem {
+	l, ok := ReadString(src)
+	if !ok {
+		t.Fatalf("parse error: %s", src)
+	}
+	s := &linSystem{vars: map[string]Variable{}}
+	for _, c := range l.(List) {
+		lc, ok := s.linearRelation(c.(Compound))
+		if !ok {
+			t.Fatalf("not linear: %s", c)
+		}
+		s.cons = append(s.cons, lc)
+	}
+	return s
+}
+
+func TestLinear01(t *testing.T) {
+	// feasibility
+	for _, c := range []struct {
+		src      string
+		feasible bool
+	}{
+		{"[X + Y == 10, X - Y == 2]", true},
+		{"[X + Y == 10, 2*X + 2*Y == 21]", false},
+		{"[X + Y == 10, 2*X + 2*Y == 20]", true},
+		{"[X <= Y + 3, Y <= 2, X >= 5]", true},
+		{"[X <= Y + 3, Y <= 2, X >= 6]", false},
+		{"[X <= Y + 3, Y <= 2, X > 5]", false},
+		{"[X > 3, X < 4]", true},
+		{"[X > 3, X < 3]", false},
+		{"[X >= 3, X =< 3]", true},
+		{"[X >= 3, X =< 3, X != 3]", false},
+		{"[X >= 3, X =< 4, X != 3, X != 4]", true},
+		{"[X + Y == 1, X - Y != 1, X >= 1, Y >= 0]", false},
+		{"[X * (1 rdiv 3) == 2 rdiv 3, X < 2]", false},
+		{"[A + B + C <= 10, A >= 4, B >= 4, C >= 2 + 1 rdiv 2]", false},
+		{"[A + B + C <= 10, A >= 4, B >= 4, C >= 2]", true},
+	} {
+		if tLinSystem(t, c.src).feasible() != c.feasible {
+			t.Errorf("TestLinear01 fails: %s, feasible should be %v", c.src, c.feasible)
+		}
+	}
+}
+
+func TestLinear02(t *testing.T) {
+	// entailment
+	for _, c := range []struct {
+		src, con string
+		entailed bool
+	}{
+		{"[X <= Y + 3, Y <= 2]", "X <= 5", true},
+		{"[X <= Y + 3, Y <= 2]", "X < 5", false},
+		{"[X <= Y + 3, Y < 2]", "X < 5", true},
+		{"[X <= Y + 3, Y <= 2]", "X <= 4", false},
+		{"[X > 3, X < 4]", "X != 3", true},
+		{"[X >= 3]", "X != 3", false},
+		{"[X + Y == 10, X - Y == 2]", "X == 6", true},
+		{"[X + Y == 10]", "Y == 10 - X", true},
+		{"[X + Y == 10]", "X == 6", false},
+		{"[X >= 3, X =< 3]", "X == 3", true},
+	} {
+		s := tLinSystem(t, c.src)
+		con, _ := ReadString(c.con)
+		lc, ok := s.linearRelation(con.(Compound))
+		if !ok || s.entails(lc) != c.entailed {
+			t.Errorf("TestLinear02 fails: %s entails %s should be %v", c.src, c.con, c.entailed)
+		}
+	}
+}
+
+func TestLinear03(t *testing.T) {
+	// values determined by the equations
+	s := tLinSystem(t, "[X + Y + Z == 6, X - Y == 0, 2 * Z == 4 - X, W >= X]")
+	env := s.determined()
+	for _, c := range [][2]string{{"X", "8 rdiv 3"}, {"Y", "8 rdiv 3"}, {"Z", "2 rdiv 3"}} {
+		want, _ := ReadString(c[1])
+		val, ok := GetBinding(NewVariable(c[0]), env)
+		if !ok || !Equal(val, Eval(want)) {
+			t.Errorf("TestLinear03 fails: %s == %v, should be %s", c[0], val, c[1])
+		}
+	}
+	if _, ok := GetBinding(NewVariable("W"), env); ok {
+		t.Error("TestLinear03 fails: W is determined")
+	}
+}
+
+func TestLinear04(t *testing.T) {
+	// the linear constraints of the built-in store, in the guards and the bodies
+	for _, mode := range []SolverMode{ModeDefault, ModeRefined} {
+		rs := MakeRuleStore()
+		rs.Mode = mode
+		ok := rs.ParseStringCHRRulesGoals(`
+		r1 @ go1(X, Y) <=> X <= Y + 3, Y <= 2, X >= 5, p(X).
+		r2 @ p(X) <=> X <= 5 | small(X).
+		r3 @ go2(X, Y) <=> X <= Y + 3, Y <= 2, X >= 6 .
+		r4 @ go3(X, Y, Z) <=> X + Y + Z == 6, X - Y == 0, q(X, Y, Z), Z == 2 .
+		r5 @ q(X, X, X) <=> same(X).
+		go1(A, B).
+		#result: small(A).
+		go2(A, B).
+		#result: false.
+		go3(A, B, C).
+		#result: same(2).
+		`)
+		if !ok {
+			t.Errorf("TestLinear04 fails, mode: %s", mode)
+		}
+	}
+}
+
+func TestLinear05(t *testing.T) {
+	// the tableau of the session is updated, when a relation is added, and
+	// restored at the backtracking to an alternative
+	for _, mode := range []SolverMode{ModeDefault, ModeRefined} {
+		rs := MakeRuleStore()
+		rs.Mode = mode
+		ok := rs.ParseStringCHRRulesGoals(`
+		p @ p(X) <=> X >= 3, d(X).
+		d @ d(X) <=> (X < 2, q(X) ; X > 5, q(X)).
+		r @ q(X) <=> X > 4 | big(X).
+		s @ q(X) <=> small(X).
+		p(A).
+		#result: big(A), 3 <= A, 5 < A .
+		`)
+		if !ok {
+			t.Errorf("TestLinear05 fails, mode: %s", mode)
+		}
+		// the tableau is built again after the reduction of the built-in store
+		lin := linearTableau(&rs.Session)
+		if lin != rs.lin || linearTableau(&rs.Session) != lin || lin.n != 2 || len(lin.ineqs) != 2 {
+			t.Fatalf("TestLinear05 fails, mode: %s, tableau: %+v", mode, lin)
+		}
+		// the same tableau, built from the built-in store
+		built := linearStore(&rs.Session).tableau()
+		c, _ := lin.relation(Compound{Functor: ">", Prio: 3, Args: []Term{NewVariable("A"), Int(5)}})
+		if !built.entails(c) || !lin.entails(c) || lin.isFeasible() != built.isFeasible() {
+			t.Errorf("TestLinear05 fails, mode: %s, tableau: %+v, built: %+v", mode, lin, built)
+		}
+		// a choice point keeps a copy of the tableau, it is not built again
+		cp := newChoicePoint(&rs.Session, nil)
+		addConstraintToStore(&rs.Session, Compound{Functor: "<", Prio: 3, Args: []Term{NewVariable("A"), Int(7)}})
+		if rs.lin != lin || lin.n != 3 || lin.version != rs.biVersion {
+			t.Errorf("TestLinear05 fails, mode: %s, added: %+v", mode, rs.lin)
+		}
+		restoreChoicePoint(&rs.Session, cp)
+		if rs.lin.n != 2 || rs.lin.version != rs.biVersion || len(cp.lin.ineqs) != 2 {
+			t.Errorf("TestLinear05 fails, mode: %s, restored: %+v", mode, rs.lin)
+		}
+	}
+}
+
+// bindingsString - the bindings of env as string
+func bindingsString(env Bindings) string {
+	s := ""
+	for b := env; b != nil; b = b.Next {
+		s += fmt.Sprintf("%s == %s, ", b.Var, b.T)
+	}
+	return s
+}
+
+func TestLinear06(t *testing.T) {
+	// the incremental tableau is the tableau of all constraints
+	s := tLinSystem(t, "[X + Y + Z == 6, W >= X, X - Y == 0, Z < W, 2 * Z == 4 - X, X != W]")
+	for n := 0; n <= len(s.cons); n++ {
+		part := &linSystem{vars: s.vars, cons: s.cons[:n]}
+		tab := newLinTableau(s.vars)
+		for _, c := range part.cons {
+			tab.add(c)
+			tab.isFeasible()
+		}
+		all := part.tableau()
+		if tab.isFeasible() != all.isFeasible() || bindingsString(tab.determined()) != bindingsString(all.determined()) {
+			t.Errorf("TestLinear06 fails, %d constraints: %+v, %+v", n, tab, all)
+		}
+		// a copy is changed independently
+		if tab2 := tab.with(linConstraint{e: newLinExpr(ratOne), rel: "<="}); tab2.isFeasible() || !tab.isFeasible() {
+			t.Errorf("TestLinear06 fails, copy of %d constraints", n)
+		}
+	}
+}
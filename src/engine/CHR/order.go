@@ -0,0 +1,101 @@
+// Copyright © 2016 The Carneades Authors
+// This Source Code Form is subject to the terms of the
+// Mozilla Public License, v. 2.0. If a copy of the MPL
+// was not distributed with this file, You can obtain one
+// at http://mozilla.org/MPL/2.0/.
+
+// Order of the constraints in the output of the CHR- and built-in store
+
+package chr
+
+import (
+	"fmt"
+	"sort"
+
+	. "github.com/hfried/GoCHR/src/engine/terms"
+)
+
+type StoreOrder int
+
+const (
+	// OrderNone - the order of the internal store, it may change from run to run
+	OrderNone StoreOrder = iota
+	// OrderId - the order of the insertion into the store (constraint Id)
+	OrderId
+	// OrderTerm - canonical term order: functor, arity, then the printed term
+	OrderTerm
+)
+
+func (o StoreOrder) String() string {
+	switch o {
+	case OrderNone:
+		return "none"
+	case OrderId:
+		return "id"
+	case OrderTerm:
+		return "term"
+	}
+	return "unknown"
+}
+
+// ParseStoreOrder - the store order with the name s ("none", "id" or "term")
+func ParseStoreOrder(s string) (StoreOrder, error) {
+	for _, o := range []StoreOrder{OrderNone, OrderId, OrderTerm} {
+		if o.String() == s {
+			return o, nil
+		}
+	}
+	return OrderNone, fmt.Errorf("unknown store order %q, expected none, id or term", s)
+}
+
+// storeCList - the constraints of the store s, which are not deleted
+func storeCList(s store) CList {
+	l := CList{}
+	for _, aChr := range s {
+		for _, con := range aChr.varArg {
+			if con != nil && !con.IsDeleted {
+				l = append(l, con)
+			}
+		}
+		for _, con := range aChr.noArg {
+			if con != nil && !con.IsDeleted {
+				l = append(l, con)
+			}
+		}
+	}
+	return l
+}
+
+// sortedStore - the constraints of the store s in the order rs.Order
+func sortedStore(rs *Session, s store) CList {
+	l := storeCList(s)
+	switch rs.Order {
+	case OrderId:
+		sort.SliceStable(l, func(i, j int) bool { return lessId(l[i], l[j]) })
+	case OrderTerm:
+		sort.SliceStable(l, func(i, j int) bool { return lessTerm(l[i], l[j]) })
+	}
+	return l
+}
+
+// lessId - c1 is added before c2, constraints without Id at the end
+func lessId(c1, c2 *Compound) bool {
+	if c1.Id == nil || c2.Id == nil {
+		return c1.Id != nil && c2.Id == nil
+	}
+	return c1.Id.Cmp(c2.Id) < 0
+}
+
+func lessTerm(c1, c2 *Compound) bool {
+	if c1.Functor != c2.Functor {
+		return c1.Functor < c2.Functor
+	}
+	if len(c1.Args) != len(c2.Args) {
+		return len(c1.Args) < len(c2.Args)
+	}
+	s1, s2 := c1.String(), c2.String()
+	if s1 != s2 {
+		return s1 < s2
+	}
+	return lessId(c1, c2)
+}
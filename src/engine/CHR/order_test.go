@@ -0,0 +1,55 @@
+// Copyright © 2016 The Carneades Authors
+// This Source Code Form is subject to the terms of the
+// Mozilla Public License, v. 2.0. If a copy of the MPL
+// was not distributed with this file, You can obtain one
+// at http://mozilla.org/MPL/2.0/.
+
+package chr
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestOrder01(t *testing.T) {
+	// the order of the insertion and the canonical term order, in every run
+	rules := `
+	r1 @ start ==> c(3), b(2), X < 2, a(1), Y > 1, c(1).
+	start, z(0).
+	`
+	for i := 0; i < 10; i++ {
+		for _, tc := range []struct {
+			order   StoreOrder
+			chr, bi string
+		}{
+			{OrderId, "[start, z(0), c(3), b(2), a(1), c(1)]", "[X1<2, 1<Y1]"},
+			{OrderTerm, "[a(1), b(2), c(1), c(3), start, z(0)]", "[1<Y1, X1<2]"},
+		} {
+			rs := MakeRuleStore()
+			rs.Order = tc.order
+			rs.ParseStringCHRRulesGoals(rules)
+			if chr2string(&rs.Session) != tc.chr || bi2string(&rs.Session) != tc.bi {
+				t.Errorf("TestOrder01 fails, order: %s, CHR-store: %s, built-in store: %s",
+					tc.order, chr2string(&rs.Session), bi2string(&rs.Session))
+			}
+		}
+	}
+}
+
+func TestOrder02(t *testing.T) {
+	rs := MakeRuleStore()
+	rs.Order = OrderId
+	rs.ParseStringCHRRulesGoals(`p(X) <=> X > 1 | q(X).`)
+	ok, result, err := rs.Infer([]string{"p(3)", "p(1)", "p(2)"})
+	if !ok || err != nil || strings.Join(result, ", ") != "p(1), q(3), q(2)" {
+		t.Errorf("TestOrder02 fails, result: %v", result)
+	}
+	for _, s := range []string{"none", "id", "term"} {
+		if o, err := ParseStoreOrder(s); err != nil || o.String() != s {
+			t.Errorf("TestOrder02 fails, order: %s", s)
+		}
+	}
+	if _, err := ParseStoreOrder("x"); err == nil {
+		t.Error("TestOrder02 fails, unknown order")
+	}
+}
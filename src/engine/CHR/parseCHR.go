@@ -224,6 +224,9 @@ func parseGoals(goals []string) (CList, error) {
 //
 // goals
 // <predicates> '.'
+//
+// A body or a goal-list may contain disjunctions of goal-lists
+// '(' <goals> ';' <goals> ... ')'; on the top level the parentheses can be omitted.
 func (rs *RuleStore) ParseStringCHRRulesGoals(src string) (ok bool) {
 	// src is the input that we want to tokenize.
 	// var s sc.Scanner
@@ -249,10 +252,29 @@ func (rs *RuleStore) ParseFileCHRRulesGoals(inFile io.Reader) (ok bool) {
 	return
 }
 
+// parse CHR-rules and goals from string src and add them to the rule store rs,
+// without clearing the rules or the CHR- and Built-In-store before.
+// New rules are appended to the rule store, new goals are added to the
+// current CHR-store and solved. solved is true, if src contains goals.
+func (rs *RuleStore) AddStringCHRRulesGoals(src string) (solved, ok bool) {
+	var s sc.Scanner
+	// Initialize the scanner.
+	s.Init(strings.NewReader(src))
+
+	s.Error = Err
+	return parseEvalRules1(rs, &s, true)
+}
+
 func parseEvalRules(rs *RuleStore, s *sc.Scanner) (ok bool) {
+	_, ok = parseEvalRules1(rs, s, false)
+	return
+}
+
+// parseEvalRules1 - if incremental, the rule store and the CHR-store of rs are kept
+func parseEvalRules1(rs *RuleStore, s *sc.Scanner, incremental bool) (solved, ok bool) {
 	var t Term
 	var rule *chrRule
-	var goals CList
+	var goals, lastGoals CList
 	newGoals := false
 	//        C O N I T I O N S         ||            R E S U L T
 	// newGoals | new rules | new goals || RuleStore | CHRStore  | newGoals
@@ -264,25 +286,32 @@ func parseEvalRules(rs *RuleStore, s *sc.Scanner) (ok bool) {
 	//   true   |   false   |   true    ||           | new goals |  true
 	//                                               | && solve  |
 
-	InitStore(rs)
+	if !incremental {
+		InitStore(rs)
+	}
 
-	nameNr := 1
+	nameNr := len(rs.prog.rules) + 1
 	tok := s.Scan()
 
-	TraceHeadln(4, 4, " parse rule tok: ", Tok2str(tok))
+	rs.Trace.Headln(4, 4, " parse rule tok: ", Tok2str(tok))
 	if tok == sc.EOF {
 		s.Error(s, " Empty input")
-		return false
+		return false, false
 	}
 
 	for tok != sc.EOF {
 		tok1 := s.Peek()
-		TraceHeadln(4, 4, " in loop parse rule tok: ", Tok2str(tok), ", tok1: [", Tok2str(tok1), "]")
+		rs.Trace.Headln(4, 4, " in loop parse rule tok: ", Tok2str(tok), ", tok1: [", Tok2str(tok1), "]")
 		switch tok {
-		case sc.Ident:
-			t, tok, ok = Factor_name(s.TokenText(), s, s.Scan())
+		case sc.Ident, '(':
+			if tok == '(' {
+				// a goal-list, beginning with a disjunction
+				t, tok, ok = Assignexpr(s, tok)
+			} else {
+				t, tok, ok = Factor_name(s.TokenText(), s, s.Scan())
+			}
 			if !ok {
-				return ok
+				return solved, ok
 			}
 			if tok == '@' {
 				tok, rule, goals, ok = parseKeepHead(rs, s, s.Scan(), t.String())
@@ -290,47 +319,52 @@ func parseEvalRules(rs *RuleStore, s *sc.Scanner) (ok bool) {
 				tok, rule, goals, ok = parseKeepHead1(rs, s, tok, fmt.Sprintf("(%d)", nameNr), t)
 				nameNr++
 			}
-			TraceHeadln(4, 4, " after parseKeep, rule", rule, ", goals: ", goals, "ok: ", ok)
+			rs.Trace.Headln(4, 4, " after parseKeep, rule", rule, ", goals: ", goals, "ok: ", ok)
 			if rule != nil {
-				if newGoals {
+				if newGoals && !incremental {
 					InitStore(rs)
-					rule.eMap = &EnvMap{InBinding: rs.emptyBinding, OutBindings: map[int]*EnvMap{}}
-					rs.CHRruleStore = []*chrRule{rule}
-					addRuleToPred2rule(rs, rule)
+					appendRule(rs, rule)
 					newGoals = false
 				} else {
-					rule.eMap = &EnvMap{InBinding: rs.emptyBinding, OutBindings: map[int]*EnvMap{}}
-					rs.CHRruleStore = append(rs.CHRruleStore, rule)
-					addRuleToPred2rule(rs, rule)
-					rs.nextRuleId++
+					appendRule(rs, rule)
+					rs.prog.nextRuleId++
+					if incremental {
+						// try the new rule with the constraints in the CHR-store
+						rs.state(rule).isOn = true
+					}
 				}
 			}
 			if goals != nil {
-				if newGoals {
+				lastGoals = goals
+				if newGoals && !incremental {
 					ClearCHRStore(rs)
 				} else {
 					newGoals = true
 				}
 
 				for _, g := range goals {
-					addRefConstraintToStore(rs, g)
+					addQuery(&rs.Session, g)
+					addRefConstraintToStore(&rs.Session, g)
 				}
 
-				CHRsolver(rs)
+				CHRsolver(&rs.Session)
+				solved = true
 
-				if CHRtrace == 0 {
-					printCHRStore(rs, "Result: ")
-					CHRtrace = 0
+				if rs.Trace.Level == 0 {
+					printCHRStore(&rs.Session, "Result: ")
+					rs.Trace.Level = 0
 				} else {
-					printCHRStore(rs, "Result: ")
+					printCHRStore(&rs.Session, "Result: ")
 				}
 			}
 
 		case '#':
 			tok = s.Scan()
 			if tok == sc.Ident {
-				switch s.TokenText() {
-				case "store", "result":
+				directive := s.TokenText()
+				switch directive {
+				case "store", "result", "bistore":
+					pos := s.Position
 					tok = s.Scan()
 					if tok == '=' || tok == ':' {
 						tok1 = s.Peek()
@@ -347,75 +381,41 @@ func parseEvalRules(rs *RuleStore, s *sc.Scanner) (ok bool) {
 					if tok == '.' {
 						tok = s.Scan()
 					}
-					compCHR := chr2List(rs)
-					chrOK := EqualVarNameCList(compCHR, t)
-					compBI := bi2List(rs)
-					biOK := EqualVarNameCList(compBI, t)
-					if chrOK || biOK {
+					check := checkExpectedStore(&rs.Session, directive, t)
+					if lastGoals != nil {
+						check.Goals = lastGoals.String()
+					}
+					check.Pos = pos
+					if rs.checkResults != nil {
+						*rs.checkResults = append(*rs.checkResults, check)
 						continue
 					}
-					if !chrOK && !biOK {
-						if compCHR == nil {
-							compCHR = compBI
-						} else {
-							if compBI != nil {
-								for _, bi := range compBI {
-									compCHR = append(compCHR, bi)
-								}
-							}
-						}
-						if t.Type() != ListType {
-							Err1(s, " exspected chr result (no List): '%s' \n !=computed chr result: '%s'", t, compCHR)
-							return false
-						}
-						lenCompCHR := len(compCHR)
-						if lenCompCHR != len(t.(List)) || lenCompCHR == 0 {
-							Err1(s, " exspected chr result: '%s' \n != len computed chr result: '%s'", t, compCHR)
-							return false
-						}
-						vec := make([]bool, lenCompCHR)
-						for _, c := range compCHR {
-							found := false
-							for i, e := range t.(List) {
-								if !vec[i] && EqualVarName(c, e) {
-									vec[i] = true
-									found = true
-									break
-								}
-							}
-							if !found {
-								Err1(s, " exspected chr result: '%s' \n != len computed chr result: '%s'", t, compCHR)
-								return false
-							}
-						}
+					if check.OK {
+						continue
 					}
-
-				case "bistore":
-					if tok == '=' {
-						tok = s.Scan()
-						if tok == '=' {
-							tok = s.Scan()
-						}
+					if t.Type() != ListType {
+						Err1(s, " exspected %s result (no List): '%s' \n !=computed %s result: '%s'", check.kind(), t, check.kind(), check.Computed)
+					} else {
+						Err1(s, " exspected %s result: '%s' \n != computed %s result: '%s'", check.kind(), t, check.kind(), check.Computed)
 					}
-					// read and compare exspected bi-result
-
+					return solved, false
 				}
 			}
 
 		default:
 			s.Error(s, fmt.Sprintf("Missing a rule-name, a predicate-name or a '#' at the beginning (not \"%v\")", Tok2str(tok)))
-			return false
+			return solved, false
 		}
 
 	}
-	return true
+	return solved, true
 }
 
 // parseKeepHead - it is not clear, a goal-list or a head-list
 // - name: the name of the rule
 func parseKeepHead(rs *RuleStore, s *sc.Scanner, tok rune, name string) (rune, *chrRule, CList, bool) {
 
-	TraceHeadln(4, 4, " parse Keep Head:", name, " tok: ", Tok2str(tok))
+	rs.Trace.Headln(4, 4, " parse Keep Head:", name, " tok: ", Tok2str(tok))
 
 	if tok != sc.Ident {
 		s.Error(s, fmt.Sprintf("Missing predicate-name in rule: %s (not \"%v\")", name, Tok2str(tok)))
@@ -444,21 +444,36 @@ func parseKeepHead1(rs *RuleStore, s *sc.Scanner, tok rune, name string, t Term)
 	//	}
 
 	keepList := List{t}
-	TraceHeadln(4, 4, " Head (in parseKeepHead1): ", t)
+	rs.Trace.Headln(4, 4, " Head (in parseKeepHead1): ", t)
 	for tok == ',' {
 		tok = s.Scan()
-		if tok != sc.Ident {
+		switch tok {
+		case sc.Ident:
+			t, tok, ok = Factor_name(s.TokenText(), s, s.Scan())
+		case '(':
+			// a disjunction in a goal-list
+			t, tok, ok = Assignexpr(s, tok)
+		default:
 			s.Error(s, fmt.Sprintf("Missing predicate-name in rule %s (not \"%v\")", name, Tok2str(tok)))
 			return tok, nil, nil, false
 		}
-		t, tok, ok = Factor_name(s.TokenText(), s, s.Scan())
 		if !ok {
 			return tok, nil, nil, ok
 		}
-		TraceHeadln(4, 4, " Head (in parseKeepHead1): ", t)
+		rs.Trace.Headln(4, 4, " Head (in parseKeepHead1): ", t)
 		keepList = append(keepList, t)
 	}
 
+	if tok == ';' {
+		// a goal-list with a disjunction
+		var d Term
+		d, tok, ok = Disjunction(s, keepList, tok)
+		if !ok {
+			return tok, nil, nil, false
+		}
+		keepList = List{d}
+	}
+
 	if tok == '.' {
 		// Goals-List
 		cGoalList, ok := prove2Clist(ParseGoal, name, keepList, s)
@@ -536,7 +551,6 @@ func parseKeepHead1(rs *RuleStore, s *sc.Scanner, tok rune, name string, t Term)
 
 func parseDelHead(s *sc.Scanner, tok rune) (delList List, tok1 rune, ok bool) {
 	var t Term
-	TraceHeadln(4, 4, "parse Del-Head tok[", Tok2str(tok))
 	delList = List{}
 	if tok != sc.Ident {
 		s.Error(s, fmt.Sprintf("Missing predicate-name (not \"%v\")", Tok2str(tok)))
@@ -566,16 +580,22 @@ func parseDelHead(s *sc.Scanner, tok rune) (delList List, tok1 rune, ok bool) {
 func parseGuardHead(rs *RuleStore, s *sc.Scanner, tok rune, name string, cKeepList, cDelList CList) (tok1 rune, rule *chrRule, goals CList, ok bool) {
 
 	bodyList, tok, ok := parseConstraints1(ParseRuleBody, s, tok)
-	TraceHead(4, 4, " parseGuardHead(1): ", bodyList, ", tok: '", Tok2str(tok), "'")
+	rs.Trace.Head(4, 4, " parseGuardHead(1): ", bodyList, ", tok: '", Tok2str(tok), "'")
+	if !ok {
+		return tok, nil, nil, false
+	}
 	cGuardList := CList{}
 	switch tok {
 	case '.':
 		tok = s.Scan()
 	case '|':
 		cGuardList, ok = prove2Clist(ParseBI, name, bodyList, s)
+		if !ok {
+			return tok, nil, nil, false
+		}
 		tok = s.Scan()
 		bodyList, tok, ok = parseConstraints1(ParseRuleBody, s, tok)
-		TraceHead(4, 4, " parseBodyHead(2): ", bodyList, ", tok: '", Tok2str(tok), "'")
+		rs.Trace.Head(4, 4, " parseBodyHead(2): ", bodyList, ", tok: '", Tok2str(tok), "'")
 		if !ok {
 			return tok, nil, nil, false
 		}
@@ -594,10 +614,9 @@ func parseGuardHead(rs *RuleStore, s *sc.Scanner, tok rune, name string, cKeepLi
 	//		body:     bodyList.(List)})
 	//	nextRuleId++
 
-	return tok, &chrRule{name: name, id: rs.nextRuleId,
+	return tok, &chrRule{name: name, id: rs.prog.nextRuleId,
 		delHead:  cDelList,
 		keepHead: cKeepList,
-		keepEnv:  makeKeepEnv(cKeepList),
 		guard:    cGuardList,
 		body:     bodyList.(List)}, nil, true
 }
@@ -634,19 +653,14 @@ func addChrRule(rs *RuleStore, s *sc.Scanner, name string, keepList, delList, gu
 	//		return errors.New(fmt.Sprintf("BODY in rule %s must be a List, not:  %s\n", name, bodyList))
 	//	}
 
-	r := &chrRule{name: name, id: rs.nextRuleId,
+	r := &chrRule{name: name, id: rs.prog.nextRuleId,
 		delHead:  cDelList,
 		keepHead: cKeepList,
-		keepEnv:  makeKeepEnv(cKeepList),
 		guard:    cGuardList,
-		body:     bodyList.(List),
-		eMap:     &EnvMap{InBinding: rs.emptyBinding, OutBindings: map[int]*EnvMap{}},
-		isOn:     false,
-		wasOn:    true}
-	rs.CHRruleStore = append(rs.CHRruleStore, r)
-	TraceHeadln(3, 3, " OFF rule: ", name, " (Add CHR-Rule) ")
-	addRuleToPred2rule(rs, r)
-	rs.nextRuleId++
+		body:     bodyList.(List)}
+	rs.Trace.Headln(3, 3, " OFF rule: ", name, " (Add CHR-Rule) ")
+	appendRule(rs, r)
+	rs.prog.nextRuleId++
 	return true
 }
 
@@ -658,7 +672,7 @@ func addStringGoals(rs *RuleStore, s *sc.Scanner, goals string) bool {
 	}
 	for _, g := range goalList.(List) {
 		if g.Type() == CompoundType {
-			addConstraintToStore(rs, g.(Compound))
+			addConstraintToStore(&rs.Session, g.(Compound))
 		} else {
 			CHRerr(s, " GOAL is not a predicate: %s\n", g)
 			return false
@@ -754,7 +768,7 @@ func prove2Clist(ty parseType, name string, t Term, s *sc.Scanner) (cl CList, ok
 			CHRerr(s, " unexpected boolean ", t, " in goal-list ")
 			return cl, false
 		}
-	case IntType:
+	case IntType, BigIntType:
 		switch ty {
 		case ParseHead:
 			CHRerr(s, " unexpected integer ", t, " in head of rule ", name)
@@ -815,6 +829,10 @@ func prove2Clist(ty parseType, name string, t Term, s *sc.Scanner) (cl CList, ok
 	case ListType:
 
 		for _, t1 := range t.(List) {
+			if ty != ParseGoal && isDisjunction(t1) {
+				CHRerr(s, " unexpected disjunction %s in rule %s", t1, name)
+				return cl, false
+			}
 			if ty == ParseHead && t1.Type() == VariableType {
 				t1 = Compound{Functor: "", Args: []Term{t1}}
 			} else {
@@ -848,12 +866,10 @@ func prove2Clist(ty parseType, name string, t Term, s *sc.Scanner) (cl CList, ok
 }
 
 func parseConstraints(ty parseType, s *sc.Scanner) (t Term, tok rune, ok bool) {
-	TraceHeadln(4, 4, " parse constraints ")
 	return parseConstraints1(ty, s, s.Scan())
 }
 
 func parseConstraints1(ty parseType, s *sc.Scanner, tok1 rune) (t Term, tok rune, ok bool) {
-	TraceHeadln(4, 4, " parse constraints ", Tok2str(tok1))
 	tok = tok1
 	if tok == sc.EOF {
 		return List{}, tok, true
@@ -882,8 +898,6 @@ func parseConstraints1(ty parseType, s *sc.Scanner, tok1 rune) (t Term, tok rune
 		}
 	}
 
-	TraceHeadln(4, 4, "<-- assing-expression: term: ", t.String(), ", tok: '", Tok2str(tok), "' ok: ", ok)
-
 	if tok == ',' {
 		t1 := List{t}
 		for tok == ',' {
@@ -910,21 +924,26 @@ func parseConstraints1(ty parseType, s *sc.Scanner, tok1 rune) (t Term, tok rune
 				}
 			}
 
-			TraceHeadln(4, 4, "<-- expression: term: %s tok: '%s' ok: %v \n", t.String(), Tok2str(tok), ok)
-
 			t1 = append(t1, t)
 		}
 		t = t1
 	} else {
 		t = List{t}
 	}
+	if tok == ';' {
+		if ty != ParseRuleBody && ty != ParseGoal {
+			s.Error(s, " A disjunction is only allowed in a rule body or a goal-list")
+			return t, tok, false
+		}
+		// the goal-list is the first alternative of a disjunction
+		t, tok, ok = Disjunction(s, t.(List), tok)
+		t = List{t}
+	}
 	return
 }
 
 func parseBIConstraint(s *sc.Scanner) (t Term, tok rune, ok bool) {
 
-	TraceHeadln(4, 4, "--> readBIConstraint : ")
-
 	tok = s.Scan()
 	if tok == sc.EOF {
 		return List{}, tok, true
@@ -932,8 +951,6 @@ func parseBIConstraint(s *sc.Scanner) (t Term, tok rune, ok bool) {
 
 	t, tok, ok = Assignexpr(s, tok)
 
-	TraceHeadln(4, 4, "<-- expression: term: %s tok: '%s' ok: %v \n", t.String(), Tok2str(tok), ok)
-
 	if t.Type() != CompoundType || t.(Compound).Prio == 0 {
 		s.Error(s, fmt.Sprintf(" Not a Built-in constraint: %s ", t))
 	}
@@ -946,8 +963,6 @@ func parseBIConstraint(s *sc.Scanner) (t Term, tok rune, ok bool) {
 		for tok == ',' {
 			t, tok, ok = Assignexpr(s, s.Scan())
 
-			TraceHeadln(4, 4, "<-- expression: term: %s tok: '%s' ok: %v ", t.String(), Tok2str(tok), ok)
-
 			if t.Type() != CompoundType || t.(Compound).Prio == 0 {
 				s.Error(s, fmt.Sprintf(" Not a Built-in constraint: %s ", t))
 			}
@@ -963,79 +978,23 @@ func parseBIConstraint(s *sc.Scanner) (t Term, tok rune, ok bool) {
 	return
 }
 
-func printCHRStore(rs *RuleStore, h string) {
+func printCHRStore(rs *Session, h string) {
 	switch rs.Result {
 	case REmpty:
 		if h != "New goal:" {
-			TraceHeadln(1, 0, h, " No rule fired (!)")
+			rs.Trace.Headln(1, 0, h, " No rule fired (!)")
 			return
 		}
 	case RFalse:
-		TraceHeadln(1, 0, h, " false (!)")
+		rs.Trace.Headln(1, 0, h, " false (!)")
 		return
 	case RTrue:
-		TraceHeadln(1, 0, h, " true (!)")
+		rs.Trace.Headln(1, 0, h, " true (!)")
 		return
 	}
 	// default: Result == RStore
-	first := true
-	for _, aChr := range rs.CHRstore {
-		for _, con := range aChr.varArg {
-			if con != nil && !con.IsDeleted {
-				if first {
-					TraceHead(1, 0, h, " CHR-Store: [", con.String())
-					first = false
-				} else {
-					Trace(1, ", ", con.String())
-				}
-			}
-		}
-		for _, con := range aChr.noArg {
-			if con != nil && !con.IsDeleted {
-				if first {
-					TraceHead(1, 0, h, " CHR-Store: [", con.String())
-					first = false
-				} else {
-					Trace(1, ", ", con.String())
-				}
-			}
-		}
-	}
-	if first {
-		TraceHeadln(1, 0, h, " CHR-Store: []")
-	} else {
-		Traceln(1, "]")
-	}
-
-	first = true
-	for _, aChr := range rs.BuiltInStore {
-		for _, con := range aChr.varArg {
-			if con != nil && !con.IsDeleted {
-				if first {
-					TraceHead(1, 0, h, " Built-In Store: [", con.String())
-					first = false
-				} else {
-					Trace(1, ", ", con.String())
-				}
-			}
-		}
-		for _, con := range aChr.noArg {
-			if con != nil && !con.IsDeleted {
-				if first {
-					TraceHead(1, 0, h, " Built-In Store: [", con.String())
-					first = false
-				} else {
-					Trace(1, ", ", con.String())
-				}
-			}
-		}
-	}
-	if first {
-		TraceHeadln(1, 0, h, " Built-In Store: []")
-	} else {
-		Traceln(1, "]")
-	}
-
+	rs.Trace.Headln(1, 0, h, " CHR-Store: ", clist2string(sortedStore(rs, rs.CHRstore)))
+	rs.Trace.Headln(1, 0, h, " Built-In Store: ", clist2string(sortedStore(rs, rs.BuiltInStore)))
 }
 
 func WriteCHRStore(rs *RuleStore, out *os.File) {
@@ -1053,125 +1012,72 @@ func WriteCHRStore(rs *RuleStore, out *os.File) {
 		return
 	}
 	// default: Result == RStore
-	first := true
-	for _, aChr := range rs.CHRstore {
-		for _, con := range aChr.varArg {
-			if con != nil && !con.IsDeleted {
-				if first {
-					fmt.Fprintf(out, "[%s", con.String())
-					first = false
-				} else {
-					fmt.Fprintf(out, ", %s", con.String())
-				}
-			}
-		}
-		for _, con := range aChr.noArg {
-			if con != nil && !con.IsDeleted {
-				if first {
-					fmt.Fprintf(out, "[%s", con.String())
-					first = false
-				} else {
-					fmt.Fprintf(out, ", %s", con.String())
-				}
-			}
-		}
-	}
-	if first {
-		fmt.Fprintf(out, "[]\n")
+	fmt.Fprintf(out, "%s\n", clist2string(sortedStore(&rs.Session, rs.CHRstore)))
+	fmt.Fprintf(out, "%s\n", clist2string(sortedStore(&rs.Session, rs.BuiltInStore)))
+}
 
-	} else {
-		fmt.Fprintf(out, "]\n")
+func WriteCHRRules(rs *RuleStore, out *os.File) {
+	for _, rule := range rs.prog.rules {
+		fmt.Fprintf(out, "%s\n", rule2string(rule))
 	}
+}
 
-	first = true
-	for _, aChr := range rs.BuiltInStore {
-		for _, con := range aChr.varArg {
-			if con != nil && !con.IsDeleted {
-				if first {
-					fmt.Fprintf(out, "[%s", con.String())
-					first = false
-				} else {
-					fmt.Fprintf(out, ", %s", con.String())
-				}
-			}
-		}
-		for _, con := range aChr.noArg {
-			if con != nil && !con.IsDeleted {
-				if first {
-					fmt.Fprintf(out, "[%s", con.String())
-					first = false
-				} else {
-					fmt.Fprintf(out, ", %s", con.String())
-				}
+// rule2string - the rule r in the syntax of the CHR-rules
+func rule2string(r *chrRule) (str string) {
+	heads := func(cl CList) string {
+		hl := []string{}
+		for _, h := range cl {
+			if h.Functor == "" && len(h.Args) == 1 {
+				// variable in head
+				hl = append(hl, h.Args[0].String())
+			} else {
+				hl = append(hl, h.String())
 			}
 		}
+		return strings.Join(hl, ", ")
 	}
-	if first {
-		fmt.Fprintf(out, "[]\n")
-	} else {
-		fmt.Fprintf(out, "]\n")
+	str = r.name + " @ "
+	switch {
+	case len(r.delHead) == 0:
+		str = str + heads(r.keepHead) + " ==> "
+	case len(r.keepHead) == 0:
+		str = str + heads(r.delHead) + " <=> "
+	default:
+		str = str + heads(r.keepHead) + " \\ " + heads(r.delHead) + " <=> "
 	}
-
+	if len(r.guard) != 0 {
+		str = str + heads(r.guard) + " | "
+	}
+	bl := []string{}
+	for _, b := range r.body {
+		bl = append(bl, b.String())
+	}
+	if len(bl) == 0 {
+		return str + "true."
+	}
+	return str + strings.Join(bl, ", ") + "."
 }
 
-func chr2CList(rs *RuleStore) (l CList) {
-	l = CList{}
+func chr2CList(rs *Session) (l CList) {
 	if rs.Result != RStore {
-		return
+		return CList{}
 	}
-	for _, aChr := range rs.CHRstore {
-		for _, con := range aChr.varArg {
-			if con != nil && !con.IsDeleted {
-				l = append(l, con)
-			}
-		}
-		for _, con := range aChr.noArg {
-			if con != nil && !con.IsDeleted {
-				l = append(l, con)
-			}
-		}
-	}
-	return
+	return sortedStore(rs, rs.CHRstore)
 }
 
-func bi2CList(rs *RuleStore) (l CList) {
-	l = CList{}
-	for _, aChr := range rs.BuiltInStore {
-		for _, con := range aChr.varArg {
-			if con != nil && !con.IsDeleted {
-				l = append(l, con)
-			}
-		}
-		for _, con := range aChr.noArg {
-			if con != nil && !con.IsDeleted {
-				l = append(l, con)
-			}
-		}
-	}
-	return
+func bi2CList(rs *Session) (l CList) {
+	return sortedStore(rs, rs.BuiltInStore)
 }
 
-func chr2List(rs *RuleStore) (l List) {
+func chr2List(rs *Session) (l List) {
 	l = List{}
-	if rs.Result != RStore {
-		return
-	}
-	for _, aChr := range rs.CHRstore {
-		for _, con := range aChr.varArg {
-			if con != nil && !con.IsDeleted {
-				l = append(l, *con)
-			}
-		}
-		for _, con := range aChr.noArg {
-			if con != nil && !con.IsDeleted {
-				l = append(l, *con)
-			}
-		}
+	for _, con := range chr2CList(rs) {
+		l = append(l, *con)
 	}
 	return
 }
 
-func bi2List(rs *RuleStore) (l List) {
+func bi2List(rs *Session) (l List) {
 	switch rs.Result {
 	case REmpty:
 		l = List{String("no rule fired")}
@@ -1180,79 +1086,27 @@ func bi2List(rs *RuleStore) (l List) {
 	case RTrue:
 		l = List{Bool(true)}
 	default:
-
 		l = List{}
-		for _, aChr := range rs.BuiltInStore {
-			for _, con := range aChr.varArg {
-				if con != nil && !con.IsDeleted {
-					l = append(l, *con)
-				}
-			}
+		for _, con := range bi2CList(rs) {
+			l = append(l, *con)
 		}
 	}
 	return
 }
 
-func chr2string(rs *RuleStore) (str string) {
-	first := true
-	for _, aChr := range rs.CHRstore {
-		for _, con := range aChr.varArg {
-			if con != nil && !con.IsDeleted {
-				if first {
-					str = "[" + con.String()
-					first = false
-				} else {
-					str = str + ", " + con.String()
-				}
-			}
-		}
-		for _, con := range aChr.noArg {
-			if con != nil && !con.IsDeleted {
-				if first {
-					str = "[" + con.String()
-					first = false
-				} else {
-					str = str + ", " + con.String()
-				}
-			}
-		}
-	}
-	if first {
-		str = "[]"
-	} else {
-		str = str + "]"
-	}
-	return
+func chr2string(rs *Session) (str string) {
+	return clist2string(sortedStore(rs, rs.CHRstore))
 }
 
-func bi2string(rs *RuleStore) (str string) {
-	first := true
-	for _, aChr := range rs.BuiltInStore {
-		for _, con := range aChr.varArg {
-			if con != nil && !con.IsDeleted {
-				if first {
-					str = "[" + con.String()
-					first = false
-				} else {
-					str = str + ", " + con.String()
-				}
-			}
-		}
-		for _, con := range aChr.noArg {
-			if con != nil && !con.IsDeleted {
-				if first {
-					str = "[" + con.String()
-					first = false
-				} else {
-					str = str + ", " + con.String()
-				}
-			}
-		}
+// clist2string - the constraints cl in the syntax of a list
+func clist2string(cl CList) string {
+	sl := make([]string, len(cl))
+	for i, con := range cl {
+		sl[i] = con.String()
 	}
-	if first {
-		str = "[]"
-	} else {
-		str = str + "]"
-	}
-	return
+	return "[" + strings.Join(sl, ", ") + "]"
+}
+
+func bi2string(rs *Session) (str string) {
+	return clist2string(sortedStore(rs, rs.BuiltInStore))
 }
@@ -0,0 +1,62 @@
+// Copyright © 2016 The Carneades Authors
+// This Source Code Form is subject to the terms of the
+// Mozilla Public License, v. 2.0. If a copy of the MPL
+// was not distributed with this file, You can obtain one
+// at http://mozilla.org/MPL/2.0/.
+
+// Program - the compiled rules, shared by the sessions of concurrent solver runs
+
+package chr
+
+// Program - the compiled rules of a rule store: the rules, the rule index
+// pred2rule, the hash index specifications and the registered built-in
+// functions. A program is immutable, many sessions can run it at the same time.
+type Program struct {
+	rules      []*chrRule
+	pred2rule  predicateRule
+	indexSpecs map[string][][]int
+	builtins   builtins
+	nextRuleId int
+}
+
+func newProgram() *Program {
+	return &Program{rules: []*chrRule{}, pred2rule: predicateRule{}, indexSpecs: map[string][][]int{}}
+}
+
+// Program returns a copy of the compiled rules of the rule store as an immutable
+// program. A later change of the rules of the rule store, e.g. by AddRule,
+// ParseStringCHRRulesGoals or RegisterBuiltin, does not change the program.
+// The compiled rules are not copied, they are not changed after the compilation.
+func (rs *RuleStore) Program() *Program {
+	p := rs.prog
+	p2r := predicateRule{}
+	for f, rIdx := range p.pred2rule {
+		p2r[f] = append([]*ruleIdx(nil), rIdx...)
+	}
+	specs := map[string][][]int{}
+	for f, pos := range p.indexSpecs {
+		specs[f] = append([][]int(nil), pos...)
+	}
+	return &Program{rules: append([]*chrRule{}, p.rules...), pred2rule: p2r,
+		indexSpecs: specs, builtins: copyBuiltins(p.builtins), nextRuleId: p.nextRuleId}
+}
+
+// NewSession returns a new session with empty stores for the rules of the program p.
+// The limits, the mode, the order and the trace of the session are the defaults of
+// MakeRuleStore.
+func (p *Program) NewSession() *Session {
+	s := &Session{prog: p}
+	clearSession(s)
+	return s
+}
+
+func copyBuiltins(bi builtins) builtins {
+	if bi == nil {
+		return nil
+	}
+	bi1 := make(builtins, len(bi))
+	for k, fn := range bi {
+		bi1[k] = fn
+	}
+	return bi1
+}
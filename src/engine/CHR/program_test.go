@@ -0,0 +1,103 @@
+// Copyright © 2016 The Carneades Authors
+// This Source Code Form is subject to the terms of the
+// Mozilla Public License, v. 2.0. If a copy of the MPL
+// was not distributed with this file, You can obtain one
+// at http://mozilla.org/MPL/2.0/.
+
+package chr
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+
+	. "github.com/hfried/GoCHR/src/engine/terms"
+)
+
+func TestProgram01(t *testing.T) {
+	// many sessions of one program in parallel goroutines
+	rs := MakeRuleStore()
+	rs.RegisterBuiltin("double", 1, func(args []Term) (Term, error) {
+		return Int(2 * args[0].(Int)), nil
+	})
+	if !rs.ParseStringCHRRulesGoals(succRules + `
+	gcd1 @ gcd(0) <=> true .
+	gcd2 @ gcd(N) \ gcd(M) <=> N <= M, L := M mod N | gcd(L).
+	dbl @ d(X) <=> Y := double(X) | e(Y).`) {
+		t.Fatal("TestProgram01 fails, parse rules")
+	}
+	p := rs.Program()
+	const n = 16
+	var wg sync.WaitGroup
+	errs := make(chan string, n)
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			s := p.NewSession()
+			s.Order = OrderTerm
+			if i%2 == 1 {
+				s.Mode = ModeRefined
+			}
+			for j := 0; j < 10; j++ {
+				goal := fmt.Sprintf("gcd(%d), gcd(%d), d(%d), add(X, Y, s(s(0))), add(Y, s(0), s(s(0)))",
+					6*(i+1), 4*(j+1), i)
+				g, _ := ParseGoalString(goal)
+				res, err := s.Solve(context.Background(), g)
+				if err != nil {
+					errs <- fmt.Sprintf("%s: %s", goal, err)
+					return
+				}
+				want := fmt.Sprintf("[e(%d), gcd(%d)]", 2*i, gcd(6*(i+1), 4*(j+1)))
+				if res.CHRStore.String() != want || res.Bindings["X"].String() != "s(0)" {
+					errs <- fmt.Sprintf("%s: %s %v", goal, res.CHRStore, res.Bindings)
+					return
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Errorf("TestProgram01 fails, %s", err)
+	}
+}
+
+func gcd(a, b int) int {
+	for b != 0 {
+		a, b = b, a%b
+	}
+	return a
+}
+
+func TestProgram02(t *testing.T) {
+	// a change of the rules of the rule store does not change the program
+	rs := MakeRuleStore()
+	rs.Order = OrderId
+	rs.ParseStringCHRRulesGoals(`r1 @ p(X) <=> q(X).`)
+	p := rs.Program()
+	rs.AddRule("r2", nil, []string{"q(X)"}, nil, []string{"r(X)"})
+	rs.RegisterBuiltin("one", 0, func(args []Term) (Term, error) { return Int(1), nil })
+	p2 := rs.Program()
+	rs.AddRule("r3", nil, []string{"r(X)"}, nil, []string{"s(X)"})
+
+	for _, tc := range []struct {
+		s    *Session
+		want string
+	}{
+		{p.NewSession(), "q(1), q(one)"},
+		{p2.NewSession(), "r(1), r(1)"},
+		{&rs.Session, "s(1), s(1)"},
+	} {
+		tc.s.Order = OrderId
+		ok, result, err := tc.s.Infer([]string{"p(1)", "p(one)"})
+		if !ok || err != nil || strings.Join(result, ", ") != tc.want {
+			t.Errorf("TestProgram02 fails, result: %v, expected: %s", result, tc.want)
+		}
+	}
+	if len(p.rules) != 1 || len(p.pred2rule) != 1 || len(p.builtins) != 0 || len(p2.rules) != 2 {
+		t.Errorf("TestProgram02 fails, program with %d rules", len(p.rules))
+	}
+}
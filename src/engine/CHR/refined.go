@@ -0,0 +1,338 @@
+// Copyright © 2016 The Carneades Authors
+// This Source Code Form is subject to the terms of the
+// Mozilla Public License, v. 2.0. If a copy of the MPL
+// was not distributed with this file, You can obtain one
+// at http://mozilla.org/MPL/2.0/.
+
+// Refined operational semantics (omega_r) of the CHR-solver
+
+package chr
+
+import (
+	"context"
+	"math/big"
+	"sort"
+	"time"
+
+	. "github.com/hfried/GoCHR/src/engine/terms"
+)
+
+type SolverMode int
+
+const (
+	// ModeDefault tries the rules of the rule store in textual order and
+	// restarts with the first rule after every firing
+	ModeDefault SolverMode = iota
+	// ModeRefined executes every new constraint as active constraint through
+	// its occurrences in textual order (refined operational semantics)
+	ModeRefined
+)
+
+func (m SolverMode) String() string {
+	switch m {
+	case ModeDefault:
+		return "default"
+	case ModeRefined:
+		return "refined"
+	}
+	return "unknown"
+}
+
+// occurrence of a constraint in the head 'head' of the rule 'rule',
+// the removed heads are numbered before the kept heads
+type occurrence struct {
+	rule *chrRule
+	head int
+}
+
+// a frame of the execution stack: an active constraint with the next
+// occurrence to try, or the remaining goals of a fired rule body
+type execFrame struct {
+	active *Compound
+	occ    int
+	rule   *chrRule
+	env    Bindings
+	rename *big.Int
+	goals  List
+	cause  *derivCause // the cause of the goals, if rs.Justify
+}
+
+type refinedSolver struct {
+	rs          *Session
+	stack       []*execFrame
+	occurrences map[string][]occurrence
+}
+
+// heads of the rule r, the removed heads first
+func ruleHeads(r *chrRule) CList {
+	heads := CList{}
+	heads = append(heads, r.delHead...)
+	return append(heads, r.keepHead...)
+}
+
+// the occurrences of the functor f in the rule store
+func (sv *refinedSolver) occurrencesOf(f string) []occurrence {
+	occ, ok := sv.occurrences[f]
+	if ok {
+		return occ
+	}
+	occ = []occurrence{}
+	for _, r := range sv.rs.prog.rules {
+		for i, h := range ruleHeads(r) {
+			if h.Functor == f {
+				occ = append(occ, occurrence{rule: r, head: i})
+			}
+		}
+	}
+	sv.occurrences[f] = occ
+	return occ
+}
+
+func (sv *refinedSolver) push(f *execFrame) {
+	sv.stack = append(sv.stack, f)
+}
+
+func (sv *refinedSolver) activate(c *Compound) {
+	sv.rs.Trace.Headln(2, 1, "activate ", c, " (Id: ", c.Id, ")")
+	sv.push(&execFrame{active: c})
+}
+
+// refinedCHRsolver takes the constraints, which are not activated up to now,
+// as goals and executes them in the order of their Id's
+func refinedCHRsolver(ctx context.Context, rs *Session, maxFirings int, deadline time.Time) error {
+	sv := &refinedSolver{rs: rs, stack: []*execFrame{}, occurrences: map[string][]occurrence{}}
+	if rs.hisIndex == nil {
+		rs.hisIndex = map[string][]hisRef{}
+	}
+	if rs.activeId == nil {
+		rs.activeId = big.NewInt(0)
+	}
+
+	goals := CList{}
+	for _, c := range chr2CList1(rs) {
+		if c.Id.Cmp(rs.activeId) >= 0 {
+			goals = append(goals, c)
+			markDeleted(rs, c)
+			delGoal1(rs, c, rs.CHRstore)
+		}
+	}
+	sort.Slice(goals, func(i, j int) bool { return goals[i].Id.Cmp(goals[j].Id) < 0 })
+	for i := len(goals) - 1; i >= 0; i-- {
+		g := CopyCompound(*goals[i])
+		g.IsDeleted = false
+		sv.push(&execFrame{goals: List{g}})
+	}
+
+	var err error
+	for len(sv.stack) > 0 && rs.Result != RFalse {
+		if err = checkLimits(ctx, rs, maxFirings, deadline); err != nil {
+			break
+		}
+		top := sv.stack[len(sv.stack)-1]
+		if top.active == nil {
+			sv.execGoal(top)
+		} else {
+			sv.activeStep(top)
+		}
+	}
+	rs.activeId = rs.chrCounter
+	return err
+}
+
+// execGoal executes the next goal of the frame f
+func (sv *refinedSolver) execGoal(f *execFrame) {
+	rs := sv.rs
+	if len(f.goals) == 0 {
+		sv.stack = sv.stack[:len(sv.stack)-1]
+		return
+	}
+	g := f.goals[0]
+	f.goals = f.goals[1:]
+	rs.cause = f.cause
+	if rs.Stats != nil && f.rule != nil {
+		defer rs.Stats.addTime(f.rule, time.Now())
+	}
+	if f.rule != nil {
+		rs.Trace.Head(3, 3, " Goal: ", g.String())
+		g = RenameAndSubstitute(g, f.rename, f.env)
+		rs.Trace.Traceln(3, " after rename&subst: ", g.String())
+		if isDisjunction(g) {
+			g, _ = rs.herbrand.resolve(g)
+			addDisjunction(rs, g.(Compound))
+			rs.Result = RStore
+			return
+		}
+		g = rs.eval(g)
+	}
+
+	switch g.Type() {
+	case CompoundType:
+		g1 := g.(Compound)
+		if g1.Prio == 0 {
+			var g0 *Compound
+			if g2, ok := rs.herbrand.resolve(g1); ok {
+				old := g1
+				g0 = &old
+				g1 = g2.(Compound)
+			}
+			if g1.Id == nil || f.rule != nil {
+				addRefConstraintToStore(rs, &g1)
+			} else {
+				// goal of the query, keep the Id
+				rs.herbrand.index(&g1)
+				addCHRGoal(rs, &g1)
+				if rs.Justify && g0 != nil {
+					rs.cause = substCause(g0, rs.herbrand.bindings(*g0))
+					justify(rs, &g1)
+					rs.cause = f.cause
+				}
+			}
+			if f.rule != nil {
+				rs.Result = RStore
+			}
+			sv.activate(&g1)
+			return
+		}
+		var eqEnv Bindings
+		if len(g1.Args) == 2 {
+			switch g1.Functor {
+			case ":=", "is", "=":
+				if g1.Args[0].Type() != VariableType {
+					rs.Trace.Headln(1, 3, "Missing Variable in assignment in body: ", g.String(), ", in rule:", f.rule.name)
+					rs.Result = RFalse
+					return
+				}
+				f.env = AddBinding(g1.Args[0].(Variable), g1.Args[1], f.env)
+			case "==":
+				g1, eqEnv = bodyEquation(g1, nil)
+			}
+		}
+		rs.Trace.Headln(3, 3, "Add Goal: ", g1)
+		addConstraintToStore(rs, g1)
+		rs.Result = RStore
+		if eqEnv != nil {
+			sv.reactivate(eqEnv)
+		}
+	case BoolType:
+		if !g.(Bool) {
+			rs.Result = RFalse
+		}
+	}
+}
+
+// reactivate binds the variables of the equations biEnv, substitutes them in the
+// constraints of the CHR-store with these variables and activates the changed
+// constraints again; the Id is kept
+func (sv *refinedSolver) reactivate(biEnv Bindings) {
+	rs := sv.rs
+	if rs.Tracer != nil {
+		rs.event(Event{Kind: EventStoreSubstituted}, biEnv)
+	}
+	h := rs.herbrand
+	changed := CList{}
+	for _, con := range h.wake(h.bind(biEnv)) {
+		con1, ok := h.resolve(*con)
+		if !ok {
+			continue
+		}
+		c := con1.(Compound)
+		markDeleted(rs, con)
+		delGoal1(rs, con, rs.CHRstore)
+		h.index(&c)
+		addCHRGoal(rs, &c)
+		if rs.Justify {
+			cause := rs.cause
+			rs.cause = substCause(con, h.bindings(*con))
+			justify(rs, &c)
+			rs.cause = cause
+		}
+		changed = append(changed, &c)
+	}
+	substituteDisjunctions(rs)
+	for i := len(changed) - 1; i >= 0; i-- {
+		rs.Trace.Headln(2, 1, "reactivate ", changed[i], " (Id: ", changed[i].Id, ")")
+		sv.push(&execFrame{active: changed[i]})
+	}
+}
+
+// activeStep tries the next occurrence of the active constraint of the frame f
+func (sv *refinedSolver) activeStep(f *execFrame) {
+	rs := sv.rs
+	c := f.active
+	occ := sv.occurrencesOf(c.Functor)
+	if c.IsDeleted || f.occ >= len(occ) {
+		sv.stack = sv.stack[:len(sv.stack)-1]
+		return
+	}
+	o := occ[f.occ]
+	r := o.rule
+	heads := ruleHeads(r)
+	partners := make(CList, len(heads))
+	partners[o.head] = c
+	if rs.Tracer != nil {
+		rs.event(Event{Kind: EventRuleTried, Rule: r.name, Constraint: c}, nil)
+	}
+	if rs.Stats != nil {
+		st := rs.Stats.rule(r)
+		st.Tries++
+		st.HeadMatches++
+		defer rs.Stats.addTime(r, time.Now())
+	}
+	env, ok := Match(*heads[o.head], *c, rs.emptyBinding)
+	if ok {
+		if rs.Tracer != nil {
+			rs.event(Event{Kind: EventHeadMatched, Rule: r.name, Head: heads[o.head], Constraint: c}, env)
+		}
+		env, ok = matchHeads(rs, r, r.occJoin[o.head], heads, partners, 0, env, nil)
+	}
+	if !ok {
+		f.occ++
+		return
+	}
+
+	// fire rule r
+	rs.Trace.Headln(1, 1, "rule ", r.name, " fired (id: ", r.id, ", active: ", c, ")")
+	rs.ruleFirings++
+	if rs.Tracer != nil {
+		rs.event(Event{Kind: EventRuleFired, Rule: r.name, Partners: partners}, env)
+	}
+	if rs.Stats != nil {
+		rs.Stats.fired(rs, r)
+	}
+	if len(r.delHead) == 0 {
+		addHistory(rs, r, partners)
+	}
+	for i := range r.delHead {
+		delConstraint(partners[i], rs)
+	}
+	if o.head < len(r.delHead) {
+		// the active constraint is removed
+		sv.stack = sv.stack[:len(sv.stack)-1]
+	}
+	rs.RenameRuleVars = newRenaming(rs)
+	goals := rule2goals(r, env)
+	if len(goals) == 0 {
+		// no body, nothing to do
+		return
+	}
+	f1 := &execFrame{rule: r, env: env, rename: rs.RenameRuleVars, goals: goals}
+	if rs.Justify {
+		f1.cause = ruleCause(rs, r, partners, env)
+	}
+	sv.push(f1)
+}
+
+// rule2goals - the goals of the body of the rule r, with the implicit equations of env
+func rule2goals(r *chrRule, env Bindings) List {
+	goals := List{}
+	g2, ok := GetImplicitEquals(env)
+	if ok {
+		goals = append(goals, g2...)
+	}
+	return append(goals, r.body...)
+}
+
+// chr2CList1 - the constraints of the CHR-store, independent of rs.Result
+func chr2CList1(rs *Session) CList {
+	return storeCList(rs.CHRstore)
+}
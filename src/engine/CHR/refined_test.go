@@ -0,0 +1,82 @@
+// Copyright © 2016 The Carneades Authors
+// This Source Code Form is subject to the terms of the
+// Mozilla Public License, v. 2.0. If a copy of the MPL
+// was not distributed with this file, You can obtain one
+// at http://mozilla.org/MPL/2.0/.
+
+package chr
+
+import (
+	"testing"
+
+	. "github.com/hfried/GoCHR/src/engine/terms"
+)
+
+func TestRefined01(t *testing.T) {
+	// the body constraint c is not in the store, when b is active
+	src := `
+	r1 @ a <=> b, c.
+	r2 @ b, c <=> ok.
+	r3 @ b <=> fail_b.
+	a.
+	`
+	rs := MakeRuleStore()
+	rs.Mode = ModeRefined
+	ok := rs.ParseStringCHRRulesGoals(src + "#result: fail_b, c.")
+	if !ok {
+		t.Error("TestRefined01 fails, refined mode")
+	}
+	rs = MakeRuleStore()
+	ok = rs.ParseStringCHRRulesGoals(src + "#result: ok.")
+	if !ok {
+		t.Error("TestRefined01 fails, default mode")
+	}
+}
+
+func TestRefined02(t *testing.T) {
+	// the occurrences are tried in textual order
+	rs := MakeRuleStore()
+	rs.Mode = ModeRefined
+	ok := rs.ParseStringCHRRulesGoals(`
+	gcd01 @ gcd(0) <=> true .
+	gcd02 @ gcd(N) \ gcd(M) <=> N <= M, L := M mod N | gcd(L).
+	gcd(94017), gcd(1155), gcd(2035).
+	#result: gcd(11) .
+	prime01 @ prime(N) ==> N>2 | prime(N-1).
+	prime02 @ prime(A) \ prime(B) <=> B > A, B mod A == 0 | true.
+	prime(20).
+	#result: prime(19), prime(17), prime(13), prime(11), prime(7), prime(5), prime(3), prime(2).
+	`)
+	if !ok {
+		t.Error("TestRefined02 fails")
+	}
+}
+
+func TestRefined03(t *testing.T) {
+	// p(1) and q(1) are reactivated after the binding of A and B
+	rs := MakeRuleStore()
+	rs.Mode = ModeRefined
+	ok := rs.ParseStringCHRRulesGoals(`
+	r1 @ p(X), q(X) <=> ok(X).
+	r2 @ go <=> p(A), q(B), A == 1, B == 1 .
+	go.
+	`)
+	if !ok || !EqualVarNameCList(chr2List(&rs.Session), List{Compound{Functor: "ok", Args: []Term{Int(1)}}}) {
+		t.Errorf("TestRefined03 fails, store: %s", chr2CList(&rs.Session))
+	}
+}
+
+func TestRefined04(t *testing.T) {
+	// a propagation rule fires only once for the same constraints
+	rs := MakeRuleStore()
+	rs.Mode = ModeRefined
+	ok := rs.ParseStringCHRRulesGoals(`
+	p1 @ p(X) ==> q(X).
+	p2 @ p(X), q(Y) ==> r(X, Y).
+	p(1), p(2).
+	#result: p(1), q(1), r(1,1), p(2), q(2), r(2,2), r(2,1), r(1,2).
+	`)
+	if !ok || rs.ruleFirings != 6 {
+		t.Errorf("TestRefined04 fails, rule firings: %d", rs.ruleFirings)
+	}
+}
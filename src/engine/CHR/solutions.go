@@ -0,0 +1,191 @@
+// Copyright © 2016 The Carneades Authors
+// This Source Code Form is subject to the terms of the
+// Mozilla Public License, v. 2.0. If a copy of the MPL
+// was not distributed with this file, You can obtain one
+// at http://mozilla.org/MPL/2.0/.
+
+// Solutions - iterator over all solutions of a disjunctive program
+
+package chr
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	. "github.com/hfried/GoCHR/src/engine/terms"
+)
+
+// SolutionOptions - the options of Solutions
+type SolutionOptions struct {
+	Max   int  // maximal number of solutions, 0 = all solutions
+	Dedup bool // skip a solution, if its stores are equal to an earlier solution up to variable renaming
+}
+
+// Solutions iterates over the solutions of the goals: the final stores of the
+// successful branches of the search tree, in the order of the chronological
+// backtracking. The search tree branches at the disjunctions and at the rule
+// choices: if a rule fires, the other rules, which are applicable in the same
+// stores, are alternatives of the rule (in ModeRefined the rules are committed
+// in the order of the refined semantics, only the disjunctions branch).
+// The iterator uses the stores of the rule store, the rule store must not be
+// used otherwise, until the iteration is finished.
+//
+//	it := rs.Solutions(ctx, SolutionOptions{Max: 10}, goals)
+//	for it.Next() {
+//		res := it.Result()
+//		...
+//	}
+//	if err := it.Err(); err != nil {
+//		...
+//	}
+type Solutions struct {
+	rs      *Session
+	ctx     context.Context
+	opts    SolutionOptions
+	goals   CList
+	started bool
+	done    bool
+	n       int    // number of solutions up to now
+	found   []List // canonical stores of the solutions, if opts.Dedup
+	res     *Result
+	err     error
+}
+
+// Solutions clears the CHR- and built-in-store and returns an iterator over the
+// solutions of the goals, see Solve for the goals and the errors of the solver.
+// The limits of the rule store (MaxRuleFirings, Timeout) apply to the search of
+// every solution.
+func (rs *Session) Solutions(ctx context.Context, opts SolutionOptions, goals ...Term) *Solutions {
+	it := &Solutions{rs: rs, ctx: ctx, opts: opts}
+	it.goals, it.err = goals2CList(goals)
+	if it.err != nil {
+		it.done = true
+	}
+	return it
+}
+
+// Next searches the next solution; false, if there is no further solution,
+// the maximal number of solutions is reached or the solver stopped with an error
+func (it *Solutions) Next() bool {
+	rs := it.rs
+	it.res = nil
+	for !it.done {
+		if it.opts.Max > 0 && it.n >= it.opts.Max {
+			break
+		}
+		if err := it.ctx.Err(); err != nil {
+			it.err = err
+			break
+		}
+		if !it.started {
+			it.started = true
+			setQuery(rs, it.goals)
+			rs.choices = nil
+		} else if !backtrack(rs) {
+			break
+		}
+		rs.ruleChoices = true
+		err := searchCHR(it.ctx, rs)
+		rs.ruleChoices = false
+		if err != nil {
+			it.err = err
+			break
+		}
+		if rs.Result == RFalse {
+			// all branches failed
+			break
+		}
+		res := storeResult(rs)
+		if it.opts.Dedup && it.isFound(res) {
+			rs.Trace.Headln(1, 1, "skip the duplicate solution ", res.CHRStore, ", ", res.BuiltInStore)
+			continue
+		}
+		it.n++
+		it.res = res
+		return true
+	}
+	it.done = true
+	rs.choices = nil
+	return false
+}
+
+// Result - the current solution, after Next returned true
+func (it *Solutions) Result() *Result {
+	return it.res
+}
+
+// Err - the error, which stopped the iteration
+func (it *Solutions) Err() error {
+	return it.err
+}
+
+// isFound - the stores of res are equal to the stores of an earlier solution
+// up to variable renaming; otherwise the stores of res are added to the found solutions
+func (it *Solutions) isFound(res *Result) bool {
+	stores := canonicalStores(res)
+	for _, f := range it.found {
+		if EqualVarNameCList(stores, f) {
+			return true
+		}
+	}
+	it.found = append(it.found, stores)
+	return false
+}
+
+// canonicalStores - the constraints of the CHR- and built-in-store of res in term
+// order, the renamed variables are replaced by _1, _2, ... in the order of
+// their first occurrence; the query variables are kept. The kind of the result
+// is the first element.
+func canonicalStores(res *Result) List {
+	type keyTerm struct {
+		key string
+		t   Compound
+	}
+	cl := []keyTerm{}
+	for _, c := range append(append(CList{}, res.CHRStore...), res.BuiltInStore...) {
+		cl = append(cl, keyTerm{key: canonicalVars(*c, nil).String(), t: *c})
+	}
+	sort.SliceStable(cl, func(i, j int) bool { return cl[i].key < cl[j].key })
+	names := map[string]Variable{}
+	stores := List{String(res.Kind.String())}
+	for _, c := range cl {
+		stores = append(stores, canonicalVars(c.t, names))
+	}
+	return stores
+}
+
+// canonicalVars replaces the renamed variables of t by the variables of names
+// or by new variables _1, _2, ...; if names is nil, by the variable _
+func canonicalVars(t Term, names map[string]Variable) Term {
+	switch t.Type() {
+	case CompoundType:
+		c := t.(Compound)
+		args := make([]Term, len(c.Args))
+		for i, a := range c.Args {
+			args[i] = canonicalVars(a, names)
+		}
+		return Compound{Functor: c.Functor, Prio: c.Prio, Args: args}
+	case ListType:
+		l := List{}
+		for _, e := range t.(List) {
+			l = append(l, canonicalVars(e, names))
+		}
+		return l
+	case VariableType:
+		v := t.(Variable)
+		if IsNewVariable(v) {
+			return v
+		}
+		if names == nil {
+			return NewVariable("_")
+		}
+		v1, ok := names[v.String()]
+		if !ok {
+			v1 = NewVariable(fmt.Sprintf("_%d", len(names)+1))
+			names[v.String()] = v1
+		}
+		return v1
+	}
+	return t
+}
@@ -0,0 +1,126 @@
+// Copyright © 2016 The Carneades Authors
+// This Source Code Form is subject to the terms of the
+// Mozilla Public License, v. 2.0. If a copy of the MPL
+// was not distributed with this file, You can obtain one
+// at http://mozilla.org/MPL/2.0/.
+
+package chr
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	. "github.com/hfried/GoCHR/src/engine/terms"
+)
+
+func solutions(it *Solutions) []string {
+	sols := []string{}
+	for it.Next() {
+		res := it.Result()
+		sols = append(sols, res.CHRStore.String()+" "+res.BuiltInStore.String())
+	}
+	return sols
+}
+
+func TestSolutions01(t *testing.T) {
+	// all solutions of X + Y = 2, the solution X = 1, Y = 1 is found twice
+	for _, mode := range []SolverMode{ModeDefault, ModeRefined} {
+		rs := MakeRuleStore()
+		rs.Mode = mode
+		rs.ParseStringCHRRulesGoals(succRules)
+		g, _ := ParseGoalString("add(X, Y, s(s(0)))")
+		sols := solutions(rs.Solutions(context.Background(), SolutionOptions{}, g))
+		if strings.Join(sols, "; ") != "[] [X==s(s(0)), Y==0]; [] [X==s(0), Y==s(0)]; "+
+			"[] [X==s(0), Y==s(0)]; [] [X==0, Y==s(s(0))]" {
+			t.Errorf("TestSolutions01 fails, mode: %s, solutions: %v", mode, sols)
+		}
+		it := rs.Solutions(context.Background(), SolutionOptions{Dedup: true}, g)
+		sols = solutions(it)
+		if it.Err() != nil || strings.Join(sols, "; ") != "[] [X==s(s(0)), Y==0]; [] [X==s(0), Y==s(0)]; "+
+			"[] [X==0, Y==s(s(0))]" {
+			t.Errorf("TestSolutions01 fails, mode: %s, dedup, solutions: %v, err: %v", mode, sols, it.Err())
+		}
+	}
+}
+
+func TestSolutions02(t *testing.T) {
+	// maximal number of solutions; no disjunction; no solution
+	rs := MakeRuleStore()
+	rs.ParseStringCHRRulesGoals(succRules)
+	g, _ := ParseGoalString("add(X, Y, s(s(0)))")
+	if sols := solutions(rs.Solutions(context.Background(), SolutionOptions{Max: 2}, g)); len(sols) != 2 {
+		t.Errorf("TestSolutions02 fails, max: 2, solutions: %v", sols)
+	}
+	// the rule choices of succ1 and succ2 have the same solution
+	g, _ = ParseGoalString("add(s(0), s(0), Z)")
+	sols := solutions(rs.Solutions(context.Background(), SolutionOptions{}, g))
+	for _, sol := range sols {
+		if sol != "[] [Z==s(s(0))]" {
+			t.Errorf("TestSolutions02 fails, solutions: %v", sols)
+		}
+	}
+	if sols := solutions(rs.Solutions(context.Background(), SolutionOptions{Dedup: true}, g)); len(sols) != 1 ||
+		sols[0] != "[] [Z==s(s(0))]" {
+		t.Errorf("TestSolutions02 fails, dedup, solutions: %v", sols)
+	}
+	g, _ = ParseGoalString("add(X, Y, s(0)), add(X, Y, s(s(0)))")
+	if sols := solutions(rs.Solutions(context.Background(), SolutionOptions{}, g)); len(sols) != 0 {
+		t.Errorf("TestSolutions02 fails, no solution: %v", sols)
+	}
+	// the rule store can be used again
+	res, err := rs.Solve(context.Background(), Compound{Functor: "add",
+		Args: []Term{Int(0), NewVariable("Y"), Int(2)}})
+	if err != nil || res.Bindings["Y"] != Int(2) {
+		t.Errorf("TestSolutions02 fails, Solve: %v, err: %v", res.Bindings, err)
+	}
+}
+
+func TestSolutions03(t *testing.T) {
+	// deduplication up to the renaming of the variables; errors
+	rs := MakeRuleStore()
+	rs.ParseStringCHRRulesGoals(`
+	p(N) <=> (q(N, A), r(A) ; q(N, B), r(B) ; q(N, C), r(N)).`)
+	g, _ := ParseGoalString("p(1)")
+	if sols := solutions(rs.Solutions(context.Background(), SolutionOptions{}, g)); len(sols) != 3 {
+		t.Errorf("TestSolutions03 fails, solutions: %v", sols)
+	}
+	if sols := solutions(rs.Solutions(context.Background(), SolutionOptions{Dedup: true}, g)); len(sols) != 2 {
+		t.Errorf("TestSolutions03 fails, dedup, solutions: %v", sols)
+	}
+	rs.MaxRuleFirings = 1
+	rs.ParseStringCHRRulesGoals(`loop(N) <=> (loop(N+1) ; loop(N+2)).`)
+	it := rs.Solutions(context.Background(), SolutionOptions{}, Compound{Functor: "loop", Args: []Term{Int(0)}})
+	if it.Next() || !errors.Is(it.Err(), ErrMaxRuleFirings) {
+		t.Errorf("TestSolutions03 fails, err: %v", it.Err())
+	}
+	it = rs.Solutions(context.Background(), SolutionOptions{}, Int(1))
+	if it.Next() || it.Err() == nil {
+		t.Error("TestSolutions03 fails, wrong goal")
+	}
+}
+
+func TestSolutions04(t *testing.T) {
+	// the overlapping search rules are alternatives, no disjunction
+	rs := MakeRuleStore()
+	rs.ParseStringCHRRulesGoals(`
+	zero @ add(X,Y,0) <=> X == 0, Y == 0 .
+	search1 @ add(X,Y,s(Z)) <=> X == s(X1), add(X1,Y,Z).
+	search2 @ add(X,Y,s(Z)) <=> Y == s(Y1), add(X,Y1,Z).`)
+	g, _ := ParseGoalString("add(X, Y, s(s(0)))")
+	sols := solutions(rs.Solutions(context.Background(), SolutionOptions{}, g))
+	if strings.Join(sols, "; ") != "[] [X==s(s(0)), Y==0]; [] [X==s(0), Y==s(0)]; "+
+		"[] [Y==s(0), X==s(0)]; [] [Y==s(s(0)), X==0]" {
+		t.Errorf("TestSolutions04 fails, solutions: %v", sols)
+	}
+	sols = solutions(rs.Solutions(context.Background(), SolutionOptions{Dedup: true}, g))
+	if strings.Join(sols, "; ") != "[] [X==s(s(0)), Y==0]; [] [X==s(0), Y==s(0)]; [] [Y==s(s(0)), X==0]" {
+		t.Errorf("TestSolutions04 fails, dedup, solutions: %v", sols)
+	}
+	// Solve commits to the first rule
+	res, err := rs.Solve(context.Background(), g)
+	if err != nil || res.BuiltInStore.String() != "[X==s(s(0)), Y==0]" {
+		t.Errorf("TestSolutions04 fails, Solve: %v, err: %v", res.BuiltInStore, err)
+	}
+}
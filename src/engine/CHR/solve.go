@@ -0,0 +1,127 @@
+// Copyright © 2016 The Carneades Authors
+// This Source Code Form is subject to the terms of the
+// Mozilla Public License, v. 2.0. If a copy of the MPL
+// was not distributed with this file, You can obtain one
+// at http://mozilla.org/MPL/2.0/.
+
+// Solve - the structured API of the CHR-solver
+
+package chr
+
+import (
+	"context"
+	"fmt"
+
+	. "github.com/hfried/GoCHR/src/engine/terms"
+)
+
+// Result of a call of Solve
+type Result struct {
+	Kind         ResultType      // REmpty, RStore, RTrue or RFalse
+	CHRStore     CList           // the remaining CHR-constraints
+	BuiltInStore CList           // the remaining built-in constraints
+	Bindings     map[string]Term // the values of the bound query variables
+}
+
+// Solve clears the CHR- and built-in-store, adds the goals and solves them with the
+// rules of the rule store. A goal is a CHR- or built-in constraint, a disjunction
+// (the compound ';' with goal-lists as arguments) or a list of them.
+// If the context ctx is done, a limit of the rule store (MaxRuleFirings, Timeout)
+// is exceeded or a built-in function failed, Solve returns the partial result and
+// the error (ctx.Err(), ErrMaxRuleFirings, ErrTimeout or *BuiltinError).
+// Solve returns the first solution of the disjunctions, see Solutions for all solutions.
+// The solver state, the renaming of the variables and the trace (rs.Trace) belong to
+// the rule store: different rule stores, e.g. the sessions of a Program, can solve in parallel goroutines,
+// one rule store must not be used by more than one goroutine at the same time.
+func (rs *Session) Solve(ctx context.Context, goals ...Term) (*Result, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	cGoals, err := goals2CList(goals)
+	if err != nil {
+		return nil, err
+	}
+	setQuery(rs, cGoals)
+	err = chrSolver(ctx, rs)
+
+	return storeResult(rs), err
+}
+
+// goals2CList copies the constraints of the goals
+func goals2CList(goals []Term) (CList, error) {
+	cGoals := CList{}
+	for _, g := range goals {
+		cl, err := goal2CList(g)
+		if err != nil {
+			return nil, err
+		}
+		cGoals = append(cGoals, cl...)
+	}
+	return cGoals, nil
+}
+
+// setQuery clears the CHR- and built-in-store and adds the goals
+func setQuery(rs *Session, goals CList) {
+	clearSession(rs)
+	for _, g := range goals {
+		addQuery(rs, g)
+		addRefConstraintToStore(rs, g)
+	}
+}
+
+// storeResult - the result of the current stores
+func storeResult(rs *Session) *Result {
+	return &Result{Kind: rs.Result, CHRStore: chr2CList(rs), BuiltInStore: bi2CList(rs),
+		Bindings: queryBindings(rs)}
+}
+
+// goal2CList copies the constraints of the goal g
+func goal2CList(g Term) (CList, error) {
+	switch g.Type() {
+	case CompoundType:
+		c := CopyCompound(g.(Compound))
+		return CList{&c}, nil
+	case ListType:
+		cl := CList{}
+		for _, e := range g.(List) {
+			cl1, err := goal2CList(e)
+			if err != nil {
+				return nil, err
+			}
+			cl = append(cl, cl1...)
+		}
+		return cl, nil
+	}
+	return nil, fmt.Errorf("goal must be a constraint or a list of constraints, not: %s", g)
+}
+
+// queryBindings - the values of the query variables, bound by the
+// equations ('==', ':=', 'is' and '=') of the built-in store
+func queryBindings(rs *Session) map[string]Term {
+	bindings := map[string]Term{}
+	if rs.Result == RFalse {
+		return bindings
+	}
+	var env Bindings
+	for _, c := range bi2CList(rs) {
+		if len(c.Args) != 2 {
+			continue
+		}
+		switch c.Functor {
+		case "==", ":=", "is", "=":
+			if c.Args[0].Type() == VariableType {
+				env = AddBinding(c.Args[0].(Variable), c.Args[1], env)
+			} else if c.Args[1].Type() == VariableType {
+				env = AddBinding(c.Args[1].(Variable), c.Args[0], env)
+			}
+		}
+	}
+	for _, v := range rs.QueryVars {
+		t := Substitute(v, env)
+		if t.Type() == VariableType && EqVars(v, t.(Variable)) {
+			continue
+		}
+		bindings[v.Name] = t
+	}
+	return bindings
+}
@@ -0,0 +1,140 @@
+// Copyright © 2016 The Carneades Authors
+// This Source Code Form is subject to the terms of the
+// Mozilla Public License, v. 2.0. If a copy of the MPL
+// was not distributed with this file, You can obtain one
+// at http://mozilla.org/MPL/2.0/.
+
+package chr
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	. "github.com/hfried/GoCHR/src/engine/parser"
+	. "github.com/hfried/GoCHR/src/engine/terms"
+)
+
+func solveGoal(t *testing.T, rules, goal string) *Result {
+	rs := MakeRuleStore()
+	if !rs.ParseStringCHRRulesGoals(rules) {
+		t.Fatalf("parse rules fails: %s", rules)
+	}
+	g, ok := ReadString(goal)
+	if !ok {
+		t.Fatalf("parse goal fails: %s", goal)
+	}
+	res, err := rs.Solve(context.Background(), g)
+	if err != nil {
+		t.Fatalf("Solve(%s) fails: %s", goal, err)
+	}
+	return res
+}
+
+func TestSolve01(t *testing.T) {
+	res := solveGoal(t, `sum([], S) <=> S == 0 .
+	sum([X|Xs], S) <=> sum(Xs, S2), S == X + S2.`, "sum([1,2,3,4,5,6,7,8,9,10], S)")
+	if res.Kind != RStore || len(res.CHRStore) != 0 {
+		t.Errorf("TestSolve01 fails, kind: %s, CHR-store: %s", res.Kind, res.CHRStore)
+	}
+	if s, ok := res.Bindings["S"]; !ok || !Equal(s, Int(55)) {
+		t.Errorf("TestSolve01 fails, bindings: %v", res.Bindings)
+	}
+}
+
+func TestSolve02(t *testing.T) {
+	res := solveGoal(t, `gcd1 @ gcd(0) <=> true .
+	gcd2 @ gcd(N) \ gcd(M) <=> N <= M, L := M mod N | gcd(L).`, "[gcd(94017), gcd(1155), gcd(2035)]")
+	if res.Kind != RStore || len(res.Bindings) != 0 || len(res.BuiltInStore) != 0 ||
+		len(res.CHRStore) != 1 || res.CHRStore[0].String() != "gcd(11)" {
+		t.Errorf("TestSolve02 fails, kind: %s, CHR-store: %s, bindings: %v", res.Kind, res.CHRStore, res.Bindings)
+	}
+}
+
+func TestSolve03(t *testing.T) {
+	res := solveGoal(t, `p(X) <=> X > 0 | false.`, "[p(1), q(A)]")
+	if res.Kind != RFalse || len(res.Bindings) != 0 {
+		t.Errorf("TestSolve03 fails, kind: %s, bindings: %v", res.Kind, res.Bindings)
+	}
+}
+
+func TestSolve04(t *testing.T) {
+	rs := MakeRuleStore()
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := rs.Solve(ctx, Atom("a")); err == nil {
+		t.Error("TestSolve04 fails, canceled context")
+	}
+	if _, err := rs.Solve(context.Background(), Int(1)); err == nil {
+		t.Error("TestSolve04 fails, goal 1 is not a constraint")
+	}
+}
+
+func TestSolve05(t *testing.T) {
+	rs := MakeRuleStore()
+	rs.ParseStringCHRRulesGoals(`nat(N) ==> nat(N+1).`)
+	rs.MaxRuleFirings = 10
+	res, err := rs.Solve(context.Background(), Compound{Functor: "nat", Args: []Term{Int(0)}})
+	if err != ErrMaxRuleFirings || res == nil || res.Kind != RStore || len(res.CHRStore) != 11 || rs.Err != err {
+		t.Errorf("TestSolve05 fails, err: %v, result: %v", err, res)
+	}
+
+	rs.MaxRuleFirings = 1 << 30
+	rs.Timeout = 10 * time.Millisecond
+	res, err = rs.Solve(context.Background(), Compound{Functor: "nat", Args: []Term{Int(0)}})
+	if err != ErrTimeout || res == nil || len(res.CHRStore) == 0 {
+		t.Errorf("TestSolve05 fails, err: %v, result: %v", err, res)
+	}
+
+	rs.Timeout = 0
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	res, err = rs.Solve(ctx, Compound{Functor: "nat", Args: []Term{Int(0)}})
+	if err != context.DeadlineExceeded || res == nil || len(res.CHRStore) == 0 {
+		t.Errorf("TestSolve05 fails, err: %v, result: %v", err, res)
+	}
+}
+
+func TestSolve06(t *testing.T) {
+	// independent rule stores in parallel goroutines, each with its own
+	// renaming of variables and its own trace
+	rules := `r1 @ start ==> X < 2, Y > 1, c(3).
+	gcd1 @ gcd(0) <=> true .
+	gcd2 @ gcd(N) \ gcd(M) <=> N <= M, L := M mod N | gcd(L).`
+	const n = 8
+	var wg sync.WaitGroup
+	chr := make([]string, n)
+	bi := make([]string, n)
+	trace := make([]bytes.Buffer, n)
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			rs := MakeRuleStore()
+			rs.Order = OrderId
+			rs.Trace.Out = &trace[i]
+			rs.Trace.Level = i % 2
+			rs.ParseStringCHRRulesGoals(rules)
+			for j := 0; j < 20; j++ {
+				g, _ := ParseGoalString("start, gcd(94017), gcd(1155), gcd(2035)")
+				if _, err := rs.Solve(context.Background(), g); err != nil {
+					t.Errorf("TestSolve06 fails, err: %s", err)
+					return
+				}
+			}
+			chr[i], bi[i] = chr2string(&rs.Session), bi2string(&rs.Session)
+		}(i)
+	}
+	wg.Wait()
+	for i := 0; i < n; i++ {
+		if chr[i] != "[start, c(3), gcd(11)]" || bi[i] != "[X1<2, 1<Y1]" {
+			t.Errorf("TestSolve06 fails, CHR-store: %s, built-in store: %s", chr[i], bi[i])
+		}
+		if traced := strings.Contains(trace[i].String(), "rule gcd2 fired"); traced != (i%2 == 1) {
+			t.Errorf("TestSolve06 fails, trace level: %d, trace: %q", i%2, trace[i].String())
+		}
+	}
+}
@@ -0,0 +1,123 @@
+// Copyright © 2016 The Carneades Authors
+// This Source Code Form is subject to the terms of the
+// Mozilla Public License, v. 2.0. If a copy of the MPL
+// was not distributed with this file, You can obtain one
+// at http://mozilla.org/MPL/2.0/.
+
+// Stats - rule firing statistics of the solver runs
+
+package chr
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"text/tabwriter"
+	"time"
+)
+
+// Stats - the statistics of the solver runs of a rule store, collected, if
+// RuleStore.Stats != nil; the counters are summed up over the solver runs
+//
+//	rs.Stats = &Stats{}
+//	rs.Solve(ctx, goals...)
+//	rs.Stats.WriteTable(os.Stdout)
+type Stats struct {
+	Runs       int           `json:"runs"`
+	Firings    int           `json:"firings"`
+	Renamings  int64         `json:"renamings"`   // number of the renamings of rule variables
+	PeakStore  int           `json:"peak_store"`  // maximal number of constraints in the CHR-store
+	FinalStore int           `json:"final_store"` // number of constraints in the CHR-store at the end of the last solver run
+	MeanStore  float64       `json:"mean_store"`  // mean number of constraints in the CHR-store of the samples
+	Samples    int           `json:"samples"`     // the CHR-store is sampled before every rule firing and at the end of every solver run
+	Time       time.Duration `json:"time_ns"`     // time of the solver runs
+	Rules      []RuleStats   `json:"rules"`       // in the order of the rule store
+}
+
+// RuleStats - the statistics of a rule
+type RuleStats struct {
+	Name          string        `json:"name"`
+	Tries         int           `json:"tries"`          // the rule was tried (ModeRefined: with an active constraint)
+	HeadMatches   int           `json:"head_matches"`   // attempts to match a head with a constraint
+	GuardFailures int           `json:"guard_failures"` // failed guards
+	Firings       int           `json:"firings"`
+	Time          time.Duration `json:"time_ns"` // time of the trials, the firings and the bodies of the rule
+}
+
+// the state of the statistics at the start of a solver run
+type statsRun struct {
+	start     time.Time
+	renamings int64
+}
+
+// startRun - a solver run of rs starts; the rules of rs get their statistics
+func (st *Stats) startRun(rs *Session) statsRun {
+	for len(st.Rules) < len(rs.prog.rules) {
+		st.Rules = append(st.Rules, RuleStats{Name: rs.prog.rules[len(st.Rules)].name})
+	}
+	return statsRun{start: time.Now(), renamings: rs.renamings}
+}
+
+// endRun - the solver run of rs, started with sr, ends
+func (st *Stats) endRun(rs *Session, sr statsRun) {
+	st.Runs++
+	st.Time += time.Since(sr.start)
+	st.Renamings += rs.renamings - sr.renamings
+	st.sampleStore(rs)
+	st.FinalStore = rs.chrSize
+}
+
+func (st *Stats) rule(r *chrRule) *RuleStats {
+	for len(st.Rules) <= r.pos {
+		st.Rules = append(st.Rules, RuleStats{})
+	}
+	rst := &st.Rules[r.pos]
+	rst.Name = r.name
+	return rst
+}
+
+// fired - the rule r fires
+func (st *Stats) fired(rs *Session, r *chrRule) {
+	st.Firings++
+	st.rule(r).Firings++
+	st.sampleStore(rs)
+}
+
+// addTime adds the time since start to the rule r
+func (st *Stats) addTime(r *chrRule, start time.Time) {
+	st.rule(r).Time += time.Since(start)
+}
+
+// sampleStore adds the size of the CHR-store of rs to the peak and the mean
+func (st *Stats) sampleStore(rs *Session) {
+	n := rs.chrSize
+	st.Samples++
+	st.MeanStore += (float64(n) - st.MeanStore) / float64(st.Samples)
+	if n > st.PeakStore {
+		st.PeakStore = n
+	}
+}
+
+// WriteTable writes the statistics as a table, a line for every rule
+func (st *Stats) WriteTable(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	fmt.Fprintf(tw, "rule\ttries\thead matches\tguard failures\tfirings\ttime\n")
+	for _, r := range st.Rules {
+		fmt.Fprintf(tw, "%s\t%d\t%d\t%d\t%d\t%s\n", r.Name, r.Tries, r.HeadMatches, r.GuardFailures,
+			r.Firings, r.Time.Round(time.Microsecond))
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	_, err := fmt.Fprintf(w, "runs: %d, firings: %d, renamings: %d, peak store: %d, final store: %d, "+
+		"mean store: %.1f, time: %s\n", st.Runs, st.Firings, st.Renamings, st.PeakStore, st.FinalStore,
+		st.MeanStore, st.Time.Round(time.Microsecond))
+	return err
+}
+
+// WriteJSON writes the statistics as JSON-object
+func (st *Stats) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(st)
+}
@@ -0,0 +1,120 @@
+// Copyright © 2016 The Carneades Authors
+// This Source Code Form is subject to the terms of the
+// Mozilla Public License, v. 2.0. If a copy of the MPL
+// was not distributed with this file, You can obtain one
+// at http://mozilla.org/MPL/2.0/.
+
+package chr
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func statsGoal(t *testing.T, mode SolverMode, runs int) *Stats {
+	rs := MakeRuleStore()
+	rs.Mode = mode
+	if !rs.ParseStringCHRRulesGoals(gcdRules) {
+		t.Fatal("parse rules fails")
+	}
+	rs.Stats = &Stats{}
+	for i := 0; i < runs; i++ {
+		g, _ := ParseGoalString("gcd(9), gcd(6), p(Y), q(Y)")
+		if _, err := rs.Solve(context.Background(), g); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return rs.Stats
+}
+
+// ruleCounters - name tries head-matches guard-failures firings of the rules
+func ruleCounters(st *Stats) string {
+	rules := []string{}
+	for _, r := range st.Rules {
+		rules = append(rules, fmt.Sprintf("%s %d %d %d %d", r.Name, r.Tries, r.HeadMatches, r.GuardFailures, r.Firings))
+	}
+	return strings.Join(rules, ", ")
+}
+
+func TestStats01(t *testing.T) {
+	// the counters of both modes, summed up over two runs
+	for _, tc := range []struct {
+		mode  SolverMode
+		runs  int
+		rules string
+		sizes string // peak, final, mean store and samples
+	}{
+		// the sizes of the samples: 4 4 4 3 2
+		{ModeDefault, 1, "gcd1 4 1 0 1, gcd2 3 9 2 2, eq 2 1 0 1", "4 2 3.4 5"},
+		{ModeDefault, 2, "gcd1 8 2 0 2, gcd2 6 18 4 4, eq 4 2 0 2", "4 2 3.4 10"},
+		{ModeRefined, 1, "gcd1 4 4 0 1, gcd2 7 11 2 2, eq 1 1 0 1", "2 2 2.0 5"},
+	} {
+		st := statsGoal(t, tc.mode, tc.runs)
+		sizes := fmt.Sprintf("%d %d %.1f %d", st.PeakStore, st.FinalStore, st.MeanStore, st.Samples)
+		if ruleCounters(st) != tc.rules || sizes != tc.sizes ||
+			st.Runs != tc.runs || st.Firings != 4*tc.runs || st.Renamings == 0 || st.Time <= 0 {
+			t.Errorf("TestStats01 fails, mode: %s, runs: %d, rules: %s, sizes: %s, stats: %+v",
+				tc.mode, tc.runs, ruleCounters(st), sizes, st)
+		}
+		for _, r := range st.Rules {
+			if r.Time <= 0 || r.Time > st.Time {
+				t.Errorf("TestStats01 fails, mode: %s, time of %s: %s", tc.mode, r.Name, r.Time)
+			}
+		}
+	}
+}
+
+func TestStats02(t *testing.T) {
+	// table and JSON
+	st := statsGoal(t, ModeDefault, 1)
+	var out bytes.Buffer
+	if err := st.WriteTable(&out); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(out.String(), "\n")
+	if len(lines) != 6 || !strings.HasPrefix(lines[0], "rule  tries  head matches  guard failures  firings  time") ||
+		!strings.HasPrefix(lines[2], "gcd2  3      9             2               2        ") ||
+		!strings.HasPrefix(lines[4], "runs: 1, firings: 4, renamings: 9, peak store: 4, final store: 2, "+
+			"mean store: 3.4, time: ") {
+		t.Errorf("TestStats02 fails, table:\n%s", out.String())
+	}
+
+	out.Reset()
+	if err := st.WriteJSON(&out); err != nil {
+		t.Fatal(err)
+	}
+	var st2 Stats
+	if err := json.Unmarshal(out.Bytes(), &st2); err != nil {
+		t.Fatalf("TestStats02 fails, JSON: %s, err: %s", out.String(), err)
+	}
+	if ruleCounters(&st2) != ruleCounters(st) || st2.PeakStore != 4 || st2.Time != st.Time ||
+		!strings.Contains(out.String(), `"guard_failures": 2`) {
+		t.Errorf("TestStats02 fails, JSON: %s", out.String())
+	}
+}
+
+func TestStats03(t *testing.T) {
+	// the counted size of the CHR-store is the number of its constraints, after
+	// deletions, substitutions, disjunctions and the reactivation (ModeRefined)
+	for _, mode := range []SolverMode{ModeDefault, ModeRefined} {
+		for _, prog := range []string{
+			gcdRules + "gcd(9), gcd(6), p(Y), q(Y).",
+			succRules + "add(X, Y, s(s(0))), add(s(0), Z, X).",
+			"d(X) <=> (X == 1 ; X == 2).\np(X), q(X) <=> X > 1 | r(X).\nd(A), p(A), q(A), p(B).",
+			"t(X) ==> X == 3 .\nt(Y), t(Z), u(Y+1).",
+		} {
+			rs := MakeRuleStore()
+			rs.Mode = mode
+			rs.Stats = &Stats{}
+			rs.ParseStringCHRRulesGoals(prog)
+			if n := len(chr2CList(&rs.Session)); rs.chrSize != n || rs.Stats.FinalStore != n {
+				t.Errorf("TestStats03 fails, mode: %s, size: %d, final store: %d, store: %s, program: %s",
+					mode, rs.chrSize, rs.Stats.FinalStore, chr2string(&rs.Session), prog)
+			}
+		}
+	}
+}
@@ -0,0 +1,588 @@
+// Copyright © 2016 The Carneades Authors
+// This Source Code Form is subject to the terms of the
+// Mozilla Public License, v. 2.0. If a copy of the MPL
+// was not distributed with this file, You can obtain one
+// at http://mozilla.org/MPL/2.0/.
+
+// Standard library of built-in functions, called in guards and bodies
+
+package chr
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	. "github.com/hfried/GoCHR/src/engine/terms"
+)
+
+const StdlibHelp = `The standard built-in functions are:
+
+math:
+abs(X)              - the absolute value of the number X
+min(X, Y)           - the smaller of the numbers X and Y
+max(X, Y)           - the greater of the numbers X and Y
+sqrt(X)             - the square root of X >= 0, a float-number
+pow(X, Y)           - X to the power of Y; exact for an integer or rational X and an integer Y
+floor(X), ceil(X)   - the greatest integer <= X, the least integer >= X
+round(X)            - the nearest integer, half away from zero
+
+type conversions:
+int(X)              - the number or the string X as integer, truncated towards zero
+float(X)            - the number or the string X as float-number
+string(X)           - the term X as string
+
+strings:
+len(S)              - the number of characters of the string S
+concat(S1, S2)      - the concatenation of S1 and S2 (other terms than strings as printed)
+substr(S, I, N)     - the N characters of S from the index I (the first character has the index 0)
+upper(S), lower(S)  - S in upper case, in lower case
+contains(S, Sub)    - true, if S contains Sub
+split(S, Sep)       - the list of the substrings of S separated by Sep
+
+lists:
+length(L)           - the number of elements of the list L
+nth(L, I)           - the element with the index I of L (the first element has the index 0)
+append(L1, L2)      - the concatenation of the lists L1 and L2
+reverse(L)          - the elements of L in reverse order
+member(X, L)        - true, if X is an element of L
+sum(L)              - the sum of the numbers of L
+sort(L)             - the elements of L sorted: numbers by value, strings alphabetically,
+                      other terms as printed
+
+type tests:
+is_int(X)           - true, if X is an integer
+is_atom(X)          - true, if X is an atom
+is_list(X)          - true, if X is a list
+ground(X)           - true, if X has no variables
+var(X), nonvar(X)   - true, if X is (is not) an unbound variable
+
+A function is called, when its arguments are ground (the type tests with any
+arguments). A failed call, e.g. sqrt(-1) or nth([a], 1), stops the solver with an
+error. A call with arguments of another type, e.g. max(X, a) or length(1), is not
+evaluated, the term is kept as CHR-constraint. A function registered with RegisterBuiltin replaces the standard function
+with the same name and arity, a CHR-constraint in a rule head hides it.`
+
+// stdBuiltin - a standard built-in function; a type test is called with not ground arguments
+type stdBuiltin struct {
+	fn       BuiltinFunc
+	typeTest bool
+}
+
+// stdlib - the standard built-in functions, see StdlibHelp
+var stdlib = map[builtinKey]stdBuiltin{
+	{"abs", 1}:      {fn: stdAbs},
+	{"min", 2}:      {fn: stdMin},
+	{"max", 2}:      {fn: stdMax},
+	{"sqrt", 1}:     {fn: stdSqrt},
+	{"pow", 2}:      {fn: stdPow},
+	{"floor", 1}:    {fn: stdFloor},
+	{"ceil", 1}:     {fn: stdCeil},
+	{"round", 1}:    {fn: stdRound},
+	{"int", 1}:      {fn: stdInt},
+	{"float", 1}:    {fn: stdFloat},
+	{"string", 1}:   {fn: stdString},
+	{"len", 1}:      {fn: stdLen},
+	{"concat", 2}:   {fn: stdConcat},
+	{"substr", 3}:   {fn: stdSubstr},
+	{"upper", 1}:    {fn: stdUpper},
+	{"lower", 1}:    {fn: stdLower},
+	{"contains", 2}: {fn: stdContains},
+	{"split", 2}:    {fn: stdSplit},
+	{"length", 1}:   {fn: stdLength},
+	{"nth", 2}:      {fn: stdNth},
+	{"append", 2}:   {fn: stdAppend},
+	{"reverse", 1}:  {fn: stdReverse},
+	{"member", 2}:   {fn: stdMember},
+	{"sum", 1}:      {fn: stdSum},
+	{"sort", 1}:     {fn: stdSort},
+	{"is_int", 1}:   {fn: stdIsInt, typeTest: true},
+	{"is_atom", 1}:  {fn: stdIsAtom, typeTest: true},
+	{"is_list", 1}:  {fn: stdIsList, typeTest: true},
+	{"ground", 1}:   {fn: stdGround, typeTest: true},
+	{"var", 1}:      {fn: stdVar, typeTest: true},
+	{"nonvar", 1}:   {fn: stdNonvar, typeTest: true},
+}
+
+// hideStdlib hides the standard built-in functions with the name and arity of a head
+// of the rule r: they are registered without a function, see builtins.call
+func hideStdlib(rs *RuleStore, r *chrRule) {
+	for _, heads := range []CList{r.keepHead, r.delHead} {
+		for _, h := range heads {
+			key := builtinKey{h.Functor, len(h.Args)}
+			if _, ok := stdlib[key]; !ok {
+				continue
+			}
+			if _, ok := rs.prog.builtins[key]; ok {
+				continue
+			}
+			if rs.prog.builtins == nil {
+				rs.prog.builtins = builtins{}
+			}
+			rs.prog.builtins[key] = nil
+		}
+	}
+}
+
+var (
+	errNoNumber  = errors.New("no number")
+	errNoInteger = errors.New("no integer")
+	errNoString  = errors.New("no string")
+	errNoList    = errors.New("no list")
+	errRange     = errors.New("index out of range")
+)
+
+// isTypeError - err is the error of an argument of another type than the standard
+// built-in function expects
+func isTypeError(err error) bool {
+	return err == errNoNumber || err == errNoInteger || err == errNoString || err == errNoList
+}
+
+func isNumber(t Term) bool {
+	typ := t.Type()
+	return isExact(typ) || typ == FloatType
+}
+
+// text - the text of the string t without the quotes
+func text(t Term) (string, error) {
+	s, ok := t.(String)
+	if !ok {
+		return "", errNoString
+	}
+	if u, err := strconv.Unquote(string(s)); err == nil {
+		return u, nil
+	}
+	return string(s), nil
+}
+
+// newString - the string term with the text s
+func newString(s string) String {
+	return String(strconv.Quote(s))
+}
+
+func list(t Term) (List, error) {
+	l, ok := t.(List)
+	if !ok {
+		return nil, errNoList
+	}
+	return l, nil
+}
+
+func index(t Term) (int, error) {
+	i, ok := t.(Int)
+	if !ok {
+		return 0, errNoInteger
+	}
+	return int(i), nil
+}
+
+// floatInt - the integer value of a finite float-number
+func floatInt(f float64) (Term, error) {
+	if math.IsInf(f, 0) || math.IsNaN(f) {
+		return nil, fmt.Errorf("no finite number: %v", f)
+	}
+	if f >= math.MinInt && f < math.MaxInt {
+		return Int(f), nil
+	}
+	i, _ := big.NewFloat(f).Int(nil)
+	return NewBigInt(i), nil
+}
+
+// ratInt - the truncated quotient of the rational number r, rounded away from zero,
+// if up(quotient, remainder)
+func ratInt(r *big.Rat, up func(q, m *big.Int) bool) Term {
+	q, m := new(big.Int).QuoRem(r.Num(), r.Denom(), new(big.Int))
+	if up(q, m) {
+		if r.Sign() < 0 {
+			q.Sub(q, big.NewInt(1))
+		} else {
+			q.Add(q, big.NewInt(1))
+		}
+	}
+	return NewBigInt(q)
+}
+
+// math
+
+func stdAbs(args []Term) (Term, error) {
+	switch a := args[0].(type) {
+	case Int:
+		if a < 0 {
+			return evalUnaryMinus(nil, a, IntType), nil
+		}
+		return a, nil
+	case Float:
+		return Float(math.Abs(float64(a))), nil
+	case BigInt:
+		return NewBigInt(new(big.Int).Abs(a.Big())), nil
+	case Rational:
+		return NewRational(new(big.Rat).Abs(a.Rat())), nil
+	}
+	return nil, errNoNumber
+}
+
+func stdMin(args []Term) (Term, error) {
+	if !isNumber(args[0]) || !isNumber(args[1]) {
+		return nil, errNoNumber
+	}
+	if cmpNumbers(args[1], args[0]) < 0 {
+		return args[1], nil
+	}
+	return args[0], nil
+}
+
+func stdMax(args []Term) (Term, error) {
+	if !isNumber(args[0]) || !isNumber(args[1]) {
+		return nil, errNoNumber
+	}
+	if cmpNumbers(args[1], args[0]) > 0 {
+		return args[1], nil
+	}
+	return args[0], nil
+}
+
+func stdSqrt(args []Term) (Term, error) {
+	if !isNumber(args[0]) {
+		return nil, errNoNumber
+	}
+	f := toFloat(args[0])
+	if f < 0 {
+		return nil, errors.New("negative number")
+	}
+	return Float(math.Sqrt(f)), nil
+}
+
+func stdPow(args []Term) (Term, error) {
+	x, y := args[0], args[1]
+	if !isNumber(x) || !isNumber(y) {
+		return nil, errNoNumber
+	}
+	n, ok := y.(Int)
+	if !ok || !isExact(x.Type()) {
+		return Float(math.Pow(toFloat(x), toFloat(y))), nil
+	}
+	if n < 0 && isZero(x) {
+		return nil, errors.New("division by zero")
+	}
+	r := bigRat(x)
+	e := big.NewInt(int64(n))
+	e.Abs(e)
+	num := new(big.Int).Exp(r.Num(), e, nil)
+	den := new(big.Int).Exp(r.Denom(), e, nil)
+	if n < 0 {
+		num, den = den, num
+	}
+	return NewRational(new(big.Rat).SetFrac(num, den)), nil
+}
+
+func stdFloor(args []Term) (Term, error) {
+	switch a := args[0].(type) {
+	case Int, BigInt:
+		return a, nil
+	case Float:
+		return floatInt(math.Floor(float64(a)))
+	case Rational:
+		return ratInt(a.Rat(), func(q, m *big.Int) bool { return m.Sign() < 0 }), nil
+	}
+	return nil, errNoNumber
+}
+
+func stdCeil(args []Term) (Term, error) {
+	switch a := args[0].(type) {
+	case Int, BigInt:
+		return a, nil
+	case Float:
+		return floatInt(math.Ceil(float64(a)))
+	case Rational:
+		return ratInt(a.Rat(), func(q, m *big.Int) bool { return m.Sign() > 0 }), nil
+	}
+	return nil, errNoNumber
+}
+
+func stdRound(args []Term) (Term, error) {
+	switch a := args[0].(type) {
+	case Int, BigInt:
+		return a, nil
+	case Float:
+		return floatInt(math.Round(float64(a)))
+	case Rational:
+		r := a.Rat()
+		return ratInt(r, func(q, m *big.Int) bool {
+			// 2 * |m| >= denominator
+			m2 := new(big.Int).Lsh(new(big.Int).Abs(m), 1)
+			return m2.Cmp(r.Denom()) >= 0
+		}), nil
+	}
+	return nil, errNoNumber
+}
+
+// type conversions
+
+func stdInt(args []Term) (Term, error) {
+	switch a := args[0].(type) {
+	case Int, BigInt:
+		return a, nil
+	case Float:
+		return floatInt(math.Trunc(float64(a)))
+	case Rational:
+		return ratInt(a.Rat(), func(q, m *big.Int) bool { return false }), nil
+	case String:
+		s, _ := text(a)
+		i, ok := new(big.Int).SetString(strings.TrimSpace(s), 0)
+		if !ok {
+			return nil, fmt.Errorf("no integer: %s", a)
+		}
+		return NewBigInt(i), nil
+	}
+	return nil, errNoNumber
+}
+
+func stdFloat(args []Term) (Term, error) {
+	switch a := args[0].(type) {
+	case Int, BigInt, Rational, Float:
+		return Float(toFloat(a)), nil
+	case String:
+		s, _ := text(a)
+		f, err := strconv.ParseFloat(strings.TrimSpace(s), 64)
+		if err != nil {
+			return nil, fmt.Errorf("no float-number: %s", a)
+		}
+		return Float(f), nil
+	}
+	return nil, errNoNumber
+}
+
+func stdString(args []Term) (Term, error) {
+	if s, ok := args[0].(String); ok {
+		return s, nil
+	}
+	return newString(args[0].String()), nil
+}
+
+// strings
+
+func stdLen(args []Term) (Term, error) {
+	s, err := text(args[0])
+	if err != nil {
+		return nil, err
+	}
+	return Int(utf8.RuneCountInString(s)), nil
+}
+
+func stdConcat(args []Term) (Term, error) {
+	s := ""
+	for _, a := range args {
+		if t, err := text(a); err == nil {
+			s += t
+		} else {
+			s += a.String()
+		}
+	}
+	return newString(s), nil
+}
+
+func stdSubstr(args []Term) (Term, error) {
+	s, err := text(args[0])
+	if err != nil {
+		return nil, err
+	}
+	i, err := index(args[1])
+	if err != nil {
+		return nil, err
+	}
+	n, err := index(args[2])
+	if err != nil {
+		return nil, err
+	}
+	r := []rune(s)
+	if i < 0 || n < 0 || i+n > len(r) {
+		return nil, errRange
+	}
+	return newString(string(r[i : i+n])), nil
+}
+
+func stdUpper(args []Term) (Term, error) {
+	s, err := text(args[0])
+	if err != nil {
+		return nil, err
+	}
+	return newString(strings.ToUpper(s)), nil
+}
+
+func stdLower(args []Term) (Term, error) {
+	s, err := text(args[0])
+	if err != nil {
+		return nil, err
+	}
+	return newString(strings.ToLower(s)), nil
+}
+
+func stdContains(args []Term) (Term, error) {
+	s, err := text(args[0])
+	if err != nil {
+		return nil, err
+	}
+	sub, err := text(args[1])
+	if err != nil {
+		return nil, err
+	}
+	return Bool(strings.Contains(s, sub)), nil
+}
+
+func stdSplit(args []Term) (Term, error) {
+	s, err := text(args[0])
+	if err != nil {
+		return nil, err
+	}
+	sep, err := text(args[1])
+	if err != nil {
+		return nil, err
+	}
+	l := List{}
+	for _, e := range strings.Split(s, sep) {
+		l = append(l, newString(e))
+	}
+	return l, nil
+}
+
+// lists
+
+func stdLength(args []Term) (Term, error) {
+	l, err := list(args[0])
+	if err != nil {
+		return nil, err
+	}
+	return Int(len(l)), nil
+}
+
+func stdNth(args []Term) (Term, error) {
+	l, err := list(args[0])
+	if err != nil {
+		return nil, err
+	}
+	i, err := index(args[1])
+	if err != nil {
+		return nil, err
+	}
+	if i < 0 || i >= len(l) {
+		return nil, errRange
+	}
+	return l[i], nil
+}
+
+func stdAppend(args []Term) (Term, error) {
+	l1, err := list(args[0])
+	if err != nil {
+		return nil, err
+	}
+	l2, err := list(args[1])
+	if err != nil {
+		return nil, err
+	}
+	l := make(List, 0, len(l1)+len(l2))
+	return append(append(l, l1...), l2...), nil
+}
+
+func stdReverse(args []Term) (Term, error) {
+	l, err := list(args[0])
+	if err != nil {
+		return nil, err
+	}
+	r := make(List, len(l))
+	for i, e := range l {
+		r[len(l)-1-i] = e
+	}
+	return r, nil
+}
+
+func stdMember(args []Term) (Term, error) {
+	l, err := list(args[1])
+	if err != nil {
+		return nil, err
+	}
+	for _, e := range l {
+		if Equal(e, args[0]) {
+			return Bool(true), nil
+		}
+	}
+	return Bool(false), nil
+}
+
+func stdSum(args []Term) (Term, error) {
+	l, err := list(args[0])
+	if err != nil {
+		return nil, err
+	}
+	var s Term = Int(0)
+	for _, e := range l {
+		if !isNumber(e) {
+			return nil, errNoNumber
+		}
+		s = evalPlus(nil, s, s.Type(), e, e.Type())
+	}
+	return s, nil
+}
+
+func stdSort(args []Term) (Term, error) {
+	l, err := list(args[0])
+	if err != nil {
+		return nil, err
+	}
+	s := append(List{}, l...)
+	sort.SliceStable(s, func(i, j int) bool { return lessStd(s[i], s[j]) })
+	return s, nil
+}
+
+// lessStd - the order of sort: numbers by value, then strings alphabetically,
+// then the other terms as printed
+func lessStd(t1, t2 Term) bool {
+	n1, n2 := isNumber(t1), isNumber(t2)
+	if n1 || n2 {
+		return n1 && (!n2 || cmpNumbers(t1, t2) < 0)
+	}
+	s1, err1 := text(t1)
+	s2, err2 := text(t2)
+	if err1 == nil || err2 == nil {
+		return err1 == nil && (err2 != nil || s1 < s2)
+	}
+	return t1.String() < t2.String()
+}
+
+// type tests
+
+func stdIsInt(args []Term) (Term, error) {
+	typ := args[0].Type()
+	return Bool(typ == IntType || typ == BigIntType), nil
+}
+
+func stdIsAtom(args []Term) (Term, error) {
+	switch a := args[0].(type) {
+	case Atom:
+		return Bool(true), nil
+	case Compound:
+		// the atom a is parsed as a()
+		return Bool(a.Prio == 0 && len(a.Args) == 0), nil
+	}
+	return Bool(false), nil
+}
+
+func stdIsList(args []Term) (Term, error) {
+	return Bool(args[0].Type() == ListType), nil
+}
+
+func stdGround(args []Term) (Term, error) {
+	return Bool(isGround(args[0])), nil
+}
+
+func stdVar(args []Term) (Term, error) {
+	return Bool(args[0].Type() == VariableType), nil
+}
+
+func stdNonvar(args []Term) (Term, error) {
+	return Bool(args[0].Type() != VariableType), nil
+}
@@ -0,0 +1,168 @@
+// Copyright © 2016 The Carneades Authors
+// This Source Code Form is subject to the terms of the
+// Mozilla Public License, v. 2.0. If a copy of the MPL
+// was not distributed with this file, You can obtain one
+// at http://mozilla.org/MPL/2.0/.
+
+package chr
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	. "github.com/hfried/GoCHR/src/engine/parser"
+	. "github.com/hfried/GoCHR/src/engine/terms"
+)
+
+func TestStdlib01(t *testing.T) {
+	// math and type conversions
+	for _, c := range [][2]string{
+		{"abs(-3)", "3"},
+		{"abs(-9223372036854775807 - 1)", "9223372036854775808"},
+		{"abs(-(1 rdiv 2)) == 1 rdiv 2", "true"},
+		{"min(3, 2.5)", "2.5"},
+		{"max(3, 18446744073709551616)", "18446744073709551616"},
+		{"sqrt(16)", "4.0"},
+		{"pow(2, 100)", "1267650600228229401496703205376"},
+		{"pow(2, -2) == 1 rdiv 4", "true"},
+		{"pow(2.0, 3)", "8.0"},
+		{"floor(-2.5) == -3", "true"},
+		{"ceil(2.1)", "3"},
+		{"round(2.5)", "3"},
+		{"floor(-(7 rdiv 2)) == -4", "true"},
+		{"ceil(7 rdiv 2)", "4"},
+		{"round(-(7 rdiv 2)) == -4", "true"},
+		{"round(10 rdiv 3)", "3"},
+		{"int(-3.9) == -3", "true"},
+		{"int(\"42\")", "42"},
+		{"float(3)", "3.0"},
+		{"float(\"2.5\")", "2.5"},
+		{"string(42)", "\"42\""},
+		{"abs(X)", "abs(X)"},
+	} {
+		if !teval(t, c[0], c[1]) {
+			t.Errorf("TestStdlib01 failed: %s\n", c[0])
+		}
+	}
+}
+
+func TestStdlib02(t *testing.T) {
+	// strings and lists
+	for _, c := range [][2]string{
+		{"len(\"äbc\")", "3"},
+		{"concat(\"ab\", \"cd\")", "\"abcd\""},
+		{"concat(\"x=\", 3)", "\"x=3\""},
+		{"substr(\"hello\", 1, 3)", "\"ell\""},
+		{"upper(\"chr\")", "\"CHR\""},
+		{"lower(\"CHR\")", "\"chr\""},
+		{"contains(\"hello\", \"ll\")", "true"},
+		{"split(\"a,b,c\", \",\")", "[\"a\", \"b\", \"c\"]"},
+		{"length([a, b, c])", "3"},
+		{"nth([a, b, c], 1)", "b"},
+		{"append([1, 2], [3])", "[1, 2, 3]"},
+		{"reverse([1, 2, 3])", "[3, 2, 1]"},
+		{"member(b, [a, b])", "true"},
+		{"member(c, [a, b])", "false"},
+		{"sum([1, 2, 3, 4])", "10"},
+		{"sum([9223372036854775807, 1])", "9223372036854775808"},
+		{"sum([])", "0"},
+		{"sort([3, \"b\", 1.5, f(x), \"a\", 2])", "[1.5, 2, 3, \"a\", \"b\", f(x)]"},
+	} {
+		if !teval(t, c[0], c[1]) {
+			t.Errorf("TestStdlib02 failed: %s\n", c[0])
+		}
+	}
+}
+
+func TestStdlib03(t *testing.T) {
+	// type tests, in guards with unbound variables
+	for _, c := range [][2]string{
+		{"is_int(3)", "true"},
+		{"is_int(18446744073709551616)", "true"},
+		{"is_int(3.0)", "false"},
+		{"is_atom(a)", "true"},
+		{"is_atom(f(a))", "false"},
+		{"is_list([])", "true"},
+		{"ground(f(a, [1]))", "true"},
+		{"ground(f(a, [X]))", "false"},
+		{"var(X)", "true"},
+		{"nonvar(X)", "false"},
+		{"nonvar(f(X))", "true"},
+	} {
+		if !teval(t, c[0], c[1]) {
+			t.Errorf("TestStdlib03 failed: %s\n", c[0])
+		}
+	}
+	for _, mode := range []SolverMode{ModeDefault, ModeRefined} {
+		rs := MakeRuleStore()
+		rs.Mode = mode
+		ok := rs.ParseStringCHRRulesGoals(`
+		free @ p(X) <=> var(X) | free(X).
+		bound @ p(X) <=> nonvar(X) | bound(X).
+		total @ total(L) <=> is_list(L), S := sum(L) | total_is(S).
+		p(Y), p(1), total([1, 2, 3, 4, 5, 6, 7, 8, 9, 10]).
+		#result: free(Y), bound(1), total_is(55).
+		`)
+		if !ok {
+			t.Errorf("TestStdlib03 fails, mode: %s", mode)
+		}
+	}
+}
+
+func TestStdlib04(t *testing.T) {
+	// a CHR-constraint hides the standard built-in, a registered built-in replaces it
+	rs := MakeRuleStore()
+	ok := rs.ParseStringCHRRulesGoals(`
+	m1 @ member(X, [X|L]) <=> found(X).
+	m2 @ member(X, [Y|L]) <=> member(X, L).
+	t1 @ test(X) <=> reverse(X) == [3, 2, 1] | reversed.
+	member(b, [a, b, c]), test([1, 2, 3]).
+	#result: found(b), reversed.
+	`)
+	if !ok {
+		t.Error("TestStdlib04 fails, member")
+	}
+	if err := rs.RegisterBuiltin("reverse", 1, func(args []Term) (Term, error) { return args[0], nil }); err != nil {
+		t.Fatalf("RegisterBuiltin fails: %s", err)
+	}
+	ok = rs.ParseStringCHRRulesGoals(`
+	t1 @ test(X) <=> reverse(X) == [1, 2, 3] | registered.
+	test([1, 2, 3]).
+	#result: registered.
+	`)
+	if !ok {
+		t.Error("TestStdlib04 fails, registered")
+	}
+}
+
+func TestStdlib05(t *testing.T) {
+	// an error of a standard built-in stops the solver
+	rs := MakeRuleStore()
+	rs.ParseStringCHRRulesGoals(`p(X) <=> Y := sqrt(X) | q(Y).`)
+	g, _ := ReadString("[p(4), p(-1)]")
+	_, err := rs.Solve(context.Background(), g)
+	var bErr *BuiltinError
+	if !errors.As(err, &bErr) || bErr.Call.String() != "sqrt(-1)" {
+		t.Errorf("TestStdlib05 fails, err: %v", err)
+	}
+}
+
+func TestStdlib06(t *testing.T) {
+	// a standard built-in with arguments of another type is kept as CHR-constraint
+	if !teval(t, "max(1, a)", "max(1, a)") || !teval(t, "length(1)", "length(1)") {
+		t.Error("TestStdlib06 failed: eval")
+	}
+	for _, mode := range []SolverMode{ModeDefault, ModeRefined} {
+		rs := MakeRuleStore()
+		rs.Mode = mode
+		ok := rs.ParseStringCHRRulesGoals(`
+		r @ p(X) ==> max(X, a), length(X).
+		p(1).
+		#result: p(1), max(1, a), length(1).
+		`)
+		if !ok || rs.Err != nil {
+			t.Errorf("TestStdlib06 fails, mode: %s, err: %v", mode, rs.Err)
+		}
+	}
+}
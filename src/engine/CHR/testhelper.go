@@ -60,7 +60,7 @@ func tAtt(t *testing.T, store string, head string, result string) bool {
 		fmt.Printf(" store [")
 		for _, g := range term1.(List) {
 			if g.Type() == CompoundType {
-				addConstraintToStore(rs, g.(Compound))
+				addConstraintToStore(&rs.Session, g.(Compound))
 				fmt.Printf("%s, ", g)
 			} else {
 				fmt.Printf(" no CHR predicate: %s \n", g)
@@ -68,7 +68,7 @@ func tAtt(t *testing.T, store string, head string, result string) bool {
 		}
 		fmt.Printf("]\n")
 	case CompoundType:
-		addConstraintToStore(rs, term1.(Compound))
+		addConstraintToStore(&rs.Session, term1.(Compound))
 		fmt.Printf("store [%s]\n", term1)
 	default:
 		fmt.Printf(" no CHR predicate or list: %s \n", term1)
@@ -88,9 +88,9 @@ func tAtt(t *testing.T, store string, head string, result string) bool {
 	fmt.Printf(" Head: %s ", term2)
 	t2 := term2.(Compound)
 	if term2.(Compound).Prio == 0 {
-		att = readProperConstraintsFromCHR_Store(rs, &t2, nil)
+		att = readProperConstraintsFromCHR_Store(&rs.Session, &t2, nil)
 	} else {
-		att = readProperConstraintsFromBI_Store(rs, &t2, nil)
+		att = readProperConstraintsFromBI_Store(&rs.Session, &t2, nil)
 	}
 	if term3.Type() != ListType {
 		fmt.Printf(" result is not a list %s \n", term3)
@@ -169,19 +169,14 @@ func tAddStringChrRule(rs *RuleStore, t *testing.T, name, keep, del, guard, body
 		return false
 	}
 
-	r := &chrRule{name: name, id: rs.nextRuleId,
+	r := &chrRule{name: name, id: rs.prog.nextRuleId,
 		delHead:  cDelList,
 		keepHead: cKeepList,
-		keepEnv:  makeKeepEnv(cKeepList),
 		guard:    cGuardList,
-		body:     bodyList.(List),
-		eMap:     &EnvMap{InBinding: rs.emptyBinding, OutBindings: map[int]*EnvMap{}},
-		isOn:     false,
-		wasOn:    true}
-	rs.CHRruleStore = append(rs.CHRruleStore, r)
-	TraceHeadln(3, 3, " OFF rule: ", name, " (t Add String CHR-Rule) ")
-	addRuleToPred2rule(rs, r)
-	rs.nextRuleId++
+		body:     bodyList.(List)}
+	rs.Trace.Headln(3, 3, " OFF rule: ", name, " (t Add String CHR-Rule) ")
+	appendRule(rs, r)
+	rs.prog.nextRuleId++
 	return true
 
 }
@@ -189,12 +184,12 @@ func tAddStringChrRule(rs *RuleStore, t *testing.T, name, keep, del, guard, body
 func tNewQuery(rs *RuleStore, t *testing.T, goals string) bool {
 	ClearCHRStore(rs)
 	if tAddStringGoals(rs, t, goals) {
-		if CHRtrace == 0 {
-			CHRtrace = 1
-			printCHRStore(rs, "New goal:")
-			CHRtrace = 0
+		if rs.Trace.Level == 0 {
+			rs.Trace.Level = 1
+			printCHRStore(&rs.Session, "New goal:")
+			rs.Trace.Level = 0
 		}
-		CHRsolver(rs)
+		CHRsolver(&rs.Session)
 		return true
 	}
 	return false
@@ -208,7 +203,7 @@ func tAddStringGoals(rs *RuleStore, t *testing.T, goals string) bool {
 	}
 	for _, g := range goalList.(List) {
 		if g.Type() == CompoundType {
-			addConstraintToStore(rs, g.(Compound))
+			addConstraintToStore(&rs.Session, g.(Compound))
 		} else {
 			t.Errorf(fmt.Sprintf(" GOAL is not a predicate: %s\n", g))
 			return false
@@ -225,7 +220,7 @@ func checkResult(rs *RuleStore, t *testing.T, chr, bi string) {
 		t.Error(" Scan exspected chr result failed: %s\n", chrList)
 		return
 	}
-	compCHR := chr2List(rs)
+	compCHR := chr2List(&rs.Session)
 	chrOK := EqualVarNameCList(compCHR, chrList)
 
 	biList, ok := ParseRuleBodyString(bi)
@@ -233,7 +228,7 @@ func checkResult(rs *RuleStore, t *testing.T, chr, bi string) {
 		t.Error(" Scan exspected bi result failed: %s\n", biList)
 		return
 	}
-	compBI := bi2List(rs)
+	compBI := bi2List(&rs.Session)
 	biOK := EqualVarNameCList(compBI, biList)
 
 	if !chrOK && !biOK {
@@ -315,11 +310,17 @@ func EqualVarNameCList(t1 Term, t2 Term) bool {
 
 func EqualVarName(t1, t2 Term) bool {
 	if t1.Type() != t2.Type() {
+		// a rational number is read as n rdiv d
+		if t1.Type() == RationalType || t2.Type() == RationalType {
+			return Equal(Eval(t1), Eval(t2))
+		}
 		return false
 	}
 	switch t1.Type() {
 	case AtomType, BoolType, IntType, FloatType, StringType:
 		return t1 == t2
+	case BigIntType, RationalType:
+		return Equal(t1, t2)
 	case CompoundType:
 		//		fmt.Printf("## t1-Functor %s(%d), t2-Functor %s(%d)\n ", t1.(Compound).Functor, t1.(Compound).Arity(),
 		//			t2.(Compound).Functor, t2.(Compound).Arity())
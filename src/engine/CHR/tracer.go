@@ -0,0 +1,224 @@
+// Copyright © 2016 The Carneades Authors
+// This Source Code Form is subject to the terms of the
+// Mozilla Public License, v. 2.0. If a copy of the MPL
+// was not distributed with this file, You can obtain one
+// at http://mozilla.org/MPL/2.0/.
+
+// Tracer - structured trace events of the solver runs
+
+package chr
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	. "github.com/hfried/GoCHR/src/engine/terms"
+)
+
+// Tracer receives the events of the solver runs of a rule store, see RuleStore.Tracer.
+// The events are sent by the goroutine of the solver run, before the solver goes on.
+type Tracer interface {
+	Event(e Event)
+}
+
+// EventKind - the kind of a trace event
+type EventKind int
+
+const (
+	EventConstraintAdded   EventKind = iota // a CHR- or built-in constraint is added to the store
+	EventRuleTried                          // the heads of a rule are matched
+	EventHeadMatched                        // a head of a rule matched a constraint of the CHR-store
+	EventGuardFailed                        // a guard of a rule failed
+	EventRuleFired                          // the heads and the guards matched, the body is executed next
+	EventConstraintDeleted                  // a constraint is removed from the CHR-store by a rule
+	EventStoreSubstituted                   // the bound variables are replaced in the CHR-store
+)
+
+func (k EventKind) String() string {
+	switch k {
+	case EventConstraintAdded:
+		return "constraint added"
+	case EventRuleTried:
+		return "rule tried"
+	case EventHeadMatched:
+		return "head matched"
+	case EventGuardFailed:
+		return "guard failed"
+	case EventRuleFired:
+		return "rule fired"
+	case EventConstraintDeleted:
+		return "constraint deleted"
+	case EventStoreSubstituted:
+		return "store substituted"
+	}
+	return "unknown"
+}
+
+// Event - a trace event of a solver run; only the fields of the kind are set
+type Event struct {
+	Kind       EventKind
+	Firing     int             // number of the rule firings of the solver run up to now, including a fired rule
+	Rule       string          // the name of the tried, matched or fired rule
+	Constraint *Compound       // the added, deleted or matched constraint; the active constraint of a tried rule (ModeRefined)
+	Head       *Compound       // the matched head
+	Guard      *Compound       // the failed guard
+	Partners   CList           // the constraints of the heads of a fired rule, del-heads first
+	Bindings   map[string]Term // the bindings of the rule variables; the substituted variables of the store
+}
+
+func (e Event) String() string {
+	s := e.Kind.String() + ":"
+	if e.Rule != "" {
+		s += " " + e.Rule
+	}
+	switch e.Kind {
+	case EventConstraintAdded, EventConstraintDeleted:
+		s += fmt.Sprintf(" %s (Id: %s)", e.Constraint, e.Constraint.Id)
+	case EventRuleTried:
+		if e.Constraint != nil {
+			s += fmt.Sprintf(", active: %s (Id: %s)", e.Constraint, e.Constraint.Id)
+		}
+	case EventHeadMatched:
+		s += fmt.Sprintf(", %s with %s (Id: %s)", e.Head, e.Constraint, e.Constraint.Id)
+	case EventGuardFailed:
+		s += fmt.Sprintf(", %s", e.Guard)
+	case EventRuleFired:
+		s += " " + e.Partners.String()
+	}
+	if len(e.Bindings) != 0 {
+		s += " " + bindings2string(e.Bindings)
+	}
+	return s
+}
+
+// bindings2string - the bindings in the order of the variable names
+func bindings2string(bindings map[string]Term) string {
+	names := make([]string, 0, len(bindings))
+	for name := range bindings {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for i, name := range names {
+		names[i] = name + "=" + bindings[name].String()
+	}
+	return "{" + strings.Join(names, ", ") + "}"
+}
+
+// env2map - the bindings of env, the latest binding of a variable first
+func env2map(env Bindings) map[string]Term {
+	m := map[string]Term{}
+	for b := env; b != nil; b = b.Next {
+		if b.Var.Name == "" || b.T == nil {
+			continue
+		}
+		name := b.Var.String()
+		if _, ok := m[name]; !ok {
+			m[name] = b.T
+		}
+	}
+	return m
+}
+
+// event sends the event e with the bindings env to the tracer of the rule store;
+// the constraints of the event are copies
+func (rs *Session) event(e Event, env Bindings) {
+	if rs.Tracer == nil {
+		return
+	}
+	e.Firing = rs.ruleFirings
+	if e.Constraint != nil {
+		c := CopyCompound(*e.Constraint)
+		e.Constraint = &c
+	}
+	if e.Partners != nil {
+		partners := make(CList, len(e.Partners))
+		for i, p := range e.Partners {
+			c := CopyCompound(*p)
+			partners[i] = &c
+		}
+		e.Partners = partners
+	}
+	if env != nil {
+		e.Bindings = env2map(env)
+	}
+	rs.Tracer.Event(e)
+}
+
+// TextTracer writes the events as lines of text to Out, to os.Stdout, if Out is nil
+type TextTracer struct {
+	Out io.Writer
+}
+
+func (tr *TextTracer) Event(e Event) {
+	out := tr.Out
+	if out == nil {
+		out = os.Stdout
+	}
+	fmt.Fprintf(out, "[%d] %s\n", e.Firing, e)
+}
+
+// JSONTracer writes the events as JSON-lines (one JSON-object per line) to Out;
+// the terms are written in the CHR-syntax
+type JSONTracer struct {
+	Out io.Writer
+	err error
+}
+
+type jsonEvent struct {
+	Kind       string            `json:"kind"`
+	Firing     int               `json:"firing"`
+	Rule       string            `json:"rule,omitempty"`
+	Constraint string            `json:"constraint,omitempty"`
+	Id         string            `json:"id,omitempty"`
+	Head       string            `json:"head,omitempty"`
+	Guard      string            `json:"guard,omitempty"`
+	Partners   []string          `json:"partners,omitempty"`
+	Bindings   map[string]string `json:"bindings,omitempty"`
+}
+
+func (tr *JSONTracer) Event(e Event) {
+	if tr.err != nil {
+		return
+	}
+	je := jsonEvent{Kind: e.Kind.String(), Firing: e.Firing, Rule: e.Rule}
+	if e.Constraint != nil {
+		je.Constraint = e.Constraint.String()
+		if e.Constraint.Id != nil {
+			je.Id = e.Constraint.Id.String()
+		}
+	}
+	if e.Head != nil {
+		je.Head = e.Head.String()
+	}
+	if e.Guard != nil {
+		je.Guard = e.Guard.String()
+	}
+	for _, p := range e.Partners {
+		je.Partners = append(je.Partners, p.String())
+	}
+	if len(e.Bindings) != 0 {
+		je.Bindings = map[string]string{}
+		for name, t := range e.Bindings {
+			je.Bindings[name] = t.String()
+		}
+	}
+	tr.err = json.NewEncoder(tr.Out).Encode(je)
+}
+
+// Err - the first error of writing an event; no events are written after an error
+func (tr *JSONTracer) Err() error {
+	return tr.err
+}
+
+// MemoryTracer keeps the events in Events
+type MemoryTracer struct {
+	Events []Event
+}
+
+func (tr *MemoryTracer) Event(e Event) {
+	tr.Events = append(tr.Events, e)
+}
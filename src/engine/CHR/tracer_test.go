@@ -0,0 +1,115 @@
+// Copyright © 2016 The Carneades Authors
+// This Source Code Form is subject to the terms of the
+// Mozilla Public License, v. 2.0. If a copy of the MPL
+// was not distributed with this file, You can obtain one
+// at http://mozilla.org/MPL/2.0/.
+
+package chr
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+const gcdRules = `
+gcd1 @ gcd(0) <=> true .
+gcd2 @ gcd(N) \ gcd(M) <=> N <= M, L := M mod N | gcd(L).
+eq @ p(X) <=> X == 1 .
+`
+
+func traceGoal(t *testing.T, mode SolverMode, tr Tracer, goal string) *RuleStore {
+	rs := MakeRuleStore()
+	rs.Mode = mode
+	if !rs.ParseStringCHRRulesGoals(gcdRules) {
+		t.Fatal("parse rules fails")
+	}
+	rs.Tracer = tr
+	g, _ := ParseGoalString(goal)
+	if _, err := rs.Solve(context.Background(), g); err != nil {
+		t.Fatalf("Solve(%s) fails: %s", goal, err)
+	}
+	return rs
+}
+
+func TestTracer01(t *testing.T) {
+	// the events of both modes
+	for _, mode := range []SolverMode{ModeDefault, ModeRefined} {
+		tr := &MemoryTracer{}
+		rs := traceGoal(t, mode, tr, "gcd(9), gcd(6), p(Y), q(Y)")
+		count := map[EventKind]int{}
+		for _, e := range tr.Events {
+			count[e.Kind]++
+		}
+		// added: 4 goals, gcd(3), gcd(0), Y==1 and the substituted q(1) (ModeDefault);
+		// deleted: gcd(9), gcd(6), gcd(0), p(Y)
+		added := 8
+		if mode == ModeRefined {
+			// q(Y) is substituted in place by the reactivation
+			added = 7
+		}
+		if count[EventRuleFired] != rs.ruleFirings || count[EventRuleFired] != 4 ||
+			count[EventConstraintAdded] != added || count[EventConstraintDeleted] != 4 ||
+			count[EventStoreSubstituted] != 1 || count[EventGuardFailed] != 2 ||
+			count[EventRuleTried] == 0 || count[EventHeadMatched] == 0 {
+			t.Errorf("TestTracer01 fails, mode: %s, events: %v", mode, count)
+		}
+		for _, e := range tr.Events {
+			if e.Kind != EventRuleFired || e.Firing != 1 {
+				continue
+			}
+			if e.Rule != "gcd2" || e.Partners.String() != "[gcd(9), gcd(6)]" ||
+				bindings2string(e.Bindings) != "{L=3, M=9, N=6}" {
+				t.Errorf("TestTracer01 fails, mode: %s, first firing: %s", mode, e)
+			}
+		}
+		if e := tr.Events[0]; e.Kind != EventConstraintAdded || e.Constraint.String() != "gcd(9)" || e.Firing != 0 {
+			t.Errorf("TestTracer01 fails, mode: %s, first event: %s", mode, e)
+		}
+	}
+}
+
+func TestTracer02(t *testing.T) {
+	// text and JSON-lines
+	var text bytes.Buffer
+	traceGoal(t, ModeDefault, &TextTracer{Out: &text}, "gcd(4), gcd(2)")
+	lines := strings.Split(text.String(), "\n")
+	for _, want := range []string{
+		"[0] constraint added: gcd(4) (Id: 0)",
+		"[0] guard failed: gcd2, N<=M {M=2, N=4}",
+		"[1] rule fired: gcd2 [gcd(4), gcd(2)] {L=0, M=4, N=2}",
+		"[1] constraint deleted: gcd(4) (Id: 0)",
+	} {
+		found := false
+		for _, l := range lines {
+			found = found || l == want
+		}
+		if !found {
+			t.Errorf("TestTracer02 fails, missing: %s, in:\n%s", want, text.String())
+		}
+	}
+
+	var out bytes.Buffer
+	tr := &JSONTracer{Out: &out}
+	traceGoal(t, ModeRefined, tr, "p(Y), q(Y)")
+	kinds := []string{}
+	for _, l := range strings.Split(strings.TrimSpace(out.String()), "\n") {
+		var e struct {
+			Kind     string            `json:"kind"`
+			Bindings map[string]string `json:"bindings"`
+		}
+		if err := json.Unmarshal([]byte(l), &e); err != nil {
+			t.Fatalf("TestTracer02 fails, line: %s, err: %s", l, err)
+		}
+		kinds = append(kinds, e.Kind)
+		if e.Kind == "store substituted" && e.Bindings["Y"] != "1" {
+			t.Errorf("TestTracer02 fails, line: %s", l)
+		}
+	}
+	if tr.Err() != nil || strings.Join(kinds, ",") != "constraint added,constraint added,"+
+		"rule tried,head matched,rule fired,constraint deleted,constraint added,store substituted" {
+		t.Errorf("TestTracer02 fails, err: %v, kinds: %v", tr.Err(), kinds)
+	}
+}
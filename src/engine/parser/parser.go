@@ -11,6 +11,7 @@ package parser
 import (
 	"fmt"
 	. "github.com/hfried/GoCHR/src/engine/terms"
+	"math/big"
 	"os"
 	"strings"
 	sc "text/scanner"
@@ -21,11 +22,12 @@ import (
 // Precedence  Operator
 //     7 (coded as 0)  Variavle, Function, Konstant
 //     6         unary operators +, -, !, ^, ¬ and in Go: *, &, <-
-//     5         *, /, %, div, mod, &, &^, <<, >>
+//     5         *, /, %, div, mod, rdiv, &, &^, <<, >>
 //     4        +, -, ^, or (the | will be used as list-operator, as in [a|B])
-//     3        ==, !=, <, <=, >, >= and =< (only for Prolog-like)
+//     3        ==, !=, <, <=, >, >=, =< (only for Prolog-like), in and .. (range of integers, 1..9)
 //     2        &&
 //     1        ||
+//     1        ; (disjunction of goal-lists, only in '(' ')')
 
 const trace = false
 
@@ -231,9 +233,9 @@ func comp_expr(s *sc.Scanner, tok1 rune) (t Term, tok rune, ok bool) {
 	if trace {
 		fmt.Printf("--> comp_expr: '%s'\n", Tok2str(tok1))
 	}
-	t, tok, ok = simple_expression(s, tok1)
+	t, tok, ok = range_expr(s, tok1)
 	if trace {
-		fmt.Printf("<-- simple_expression: term: %s tok: '%s' ok: %v \n", t.String(), Tok2str(tok), ok)
+		fmt.Printf("<-- range_expr: term: %s tok: '%s' ok: %v \n", t.String(), Tok2str(tok), ok)
 	}
 	op := ""
 	// named operator
@@ -284,9 +286,9 @@ func comp_expr(s *sc.Scanner, tok1 rune) (t Term, tok rune, ok bool) {
 	}
 	// compare expression with op
 	t1 := t
-	t, tok, ok = simple_expression(s, s.Scan())
+	t, tok, ok = range_expr(s, s.Scan())
 	if trace {
-		fmt.Printf("<-- simple_expression: term: %s tok: '%s' ok: %v \n", t.String(), Tok2str(tok), ok)
+		fmt.Printf("<-- range_expr: term: %s tok: '%s' ok: %v \n", t.String(), Tok2str(tok), ok)
 	}
 	if !ok {
 		return t1, tok, ok
@@ -295,6 +297,25 @@ func comp_expr(s *sc.Scanner, tok1 rune) (t Term, tok rune, ok bool) {
 	return Compound{Functor: op, Args: []Term{t1, t}, Prio: 3}, tok, ok
 }
 
+// <simple_expression> | <simple_expression> '..' <simple_expression>
+func range_expr(s *sc.Scanner, tok1 rune) (t Term, tok rune, ok bool) {
+	if trace {
+		fmt.Printf("--> range_expr : '%s'\n", Tok2str(tok1))
+	}
+	t, tok, ok = simple_expression(s, tok1)
+	if !ok || tok != '.' || s.Peek() != '.' {
+		return
+	}
+	// the second '.' is read as character, '..9' is no float-number '.9'
+	s.Next()
+	t1 := t
+	t, tok, ok = simple_expression(s, s.Scan())
+	if !ok {
+		return t1, tok, ok
+	}
+	return Compound{Functor: "..", Args: []Term{t1, t}, Prio: 3}, tok, ok
+}
+
 // <sterm> | <sterm> ['or','-','+','^'] <sterm>
 func simple_expression(s *sc.Scanner, tok1 rune) (t Term, tok rune, ok bool) {
 	if trace {
@@ -349,7 +370,7 @@ func simple_expression(s *sc.Scanner, tok1 rune) (t Term, tok rune, ok bool) {
 	}
 }
 
-// <unary_factor> | <unary_factor> ['div','mod','*','/','%','&','&^','<<','>>'] <unary_factor>
+// <unary_factor> | <unary_factor> ['div','mod','rdiv','*','/','%','&','&^','<<','>>'] <unary_factor>
 func sterm(s *sc.Scanner, tok1 rune) (t Term, tok rune, ok bool) {
 	if trace {
 		fmt.Printf("--> sterm : '%s'\n", Tok2str(tok1))
@@ -368,6 +389,8 @@ func sterm(s *sc.Scanner, tok1 rune) (t Term, tok rune, ok bool) {
 					op = "div"
 				case "mod":
 					op = "mod"
+				case "rdiv":
+					op = "rdiv"
 				default:
 					return
 				}
@@ -471,7 +494,7 @@ func unary_factor(s *sc.Scanner, tok1 rune) (t Term, tok rune, ok bool) {
 }
 
 // '[' ']' | '[' <expression> [',' <expression>]0..n ['|' <variable>]0..1 ']' |
-// '(' <expression> ')' | <factor-name> | <int> | <float> | <char> | <string> | <raw-string>
+// '(' <expression> ')' | '(' <disjunction> ')' | <factor-name> | <int> | <float> | <char> | <string> | <raw-string>
 func factor(s *sc.Scanner, tok1 rune) (t Term, tok rune, ok bool) {
 	if trace {
 		fmt.Printf("--> factor : '%s'\n", Tok2str(tok1))
@@ -533,13 +556,19 @@ func factor(s *sc.Scanner, tok1 rune) (t Term, tok rune, ok bool) {
 		return t, s.Scan(), true
 	case '(':
 		pos := s.Pos()
-		t, tok, ok = expression(s, s.Scan())
+		t, tok, ok = Assignexpr(s, s.Scan())
 		if trace {
 			fmt.Printf("<-- expression in ( factor: term: %s tok: '%s' ok: %v \n", t.String(), Tok2str(tok), ok)
 		}
 		if !ok {
 			return
 		}
+		if tok == ',' || tok == ';' {
+			t, tok, ok = Disjunction(s, List{t}, tok)
+			if !ok {
+				return
+			}
+		}
 		if tok != ')' {
 			s.Error(s, fmt.Sprintf("missing closed ')' for the open '(' at position %s", pos))
 			return t, tok, false
@@ -588,6 +617,43 @@ func factor(s *sc.Scanner, tok1 rune) (t Term, tok rune, ok bool) {
 	return t, tok, true
 }
 
+// <goal-list> ';' <goal-list> [';' <goal-list>]0..n, a <goal-list> is
+// <assign-expression> [',' <assign-expression>]0..n;
+// conj is the first goal-list, which is read up to the token tok1.
+// The result is the compound ';' with the goal-lists (type List) as arguments.
+func Disjunction(s *sc.Scanner, conj List, tok1 rune) (t Term, tok rune, ok bool) {
+	if trace {
+		fmt.Printf("--> disjunction : %s, '%s'\n", conj, Tok2str(tok1))
+	}
+	alts := []Term{}
+	tok = tok1
+	for {
+		if tok == ',' {
+			t, tok, ok = Assignexpr(s, s.Scan())
+			if !ok {
+				return t, tok, false
+			}
+			conj = append(conj, t)
+			continue
+		}
+		alts = append(alts, conj)
+		if tok != ';' {
+			break
+		}
+		t, tok, ok = Assignexpr(s, s.Scan())
+		if !ok {
+			return t, tok, false
+		}
+		conj = List{t}
+	}
+	t = Compound{Functor: ";", Args: alts, Prio: 1}
+	if len(alts) < 2 {
+		s.Error(s, fmt.Sprintf("missing ';' in the disjunction %s", conj))
+		return t, tok, false
+	}
+	return t, tok, true
+}
+
 // <bi_0 name> | <name>'('')' | <name> '(' <expression> [',' <expression>]0..n ')'
 func Factor_name(name string, s *sc.Scanner, tok1 rune) (t Term, tok rune, ok bool) {
 	if trace {
@@ -637,6 +703,10 @@ func sInt(s *sc.Scanner) (Term, rune, bool) {
 	if err == nil {
 		return Int(i), s.Scan(), true
 	}
+	// too large for an Int
+	if b, ok := new(big.Int).SetString(s.TokenText(), 0); ok {
+		return NewBigInt(b), s.Scan(), true
+	}
 	return Int(i), s.Scan(), false
 }
 
@@ -648,6 +718,13 @@ func sFloat(s *sc.Scanner) (Term, rune, bool) {
 		f   float64
 		err error
 	)
+	// the integer before '..' is scanned as float-number, e.g. '1.' in 1..9
+	if text := s.TokenText(); strings.HasSuffix(text, ".") && s.Peek() == '.' {
+		var i int
+		if _, err = fmt.Sscan(text[:len(text)-1], &i); err == nil {
+			return Int(i), '.', true
+		}
+	}
 	_, err = fmt.Sscan(s.TokenText(), &f)
 	if err == nil {
 		return Float(f), s.Scan(), true
@@ -38,6 +38,10 @@ func Test_terms(t *testing.T) {
 	tt(t, "¬A")
 	tt(t, "-a+-b+^c+!d")
 	tt(t, "---A+!!!B++++C")
+	tt(t, "(X == s(Y), Z == Y ; X == Y, Z := s(Y) ; f(X))")
+	tt(t, "123456789012345678901234567890 rdiv 7 + 0x1fffffffffffffffff")
+	tt(t, "X in 1..9")
+	tt(t, "[X, Y] in -3 .. N-1")
 	// tt(t, "_t(-_a,_B)")
 
 	// Fehler
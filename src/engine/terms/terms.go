@@ -28,6 +28,8 @@ const (
 	CompoundType
 	ListType
 	VariableType
+	BigIntType
+	RationalType
 )
 
 type Vars []Variable
@@ -44,6 +46,16 @@ type Int int
 type Float float64
 type String string
 
+// BigInt - an integer, which does not fit into an Int; see NewBigInt
+type BigInt struct {
+	i *big.Int
+}
+
+// Rational - a fraction of integers, which is not an integer; see NewRational
+type Rational struct {
+	r *big.Rat
+}
+
 // type EnvMap map[int][]Bindings
 
 type Compound struct {
@@ -271,6 +283,14 @@ func (t String) Type() Type {
 	return StringType
 }
 
+func (t BigInt) Type() Type {
+	return BigIntType
+}
+
+func (t Rational) Type() Type {
+	return RationalType
+}
+
 func (t Compound) Type() Type {
 	return CompoundType
 }
@@ -307,58 +327,74 @@ func (t String) String() string {
 	return string(t)
 }
 
+func (t BigInt) String() string {
+	return t.i.String()
+}
+
+// String - the rational number as n rdiv d, which is read back by the parser
+func (t Rational) String() string {
+	return t.r.Num().String() + " rdiv " + t.r.Denom().String()
+}
+
+// operandPrio - the precedence of the operand t of an operator, 7 for a term without
+// operator; a rational number n rdiv d as right operand of *, /, ... in parentheses
+func operandPrio(t Term, right bool) int {
+	switch t := t.(type) {
+	case Compound:
+		if t.Prio != 0 {
+			return t.Prio
+		}
+	case Rational:
+		if right {
+			return 4
+		}
+		return 5
+	}
+	return 7
+}
+
 func (t Compound) String() string {
+	if t.Functor == ";" && t.Prio != 0 {
+		// disjunction of goal-lists
+		alts := []string{}
+		for _, alt := range t.Args {
+			if l, ok := alt.(List); ok {
+				goals := []string{}
+				for _, g := range l {
+					goals = append(goals, g.String())
+				}
+				alts = append(alts, strings.Join(goals, ", "))
+			} else {
+				alts = append(alts, alt.String())
+			}
+		}
+		return "(" + strings.Join(alts, " ; ") + ")"
+	}
 	if t.Prio != 0 {
 		prio := t.Prio
 		f := t.Functor
 		switch f {
-		case "||", "&&", "in", "or", "div", "mod":
+		case "||", "&&", "in", "or", "div", "mod", "rdiv":
 			f = " " + f + " "
 		}
 		switch t.Arity() {
 		case 1:
-			if t.Args[0].Type() == CompoundType {
-				prio1 := t.Args[0].(Compound).Prio
-				if prio1 == 0 {
-					return f + t.Args[0].String()
-				}
-				if prio1 < prio {
-					return f + "(" + t.Args[0].String() + ")"
-				}
+			if operandPrio(t.Args[0], false) < prio {
+				return f + "(" + t.Args[0].String() + ")"
 			}
 			return f + t.Args[0].String()
 		case 2:
-			if t.Args[0].Type() == CompoundType {
-				prio1 := t.Args[0].(Compound).Prio
-				if prio1 == 0 {
-					prio1 = 7
-				}
-				if t.Args[1].Type() == CompoundType {
-					prio2 := t.Args[1].(Compound).Prio
-					if prio2 == 0 {
-						prio2 = 7
-					}
-					switch {
-					case prio1 < prio && prio2 < prio:
-						return "(" + t.Args[0].String() + ") " + f + " (" + t.Args[1].String() + ")"
-					case prio1 < prio:
-						return "(" + t.Args[0].String() + ") " + f + " " + t.Args[1].String()
-					case prio2 < prio:
-						return t.Args[0].String() + " " + f + " (" + t.Args[1].String() + ")"
-					default:
-						return t.Args[0].String() + f + t.Args[1].String()
-					}
-				} else {
-					if prio1 < prio {
-						return "(" + t.Args[0].String() + ") " + f + " " + t.Args[1].String()
-					} else {
-						return t.Args[0].String() + f + t.Args[1].String()
-					}
-				}
-			} else if t.Args[1].Type() == CompoundType && t.Args[1].(Compound).Prio != 0 && t.Args[1].(Compound).Prio < prio {
-				return t.Args[0].String() + " " + f + " (" + t.Args[1].String() + ")"
+			a0, a1 := t.Args[0].String(), t.Args[1].String()
+			prio0, prio1 := operandPrio(t.Args[0], false), operandPrio(t.Args[1], true)
+			switch {
+			case prio0 < prio && prio1 < prio:
+				return "(" + a0 + ") " + f + " (" + a1 + ")"
+			case prio0 < prio:
+				return "(" + a0 + ") " + f + " " + a1
+			case prio1 < prio:
+				return a0 + " " + f + " (" + a1 + ")"
 			}
-			return t.Args[0].String() + f + t.Args[1].String()
+			return a0 + f + a1
 
 		}
 	}
@@ -433,6 +469,14 @@ func (t String) OccurVars() Vars {
 	return nil
 }
 
+func (t BigInt) OccurVars() Vars {
+	return nil
+}
+
+func (t Rational) OccurVars() Vars {
+	return nil
+}
+
 func (t Compound) OccurVars() Vars {
 	if t.identifyOccurVars {
 		return t.occurVars
@@ -458,38 +502,49 @@ func (t Variable) OccurVars() Vars {
 	return Vars{t}
 }
 
-func (t Compound) Arity() int {
-	return len(t.Args)
+// NewBigInt - the integer i as term, an Int, if i fits into an Int, else a BigInt;
+// i is copied
+func NewBigInt(i *big.Int) Term {
+	if i.IsInt64() {
+		if v := i.Int64(); int64(int(v)) == v {
+			return Int(v)
+		}
+	}
+	return BigInt{i: new(big.Int).Set(i)}
 }
 
-// stream of pointers to big integers for renaming variables
-var Counter <-chan *big.Int
+// Big - the value of t (a copy)
+func (t BigInt) Big() *big.Int {
+	return new(big.Int).Set(t.i)
+}
+
+// NewRational - the fraction r as term, an Int or a BigInt, if r is an integer,
+// else a Rational; r is copied
+func NewRational(r *big.Rat) Term {
+	if r.IsInt() {
+		return NewBigInt(r.Num())
+	}
+	return Rational{r: new(big.Rat).Set(r)}
+}
 
-// var Counter <-chan *big.Int
+// Rat - the value of t (a copy)
+func (t Rational) Rat() *big.Rat {
+	return new(big.Rat).Set(t.r)
+}
 
-var InitRenamingVariables func()
+func (t Compound) Arity() int {
+	return len(t.Args)
+}
 
-func init() {
-	c := make(chan *big.Int)
-	reset := make(chan bool)
-	i := big.NewInt(1)
-	one := big.NewInt(1)
-	go func() {
-		for {
-			select {
-			case c <- i:
-				i = new(big.Int).Add(i, one)
-			case <-reset:
-				i = one
-			}
-		}
-	}()
-	InitRenamingVariables = func() { reset <- true }
-	Counter = c
+// Rename - the variable v renamed with the index idx
+func (v Variable) Rename(idx *big.Int) Variable {
+	return Variable{Name: v.Name, index: idx}
 }
 
-func (v Variable) Rename() Variable {
-	return Variable{Name: v.Name, index: <-Counter}
+// Key - a string, unique for the name and the index of the variable v,
+// e.g. the key of v in a map
+func (v Variable) Key() string {
+	return v.Name + "#" + v.index.String()
 }
 
 func Equal(t1, t2 Term) bool {
@@ -499,6 +554,10 @@ func Equal(t1, t2 Term) bool {
 	switch t1.Type() {
 	case AtomType, BoolType, IntType, FloatType, StringType:
 		return t1 == t2
+	case BigIntType:
+		return t1.(BigInt).i.Cmp(t2.(BigInt).i) == 0
+	case RationalType:
+		return t1.(Rational).r.Cmp(t2.(Rational).r) == 0
 	case CompoundType:
 		//		fmt.Printf("## t1-Functor %s(%d), t2-Functor %s(%d)\n ", t1.(Compound).Functor, t1.(Compound).Arity(),
 		//			t2.(Compound).Functor, t2.(Compound).Arity())
@@ -564,7 +623,7 @@ func Match(t1, t2 Term, env Bindings) (env2 Bindings, ok bool) {
 		return env, false
 	}
 	switch t1.Type() {
-	case AtomType, BoolType, IntType, FloatType, StringType:
+	case AtomType, BoolType, IntType, FloatType, StringType, BigIntType, RationalType:
 		return env, Equal(t1, t2)
 	case CompoundType:
 		if t1.(Compound).Functor != t2.(Compound).Functor ||
@@ -656,19 +715,12 @@ func Match(t1, t2 Term, env Bindings) (env2 Bindings, ok bool) {
 	}
 }
 
-func TraceMatch(t1, t2 Term, env Bindings) (env2 Bindings, ok bool) {
-	TraceHeadln(3, 3, " > TraceMatch t1=", t1, " t2=", t2, " env=", env)
-	env2, ok = Match1(t1, t2, env)
-	TraceHeadln(3, 3, " < TraceMatch t1=", t1, " t2=", t2, " is ", ok, " env=", env2)
-	return
-}
-
 func Match1(t1, t2 Term, env Bindings) (env2 Bindings, ok bool) {
 	if t1.Type() != VariableType && t1.Type() != t2.Type() {
 		return env, false
 	}
 	switch t1.Type() {
-	case AtomType, BoolType, IntType, FloatType, StringType:
+	case AtomType, BoolType, IntType, FloatType, StringType, BigIntType, RationalType:
 		return env, Equal(t1, t2)
 	case CompoundType:
 		if t1.(Compound).Functor != t2.(Compound).Functor ||
@@ -677,7 +729,7 @@ func Match1(t1, t2 Term, env Bindings) (env2 Bindings, ok bool) {
 		}
 		env2 := env
 		for i, _ := range t1.(Compound).Args {
-			env2, ok = TraceMatch(t1.(Compound).Args[i], t2.(Compound).Args[i], env2)
+			env2, ok = Match1(t1.(Compound).Args[i], t2.(Compound).Args[i], env2)
 			if !ok {
 				return env, false
 			}
@@ -706,16 +758,16 @@ func Match1(t1, t2 Term, env Bindings) (env2 Bindings, ok bool) {
 			}
 			env2 := env
 			for i := 0; i < lent1m1; i++ {
-				env2, ok = TraceMatch(t1.(List)[i], t2.(List)[i], env2)
+				env2, ok = Match1(t1.(List)[i], t2.(List)[i], env2)
 				if !ok {
 					return env, false
 				}
 			}
 			v := last.(Compound).Args[0]
 			if lent2 == lent1m1 {
-				env2, ok = TraceMatch(v, List{}, env2)
+				env2, ok = Match1(v, List{}, env2)
 			} else {
-				env2, ok = TraceMatch(v, t2.(List)[lent1m1:], env2)
+				env2, ok = Match1(v, t2.(List)[lent1m1:], env2)
 			}
 			if !ok {
 				return env, false
@@ -729,7 +781,7 @@ func Match1(t1, t2 Term, env Bindings) (env2 Bindings, ok bool) {
 		env2 := env
 		// for i, _ := range t1.(List) {
 		for i := 0; i < lent1; i++ {
-			env2, ok = TraceMatch(t1.(List)[i], t2.(List)[i], env2)
+			env2, ok = Match1(t1.(List)[i], t2.(List)[i], env2)
 			if !ok {
 				return env, false
 			}
@@ -818,7 +870,7 @@ func Unify1(t1, t2 Term, visited Vars, env Bindings) (env2 Bindings, ok bool) {
 		return env, false
 	}
 	switch t1.Type() {
-	case AtomType, BoolType, IntType, FloatType, StringType:
+	case AtomType, BoolType, IntType, FloatType, StringType, BigIntType, RationalType:
 		return env, Equal(t1, t2)
 	case CompoundType:
 		if t1.(Compound).Functor != t2.(Compound).Functor ||
@@ -972,7 +1024,7 @@ func Substitute(t Term, env Bindings) Term {
 func Substitute1(t Term, visited map[string]bool, env Bindings) Term {
 
 	switch t.Type() {
-	case AtomType, BoolType, IntType, FloatType, StringType:
+	case AtomType, BoolType, IntType, FloatType, StringType, BigIntType, RationalType:
 		return t
 	case CompoundType:
 		args := []Term{}
@@ -1020,7 +1072,7 @@ func SubstituteBiEnv(t Term, biEnv Bindings) (Term, bool) {
 	visited := map[Variable]bool{}
 
 	switch t.Type() {
-	case AtomType, BoolType, IntType, FloatType, StringType:
+	case AtomType, BoolType, IntType, FloatType, StringType, BigIntType, RationalType:
 		return t, ok
 	case CompoundType:
 		args := []Term{}
@@ -1070,7 +1122,7 @@ func RenameAndSubstitute(t Term, idx *big.Int, env Bindings) Term {
 	// visited := map[Variable]bool{}
 
 	switch t.Type() {
-	case AtomType, BoolType, IntType, FloatType, StringType:
+	case AtomType, BoolType, IntType, FloatType, StringType, BigIntType, RationalType:
 		return t
 	case CompoundType:
 		args := []Term{}
@@ -10,98 +10,112 @@ package terms
 
 import (
 	"fmt"
-
-	// "math/big"
-	// "strconv"
-	// "strings"
+	"io"
+	"os"
 )
 
-var CHRtrace int
+// TraceWriter - the trace of a solver run: the messages up to the level
+// Level are written to Out, to os.Stdout, if Out is nil;
+// the zero value writes no trace
+type TraceWriter struct {
+	Level int
+	Out   io.Writer
+}
 
 // ---------------
 // trace functions
 // ---------------
 
-func TraceHeadln(l, n int, s ...interface{}) {
-	if CHRtrace >= l {
+// On - the messages of the level l are written
+func (tw *TraceWriter) On(l int) bool {
+	return tw != nil && tw.Level >= l
+}
+
+func (tw *TraceWriter) printf(format string, a ...interface{}) {
+	out := tw.Out
+	if out == nil {
+		out = os.Stdout
+	}
+	fmt.Fprintf(out, format, a...)
+}
+
+func (tw *TraceWriter) Headln(l, n int, s ...interface{}) {
+	if tw.On(l) {
 		for i := 0; i < n; i++ {
-			fmt.Printf("      ")
+			tw.printf("      ")
 		}
-		fmt.Printf("*** ")
+		tw.printf("*** ")
 		for _, s1 := range s {
-			fmt.Printf("%v", s1)
+			tw.printf("%v", s1)
 		}
-		fmt.Printf("\n")
+		tw.printf("\n")
 	}
 }
 
-func TraceHead(l, n int, s ...interface{}) {
-	if CHRtrace >= l {
+func (tw *TraceWriter) Head(l, n int, s ...interface{}) {
+	if tw.On(l) {
 		for i := 0; i < n; i++ {
-			fmt.Printf("      ")
+			tw.printf("      ")
 		}
-		fmt.Printf("*** ")
+		tw.printf("*** ")
 		for _, s1 := range s {
-			fmt.Printf("%v", s1)
+			tw.printf("%v", s1)
 		}
 	}
 }
 
-func Trace(l int, s ...interface{}) {
-	if CHRtrace >= l {
+func (tw *TraceWriter) Trace(l int, s ...interface{}) {
+	if tw.On(l) {
 		for _, s1 := range s {
-			fmt.Printf("%v", s1)
+			tw.printf("%v", s1)
 		}
 	}
 }
 
-func Traceln(l int, s ...interface{}) {
-	if CHRtrace >= l {
+func (tw *TraceWriter) Traceln(l int, s ...interface{}) {
+	if tw.On(l) {
 		for _, s1 := range s {
-			fmt.Printf("%v", s1)
+			tw.printf("%v", s1)
 		}
-		fmt.Printf("\n")
+		tw.printf("\n")
 	}
 }
 
-func TraceEnv(l int, e Bindings) {
+func (tw *TraceWriter) Env(l int, e Bindings) {
 	if e == nil {
-		Trace(l, "nil")
+		tw.Trace(l, "nil")
 	} else {
 		if e.Var.Name == "" {
-			Trace(l, "[\"\"=nil]")
+			tw.Trace(l, "[\"\"=nil]")
 		} else {
 			if e.Next == nil || e.Next.Var.Name == "" {
-				Trace(l, "[", e.Var.Name, "=", e.T.String(), ", nil]")
+				tw.Trace(l, "[", e.Var.Name, "=", e.T.String(), ", nil]")
 			} else {
-				Trace(l, "[", e.Var.Name, "=", e.T.String(), ",...]")
+				tw.Trace(l, "[", e.Var.Name, "=", e.T.String(), ",...]")
 			}
 		}
 
 	}
 }
 
-func TraceEMap(l int, n int, h *Compound, eMap *EnvMap) {
-	if CHRtrace >= l {
+func (tw *TraceWriter) EMap(l int, n int, h *Compound, eMap *EnvMap) {
+	if tw.On(l) {
 		for i := 0; i < n; i++ {
-			fmt.Printf("      ")
+			tw.printf("      ")
 		}
-		fmt.Printf("*** head: %s inBind: ", h.String())
-		TraceEnv(l, eMap.InBinding)
-		fmt.Printf(" outBind:[")
+		tw.printf("*** head: %s inBind: ", h.String())
+		tw.Env(l, eMap.InBinding)
+		tw.printf(" outBind:[")
 		env := eMap.OutBindings
 		for i, e := range env {
-			fmt.Printf("[ %d ] =", i)
-			// for _, e1 := range e.inBinding {
+			tw.printf("[ %d ] =", i)
 			if e == nil {
-				fmt.Printf("NIL")
+				tw.printf("NIL")
 			} else {
-				TraceEnv(l, e.InBinding)
+				tw.Env(l, e.InBinding)
 			}
-
-			// }
-			fmt.Printf(" | ")
+			tw.printf(" | ")
 		}
-		fmt.Printf("]\n")
+		tw.printf("]\n")
 	}
 }